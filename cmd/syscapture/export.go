@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+
+	"github.com/nodebytehosting/syscapture/internal/emit"
+	"github.com/nodebytehosting/syscapture/internal/influx"
+	"github.com/nodebytehosting/syscapture/internal/otlp"
+	"github.com/nodebytehosting/syscapture/internal/remotewrite"
+)
+
+// startExporters registers the configured exporters as collector sinks and
+// starts pushing until ctx is cancelled
+func startExporters(ctx context.Context) {
+	if cfg := appConfig.Load().RemoteWrite; cfg.URL != "" {
+		writer, err := remotewrite.New(remotewrite.Options{
+			URL:            cfg.URL,
+			Username:       cfg.Username,
+			Password:       cfg.Password,
+			BearerToken:    cfg.BearerToken,
+			ExternalLabels: cfg.ExternalLabels,
+			Interval:       cfg.Interval,
+			QueueDir:       cfg.QueueDir,
+			QueueSize:      cfg.QueueSize,
+		}, func(err error) {
+			logger.Warnf("Prometheus remote write: %v", err)
+		})
+		if err != nil {
+			logger.Fatalf("Unable to start Prometheus remote write: %v", err)
+		}
+		appCollector.AddSink(writer)
+		go writer.Run(ctx)
+		logger.Infof("Pushing metrics to %s every %s", cfg.URL, cfg.Interval)
+	}
+
+	if cfg := appConfig.Load().Influx; cfg.URL != "" {
+		pusher := influx.NewPusher(influx.PushOptions{
+			URL:           cfg.URL,
+			Org:           cfg.Org,
+			Bucket:        cfg.Bucket,
+			Token:         cfg.Token,
+			Tags:          cfg.Tags,
+			BatchSize:     cfg.BatchSize,
+			FlushInterval: cfg.FlushInterval,
+		}, func(err error) {
+			logger.Warnf("InfluxDB: %v", err)
+		})
+		appCollector.AddSink(pusher)
+		go pusher.Run(ctx)
+		logger.Infof("Pushing metrics to InfluxDB bucket %s at %s every %s", cfg.Bucket, cfg.URL, cfg.FlushInterval)
+	}
+
+	if cfg := appConfig.Load().OTLP; cfg.Endpoint != "" {
+		exporter := otlp.New(otlp.Options{
+			Endpoint:           cfg.Endpoint,
+			Protocol:           cfg.Protocol,
+			Headers:            cfg.Headers,
+			ResourceAttributes: cfg.ResourceAttributes,
+			Version:            Version,
+			Interval:           cfg.Interval,
+		}, func(err error) {
+			logger.Warnf("OTLP: %v", err)
+		})
+		appCollector.AddSink(exporter)
+		go exporter.Run(ctx)
+		logger.Infof("Exporting OTLP metrics to %s every %s", cfg.Endpoint+otlp.MetricsPath, cfg.Interval)
+	}
+
+	if cfg := appConfig.Load().StatsD; cfg.Address != "" {
+		statsd, err := emit.NewStatsD(emit.StatsDOptions{
+			Address:  cfg.Address,
+			Template: cfg.Template,
+		}, func(err error) {
+			logger.Warnf("StatsD: %v", err)
+		})
+		if err != nil {
+			logger.Fatalf("Unable to start the StatsD emitter: %v", err)
+		}
+		appCollector.AddSink(statsd)
+		go func() {
+			<-ctx.Done()
+			statsd.Close()
+		}()
+		logger.Infof("Sending StatsD gauges to %s", cfg.Address)
+	}
+
+	if cfg := appConfig.Load().Graphite; cfg.Address != "" {
+		graphite, err := emit.NewGraphite(emit.GraphiteOptions{
+			Address:     cfg.Address,
+			Template:    cfg.Template,
+			MaxBuffered: cfg.BufferSize,
+		}, func(err error) {
+			logger.Warnf("Graphite: %v", err)
+		})
+		if err != nil {
+			logger.Fatalf("Unable to start the Graphite emitter: %v", err)
+		}
+		appCollector.AddSink(graphite)
+		go graphite.Run(ctx)
+		logger.Infof("Sending Graphite lines to %s", cfg.Address)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/rpc"
+	"google.golang.org/grpc"
+)
+
+// startGRPC serves the gRPC service on the given listeners, using TLS if it is
+// configured except on unix domain sockets, and returns a function stopping
+// it gracefully within timeout
+func startGRPC(listeners []net.Listener) func(timeout time.Duration) {
+	if len(listeners) == 0 {
+		return func(time.Duration) {}
+	}
+
+	// The same protections as /api/v1, see initRouter
+	opts := rpc.Options{
+		Tokens:       appTokens,
+		JWT:          appJWT,
+		Access:       appAccess,
+		Bans:         appBans,
+		IPLimiter:    appIPLimiter,
+		TokenLimiter: appTokenLimiter,
+		Audit:        appAudit,
+		OnAuditError: func(err error) {
+			logger.Errorf("Unable to write audit log: %v", err)
+		},
+		Collector:  appCollector,
+		Forecaster: appForecast,
+	}
+	plain := rpc.NewServer(opts)
+	servers := []*grpc.Server{plain}
+
+	secure := plain
+	if appTLS != nil {
+		opts.TLS = appTLS.Config()
+		secure = rpc.NewServer(opts)
+		servers = append(servers, secure)
+	}
+
+	for _, l := range listeners {
+		server := secure
+		if l.Addr().Network() == "unix" {
+			server = plain
+		}
+		go func() {
+			if err := server.Serve(l); err != nil {
+				logger.Fatalf("gRPC server error: %v", err)
+			}
+		}()
+	}
+	logger.Infof("Serving gRPC on %d listeners", len(listeners))
+
+	return func(timeout time.Duration) {
+		for _, server := range servers {
+			// Streams only end when their clients leave, cut them off after timeout
+			stopped := make(chan struct{})
+			go func() {
+				server.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-time.After(timeout):
+				server.Stop()
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/handler"
+	"github.com/nodebytehosting/syscapture/internal/hub"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/middleware"
+	"github.com/nodebytehosting/syscapture/internal/systemd"
+	"github.com/nodebytehosting/syscapture/internal/token"
+)
+
+// appHub is the hub serving the fleet routes in place of the metrics routes, set in hub mode
+var appHub *hub.Hub
+
+// serveHub runs "syscapture hub": instead of collecting the local metrics, it
+// polls the agents of HUB_AGENTS, accepts metrics pushed by other agents and
+// serves the fleet routes until it is stopped
+func serveHub(configPath string) {
+	initConfig(configPath)
+	initLogger()
+	initTokens()
+	initAudit()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	initTLS(ctx)
+	go watchReload(ctx)
+
+	cfg := appConfig.Load().Hub
+	appHub = hub.New(hub.Options{
+		Agents:     cfg.Agents,
+		Token:      cfg.AgentToken,
+		Interval:   cfg.PollInterval,
+		Timeout:    cfg.Timeout,
+		StaleAfter: cfg.StaleAfter,
+	})
+	hubDone := make(chan struct{})
+	go func() {
+		defer close(hubDone)
+		appHub.Run(ctx)
+	}()
+	logger.Infof("Hub polling %d agents every %s", len(cfg.Agents), cfg.PollInterval)
+
+	apiListeners, adminListeners, grpcListeners := openListeners()
+	if len(grpcListeners) > 0 {
+		logger.Warn("The gRPC service is not available in hub mode, GRPC_LISTEN is ignored")
+		for _, l := range grpcListeners {
+			l.Close()
+		}
+	}
+
+	separateAdmin := len(adminListeners) > 0
+	server := newServer(ctx, initRouter(true, !separateAdmin))
+	server.RegisterOnShutdown(cancel)
+	servers := []*http.Server{server}
+	for _, l := range apiListeners {
+		go serve(server, l)
+	}
+
+	if separateAdmin {
+		adminServer := newServer(ctx, initRouter(false, true))
+		servers = append(servers, adminServer)
+		for _, l := range adminListeners {
+			go serve(adminServer, l)
+		}
+	}
+
+	if appNotifier.Enabled() {
+		status := fmt.Sprintf("Polling %d agents every %s", len(cfg.Agents), cfg.PollInterval)
+		if err := appNotifier.Notify(systemd.Ready, systemd.Status(status)); err != nil {
+			logger.Warnf("Unable to notify systemd: %v", err)
+		}
+	}
+
+	if err := gracefulShutdown(servers, 5*time.Second); err != nil {
+		logger.Fatalf("Graceful shutdown error: %v", err)
+	}
+
+	cancel()
+	<-hubDone
+	closeAudit()
+}
+
+// initHubRoutes registers the fleet routes served in hub mode
+func initHubRoutes(apiV1 *gin.RouterGroup) {
+	// Nodes and fleet aggregates
+	metrics := apiV1.Group("", middleware.RequireScope(token.ScopeMetricsRead))
+	metrics.GET("/nodes", func(c *gin.Context) {
+		handler.ListNodes(c, appHub)
+	})
+	metrics.GET("/nodes/:name", func(c *gin.Context) {
+		handler.GetNode(c, appHub)
+	})
+	metrics.GET("/nodes/:name/metrics", func(c *gin.Context) {
+		handler.NodeMetrics(c, appHub)
+	})
+	metrics.GET("/fleet/top", func(c *gin.Context) {
+		handler.FleetTop(c, appHub)
+	})
+	metrics.GET("/fleet/summary", func(c *gin.Context) {
+		handler.FleetSummary(c, appHub)
+	})
+
+	// Metrics pushed, registrations and heartbeats sent by the agents
+	nodes := apiV1.Group("", middleware.RequireScope(token.ScopeNodesWrite))
+	nodes.POST("/nodes/:name/metrics", func(c *gin.Context) {
+		handler.PushNodeMetrics(c, appHub)
+	})
+	nodes.POST("/nodes/:name/register", func(c *gin.Context) {
+		handler.RegisterNode(c, appHub)
+	})
+	nodes.POST("/nodes/:name/heartbeat", func(c *gin.Context) {
+		handler.NodeHeartbeat(c, appHub)
+	})
+}
+
+// startRegistration registers the agent with the hub of HUB_URL, if any, and
+// sends it heartbeats until ctx is cancelled
+func startRegistration(ctx context.Context) {
+	cfg := appConfig.Load().Hub
+	if cfg.URL == "" {
+		return
+	}
+
+	name := cfg.NodeName
+	if name == "" {
+		hostname, err := os.Hostname()
+		if err != nil || !hub.ValidName(hostname) {
+			logger.Errorf("Unable to name the node after its hostname %q, set HUB_NODE_NAME to register with the hub", hostname)
+			return
+		}
+		name = hostname
+	}
+
+	agent := hub.NewAgent(hub.AgentOptions{
+		HubURL:       cfg.URL,
+		Token:        cfg.Token,
+		Name:         name,
+		Interval:     cfg.HeartbeatInterval,
+		Registration: registration,
+		Summary: func() (metric.NodeSummary, bool) {
+			s, ok := appCollector.Latest()
+			return metric.Summarize(s.Metrics, s.Errors), ok
+		},
+	})
+	logger.Infof("Registering with the hub %s as %q", cfg.URL, name)
+	go agent.Run(ctx, func(err error) {
+		logger.Warn(err)
+	})
+}
+
+// registration returns what the agent announces to the hub
+func registration() hub.Registration {
+	cfg := appConfig.Load()
+	r := hub.Registration{
+		Version:      Version,
+		Address:      cfg.Hub.AdvertiseURL,
+		Listen:       cfg.Listen.Addresses,
+		Capabilities: []string{"metrics", "stream", "websocket"},
+	}
+	if host, _ := metric.GetHostInformation(); host != nil {
+		r.Host = *host
+	}
+
+	if appStore != nil {
+		r.Capabilities = append(r.Capabilities, "history", "forecast")
+	}
+	if appDetector != nil {
+		r.Capabilities = append(r.Capabilities, "anomalies")
+	}
+	if len(cfg.Listen.GRPCAddresses) > 0 {
+		r.Capabilities = append(r.Capabilities, "grpc")
+	}
+	if appTLS != nil {
+		r.Capabilities = append(r.Capabilities, "tls")
+	}
+	return r
+}
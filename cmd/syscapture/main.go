@@ -2,68 +2,194 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/anomaly"
+	"github.com/nodebytehosting/syscapture/internal/audit"
+	"github.com/nodebytehosting/syscapture/internal/cli"
+	"github.com/nodebytehosting/syscapture/internal/collector"
 	"github.com/nodebytehosting/syscapture/internal/config"
+	"github.com/nodebytehosting/syscapture/internal/forecast"
 	"github.com/nodebytehosting/syscapture/internal/handler"
+	"github.com/nodebytehosting/syscapture/internal/listener"
+	"github.com/nodebytehosting/syscapture/internal/metric"
 	"github.com/nodebytehosting/syscapture/internal/middleware"
+	"github.com/nodebytehosting/syscapture/internal/storage"
+	"github.com/nodebytehosting/syscapture/internal/systemd"
+	"github.com/nodebytehosting/syscapture/internal/tlsutil"
+	"github.com/nodebytehosting/syscapture/internal/token"
 	"github.com/sirupsen/logrus"
 )
 
 var (
-	appConfig *config.Config
-	Version   = "0.2.0-beta"
-	logger    = logrus.New()
+	appConfig    atomic.Pointer[config.Config] // Swapped by reloadConfig
+	appCollector *collector.Collector
+	appStore     *storage.Store
+	appForecast  *forecast.DiskForecaster
+	appDetector  *anomaly.Detector
+	appTokens    *token.Store
+	appTLS       *tlsutil.Manager
+	appHMAC      *middleware.HMACVerifier
+	appJWT       *middleware.JWTAuthenticator
+	appAudit     *audit.Log
+
+	appConfigPath   string
+	appNotifier     = systemd.NewNotifier(os.Getenv("NOTIFY_SOCKET"))
+	appAccess       *middleware.AccessList
+	appAdminAccess  *middleware.AccessList
+	appIPLimiter    *middleware.RateLimiter
+	appTokenLimiter *middleware.RateLimiter
+	appBans         *middleware.AuthBan
+	Version         = "0.2.0-beta"
+	logger          = logrus.New()
 )
 
 func main() {
-	// Parse command-line flags
+	// Parse command-line flags, which may also follow the command
 	showVersion := flag.Bool("version", false, "Display the current version of SysCapture")
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "Path to a YAML or TOML configuration file")
+	flag.Usage = usage
 	flag.Parse()
 
+	command := flag.Arg(0)
+	switch command {
+	case "", "serve", "hub":
+		if flag.NArg() > 0 {
+			if err := flag.CommandLine.Parse(flag.Args()[1:]); err != nil {
+				os.Exit(2)
+			}
+		}
+		if flag.NArg() > 0 {
+			fmt.Fprintf(os.Stderr, "Unexpected argument %q\n", flag.Arg(0))
+			flag.Usage()
+			os.Exit(2)
+		}
+	case "collect":
+		// collect has flags of its own
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	// Display version if the flag is provided
 	if *showVersion {
 		fmt.Printf("SysCapture version: %s\n", Version)
 		os.Exit(0)
 	}
 
+	switch command {
+	case "collect":
+		os.Exit(cli.Collect(flag.Args()[1:], os.Stdout, os.Stderr, metric.CollectFiltered))
+	case "hub":
+		serveHub(*configPath)
+	default:
+		serveAgent(*configPath)
+	}
+}
+
+// usage prints the commands and flags of SysCapture
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: syscapture [flags] [command]
+
+Commands:
+  serve     Collect metrics and serve the API (default)
+  hub       Serve the metrics of other agents
+  collect   Run the collectors once and print the metrics, see "syscapture collect -h"
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+// serveAgent runs "syscapture serve": it collects the metrics and serves the
+// API until it is stopped
+func serveAgent(configPath string) {
 	// Initialize configuration
-	initConfig()
+	initConfig(configPath)
 
 	// Initialize logger
 	initLogger()
 
-	// Initialize Gin router
-	r := initRouter()
+	// Initialize API tokens
+	initTokens()
 
-	// Create and start the HTTP server
-	server := &http.Server{
-		Addr:              ":" + appConfig.Port,
-		Handler:           r,
-		ReadHeaderTimeout: 5 * time.Second,
+	// Initialize the audit log
+	initAudit()
+
+	// Initialize history storage and start the background collector
+	initStorage()
+	ctx, cancel := context.WithCancel(context.Background())
+	collectorDone := startCollector(ctx)
+
+	// Load the TLS certificate and reload it when it changes
+	initTLS(ctx)
+
+	// Reload the configuration on SIGHUP or when the file changes
+	go watchReload(ctx)
+
+	// Open the listeners, or take over the sockets passed by systemd
+	apiListeners, adminListeners, grpcListeners := openListeners()
+
+	// Initialize Gin routers, the health check and admin routes get their own
+	// server when there are admin listeners
+	separateAdmin := len(adminListeners) > 0
+	server := newServer(ctx, initRouter(true, !separateAdmin))
+	// End long-lived streams when shutting down, they would otherwise block it
+	server.RegisterOnShutdown(cancel)
+	servers := []*http.Server{server}
+	for _, l := range apiListeners {
+		go serve(server, l)
+	}
+
+	if separateAdmin {
+		adminServer := newServer(ctx, initRouter(false, true))
+		servers = append(servers, adminServer)
+		for _, l := range adminListeners {
+			go serve(adminServer, l)
+		}
 	}
 
-	go serve(server)
+	// Serve the gRPC service alongside the HTTP API
+	stopGRPC := startGRPC(grpcListeners)
+
+	// Register with the hub, if one is configured, now that the agent can be reached
+	startRegistration(ctx)
+
+	// Tell systemd the service is ready and feed its watchdog while the collector makes progress
+	notifyReady(ctx)
 
 	// Graceful shutdown
-	if err := gracefulShutdown(server, 5*time.Second); err != nil {
+	if err := gracefulShutdown(servers, 5*time.Second); err != nil {
 		logger.Fatalf("Graceful shutdown error: %v", err)
 	}
+	stopGRPC(5 * time.Second)
+
+	// Stop the collector before closing the storage it writes to
+	cancel()
+	<-collectorDone
+	closeStorage()
+	closeAudit()
 }
 
-// initConfig initializes the application configuration
-func initConfig() {
-	appConfig = config.NewConfig(
-		os.Getenv("PORT"),
-		os.Getenv("API_SECRET"),
-	)
+// initConfig loads the configuration file, if any, and the environment variables overriding it
+func initConfig(path string) {
+	cfg, err := config.Load(path, os.Getenv)
+	if err != nil {
+		logrus.Fatalf("Invalid configuration:\n%v", err)
+	}
+	appConfig.Store(cfg)
+	appConfigPath = path
 }
 
 // initLogger initializes the logger
@@ -75,44 +201,435 @@ func initLogger() {
 	})
 }
 
-// initRouter initializes the Gin router with routes and middleware
-func initRouter() *gin.Engine {
+// initTokens loads the API tokens and creates the authenticators and access
+// controls, which are configured by applyConfig
+func initTokens() {
+	cfg := appConfig.Load()
+	store, err := token.Open(cfg.TokensFile)
+	if err != nil {
+		logger.Fatalf("Unable to load API tokens: %v", err)
+	}
+	appTokens = store
+
+	appHMAC = middleware.NewHMACVerifier(cfg.HMAC.MaxSkew)
+	appJWT = middleware.NewJWTAuthenticator(nil)
+	appAccess = middleware.NewAccessList(nil, nil)
+	appAdminAccess = middleware.NewAccessList(nil, nil)
+	appIPLimiter = middleware.NewRateLimiter(0, 1)
+	appTokenLimiter = middleware.NewRateLimiter(0, 1)
+	appBans = middleware.NewAuthBan(0, 0, 0)
+
+	if err := applyConfig(cfg); err != nil {
+		logger.Fatalf("Invalid configuration: %v", err)
+	}
+
+	if store.Len() == 0 && len(cfg.TLS.ClientScopes) == 0 && len(cfg.HMAC.Keys) == 0 && cfg.JWT.JWKSFile == "" {
+		logger.Fatalln("No API tokens configured. Set API_SECRET to bootstrap an admin token.")
+	}
+}
+
+// initAudit opens the audit log if a destination is configured
+func initAudit() {
+	cfg := appConfig.Load()
+	if cfg.Audit.Destination == "" {
+		logger.Info("AUDIT_LOG not set, API access is not audited")
+		return
+	}
+
+	log, err := audit.Open(audit.Options{
+		Destination: cfg.Audit.Destination,
+		MaxSize:     cfg.Audit.MaxSize,
+		MaxFiles:    cfg.Audit.MaxFiles,
+	})
+	if err != nil {
+		logger.Fatalf("Unable to open audit log: %v", err)
+	}
+	appAudit = log
+}
+
+// closeAudit closes the audit log
+func closeAudit() {
+	if appAudit == nil {
+		return
+	}
+	if err := appAudit.Close(); err != nil {
+		logger.Errorf("Unable to close audit log: %v", err)
+	}
+}
+
+// initStorage opens the on-disk history storage if a storage path is configured
+func initStorage() {
+	cfg := appConfig.Load()
+	if cfg.StoragePath == "" {
+		logger.Info("STORAGE_PATH not set, metrics history is disabled")
+		return
+	}
+
+	store, err := storage.Open(cfg.StoragePath)
+	if err != nil {
+		logger.Fatalf("Unable to open history storage: %v", err)
+	}
+	appStore = store
+	appForecast = forecast.NewDiskForecaster(store, forecast.DefaultWindow)
+}
+
+// initTLS loads the TLS certificate if HTTPS is configured. The certificate is
+// reloaded when its files change or on SIGHUP, see watchReload.
+func initTLS(ctx context.Context) {
+	cfg := appConfig.Load()
+	if !cfg.TLS.Enabled() {
+		return
+	}
+
+	manager, err := tlsutil.NewManager(tlsutil.Options{
+		CertFile:     cfg.TLS.CertFile,
+		KeyFile:      cfg.TLS.KeyFile,
+		MinVersion:   cfg.TLS.MinVersion,
+		CipherSuites: cfg.TLS.CipherSuites,
+		ClientCAFile: cfg.TLS.ClientCAFile,
+		ClientAuth:   cfg.TLS.ClientAuth,
+	})
+	if err != nil {
+		logger.Fatalf("Unable to load TLS certificate: %v", err)
+	}
+	appTLS = manager
+
+	go manager.Watch(ctx, 10*time.Second, func(err error) {
+		logger.Errorf("Unable to reload TLS certificate: %v", err)
+	})
+}
+
+// startCollector starts the background collector and returns a channel that is closed once it stops
+func startCollector(ctx context.Context) <-chan struct{} {
+	cfg := appConfig.Load()
+	appCollector = collector.New(cfg.CollectInterval, collector.DefaultBufferSize)
+	appCollector.SetFilter(cfg.Filter)
+
+	// Forecasts and anomaly scores are added before the sinks run, so the history
+	// and the exporters carry them
+	if appForecast != nil {
+		appCollector.AddAnnotator(func(_ time.Time, m *metric.AllMetrics) {
+			appForecast.Annotate(m.Disk)
+		})
+	}
+
+	if len(cfg.AnomalyMetrics) > 0 {
+		appDetector = anomaly.New(anomaly.Config{
+			Metrics:   cfg.AnomalyMetrics,
+			Threshold: cfg.AnomalyThreshold,
+			Alpha:     cfg.AnomalyAlpha,
+			Warmup:    anomaly.DefaultWarmup,
+		})
+		appCollector.AddAnnotator(appDetector.Annotate)
+	}
+
+	if appStore != nil {
+		appCollector.AddSink(collector.SinkFunc(func(s collector.Snapshot) {
+			if err := appStore.Append(s.Timestamp, s.Samples()); err != nil {
+				logger.Errorf("Unable to store metrics history: %v", err)
+			}
+		}))
+	}
+
+	// Push the collected metrics to the configured exporters
+	startExporters(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		appCollector.Run(ctx)
+	}()
+	return done
+}
+
+// notifyReady sends READY=1 to systemd and, if WatchdogSec= is set, starts
+// feeding the watchdog for as long as the collector makes progress
+func notifyReady(ctx context.Context) {
+	if !appNotifier.Enabled() {
+		return
+	}
+
+	status := fmt.Sprintf("Collecting metrics every %s", appConfig.Load().CollectInterval)
+	if err := appNotifier.Notify(systemd.Ready, systemd.Status(status)); err != nil {
+		logger.Warnf("Unable to notify systemd: %v", err)
+	}
+
+	timeout := systemd.WatchdogInterval(os.Getenv, os.Getpid())
+	if timeout == 0 {
+		return
+	}
+	go appNotifier.RunWatchdog(ctx, timeout/2, collectorProgress(time.Now()), func(err error) {
+		logger.Warnf("Unable to notify systemd: %v", err)
+	})
+}
+
+// collectorProgress returns a watchdog check that fails once the collector has
+// not completed a collection for three intervals, or at least 30 seconds
+func collectorProgress(started time.Time) func() (string, bool) {
+	stalledAfter := max(3*appCollector.Interval(), 30*time.Second)
+
+	return func() (string, bool) {
+		last := started
+		if s, ok := appCollector.Latest(); ok {
+			last = s.Timestamp
+		}
+
+		age := time.Since(last).Round(time.Second)
+		if age > stalledAfter {
+			return fmt.Sprintf("Collector stalled, last collection %s ago", age), false
+		}
+		return fmt.Sprintf("Collecting metrics every %s, last collection %s ago", appCollector.Interval(), age), true
+	}
+}
+
+// closeStorage flushes and closes the history storage
+func closeStorage() {
+	if appStore == nil {
+		return
+	}
+	if err := appStore.Close(); err != nil {
+		logger.Errorf("Unable to close history storage: %v", err)
+	}
+}
+
+// initRouter initializes the Gin router with the metrics routes, the health
+// check and admin routes, or both
+func initRouter(metricsRoutes bool, adminRoutes bool) *gin.Engine {
 	r := gin.Default()
+
+	// Only read X-Forwarded-For from the configured proxies, anyone could spoof it otherwise
+	if err := r.SetTrustedProxies(appConfig.Load().Access.TrustedProxies); err != nil {
+		logger.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// The audit log comes first so denied, banned and rate limited requests are recorded
 	apiV1 := r.Group("/api/v1")
-	apiV1.Use(middleware.AuthRequired(appConfig.APISecret))
+	if appAudit != nil {
+		apiV1.Use(middleware.Audit(appAudit, func(err error) {
+			logger.Errorf("Unable to write audit log: %v", err)
+		}))
+	}
+	apiV1.Use(
+		appAccess.Middleware(),
+		appBans.Middleware(),
+		middleware.RateLimitByIP(appIPLimiter),
+	)
+
+	// WebSocket subscriptions authenticate in-band, browsers cannot set the Authorization header
+	if metricsRoutes && appHub == nil {
+		apiV1.GET("/ws", webSocket)
+	}
+
+	apiV1.Use(
+		middleware.AuthRequired(appTokens, appHMAC, appJWT),
+		middleware.RateLimitByToken(appTokenLimiter),
+		middleware.Compress(),
+	)
+
+	switch {
+	case metricsRoutes && appHub != nil:
+		initHubRoutes(apiV1)
+	case metricsRoutes:
+		initMetricsRoutes(apiV1)
+	}
+	if adminRoutes {
+		initAdminRoutes(apiV1)
+	}
+
+	return r
+}
+
+// webSocket serves the WebSocket subscriptions. Clients presenting a certificate
+// mapped to the metrics:read scope are authenticated by it, the others by the
+// token they offer. The token is recorded for the audit log like on the other routes.
+func webSocket(c *gin.Context) {
+	authenticated := false
+	if t := middleware.CertificateToken(c, appTokens); t != nil && t.HasScope(token.ScopeMetricsRead) {
+		c.Set(middleware.TokenKey, t)
+		authenticated = true
+	}
+
+	handler.MetricsWebSocket(c, appCollector, appDetector, authenticated, func(secret string) error {
+		t, err := webSocketToken(secret)
+		if err != nil {
+			// Recorded in place of the upgrade, so that the failure counts towards a ban
+			c.Set(middleware.AuthErrorKey, "Invalid token provided")
+			c.Set(middleware.StatusKey, http.StatusForbidden)
+			return err
+		}
+		c.Set(middleware.TokenKey, t)
+		return nil
+	})
+}
+
+// webSocketToken authenticates a token offered over a WebSocket, a JWT or an API token
+func webSocketToken(secret string) (*token.Token, error) {
+	if appJWT.Handles(secret) {
+		t, err := appJWT.Token(secret)
+		if err == nil && !t.HasScope(token.ScopeMetricsRead) {
+			return nil, middleware.ErrMissingScope
+		}
+		return t, err
+	}
+	return middleware.ValidateToken(appTokens, secret, token.ScopeMetricsRead)
+}
+
+// initMetricsRoutes registers the metrics routes
+func initMetricsRoutes(apiV1 *gin.RouterGroup) {
+	// Metrics
+	metrics := apiV1.Group("", middleware.RequireScope(token.ScopeMetricsRead))
+	// Requests selecting no fields get those of the background collections
+	metrics.GET("/metrics", func(c *gin.Context) {
+		handler.Metrics(c, appForecast, appCollector.Filter())
+	})
+	metrics.GET("/metrics/cpu", func(c *gin.Context) {
+		handler.MetricsCPU(c, appCollector.Filter())
+	})
+	metrics.GET("/metrics/memory", func(c *gin.Context) {
+		handler.MetricsMemory(c, appCollector.Filter())
+	})
+	metrics.GET("/metrics/disk", func(c *gin.Context) {
+		handler.MetricsDisk(c, appForecast, appCollector.Filter())
+	})
+	metrics.GET("/metrics/host", func(c *gin.Context) {
+		handler.MetricsHost(c, appCollector.Filter())
+	})
+	metrics.GET("/metrics/stream", func(c *gin.Context) {
+		handler.MetricsStream(c, appCollector)
+	})
+	metrics.GET("/metrics/history", func(c *gin.Context) {
+		handler.MetricsHistory(c, appStore)
+	})
+
+	// Anomalies
+	metrics.GET("/anomalies", func(c *gin.Context) {
+		handler.Anomalies(c, appDetector)
+	})
+}
 
+// initAdminRoutes registers the health check and the admin routes
+func initAdminRoutes(apiV1 *gin.RouterGroup) {
 	// Health Check
 	apiV1.GET("/health", func(c *gin.Context) {
 		handler.Health(c, Version)
 	})
 
-	// Metrics
-	apiV1.GET("/metrics", handler.Metrics)
-	apiV1.GET("/metrics/cpu", handler.MetricsCPU)
-	apiV1.GET("/metrics/memory", handler.MetricsMemory)
-	apiV1.GET("/metrics/disk", handler.MetricsDisk)
-	apiV1.GET("/metrics/host", handler.MetricsHost)
+	// Token management
+	admin := apiV1.Group("", appAdminAccess.Middleware(), middleware.RequireScope(token.ScopeAdmin))
+	admin.GET("/tokens", func(c *gin.Context) {
+		handler.ListTokens(c, appTokens)
+	})
+	admin.POST("/tokens", func(c *gin.Context) {
+		handler.CreateToken(c, appTokens)
+	})
+	admin.DELETE("/tokens/:name", func(c *gin.Context) {
+		handler.DeleteToken(c, appTokens)
+	})
 
-	return r
+	// Audit log
+	admin.GET("/audit", func(c *gin.Context) {
+		handler.Audit(c, appAudit)
+	})
+}
+
+// newServer creates an HTTP server for handler, using HTTPS if TLS is configured
+func newServer(ctx context.Context, h http.Handler) *http.Server {
+	server := &http.Server{
+		Handler:           listener.LocalClients(h),
+		ReadHeaderTimeout: 5 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+	if appTLS != nil {
+		server.TLSConfig = appTLS.Config()
+	}
+	return server
+}
+
+// openListeners returns the listeners of the API, of the admin routes and of
+// the gRPC service. When socket activated, the sockets passed by systemd
+// replace LISTEN, ADMIN_LISTEN and GRPC_LISTEN, sockets named "admin" serving
+// the admin routes and sockets named "grpc" the gRPC service.
+func openListeners() ([]net.Listener, []net.Listener, []net.Listener) {
+	cfg := appConfig.Load()
+	activated, err := systemd.Listeners()
+	if err != nil {
+		logger.Fatalf("Unable to use the sockets passed by systemd: %v", err)
+	}
+	if len(activated) == 0 {
+		return listen(cfg.Listen.Addresses), listen(cfg.Listen.AdminAddresses), listen(cfg.Listen.GRPCAddresses)
+	}
+
+	var apiListeners, adminListeners, grpcListeners []net.Listener
+	for _, l := range activated {
+		logger.Infof("Listening on %s passed by systemd as %q", l.Addr(), l.Name)
+		switch l.Name {
+		case "admin":
+			adminListeners = append(adminListeners, l)
+		case "grpc":
+			grpcListeners = append(grpcListeners, l)
+		default:
+			apiListeners = append(apiListeners, l)
+		}
+	}
+	if len(apiListeners) == 0 {
+		logger.Fatalln("systemd only passed sockets named \"admin\" or \"grpc\", the API needs one more")
+	}
+	return apiListeners, adminListeners, grpcListeners
 }
 
-// serve starts the HTTP server
-func serve(srv *http.Server) {
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+// listen opens the listeners of the given addresses
+func listen(addresses []string) []net.Listener {
+	cfg := appConfig.Load().Listen
+	opts := listener.Options{
+		SocketMode:  cfg.SocketMode,
+		SocketGroup: cfg.SocketGroup,
+	}
+
+	listeners := make([]net.Listener, 0, len(addresses))
+	for _, address := range addresses {
+		l, err := listener.Listen(address, opts)
+		if err != nil {
+			logger.Fatalf("Unable to listen on %s: %v", address, err)
+		}
+		logger.Infof("Listening on %s", address)
+		listeners = append(listeners, l)
+	}
+	return listeners
+}
+
+// serve serves srv on l, using HTTPS if TLS is configured. Unix domain sockets
+// are local and always serve plain HTTP.
+func serve(srv *http.Server, l net.Listener) {
+	var err error
+	if appTLS != nil && l.Addr().Network() != "unix" {
+		err = srv.ServeTLS(l, "", "")
+	} else {
+		err = srv.Serve(l)
+	}
+	if err != nil && err != http.ErrServerClosed {
 		logger.Fatalf("Server listen error: %v", err)
 	}
 }
 
-// gracefulShutdown handles graceful shutdown of the server
-func gracefulShutdown(srv *http.Server, timeout time.Duration) error {
+// gracefulShutdown handles graceful shutdown of the servers
+func gracefulShutdown(servers []*http.Server, timeout time.Duration) error {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	sig := <-quit
 	logger.Infof("Signal received: %v", sig)
+	if err := appNotifier.Notify(systemd.Stopping); err != nil {
+		logger.Warnf("Unable to notify systemd: %v", err)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	return srv.Shutdown(ctx)
+	var errs []error
+	for _, srv := range servers {
+		errs = append(errs, srv.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/config"
+	"github.com/nodebytehosting/syscapture/internal/jwt"
+	"github.com/nodebytehosting/syscapture/internal/token"
+)
+
+// configWatchInterval is how often the configuration file is checked for changes.
+const configWatchInterval = 5 * time.Second
+
+// applyConfig applies the settings that can change while the server is running:
+// API_SECRET, the client certificate, HMAC and JWT scopes, the access lists,
+// rate limits and bans. Nothing is changed if any of them is invalid.
+func applyConfig(cfg *config.Config) error {
+	scopes := map[string]map[string][]string{
+		"client certificate": cfg.TLS.ClientScopes,
+		"HMAC key":           cfg.HMAC.Scopes,
+		"JWT claim value":    cfg.JWT.ScopeMap,
+	}
+	for kind, mapping := range scopes {
+		for name, granted := range mapping {
+			for _, scope := range granted {
+				if !token.ValidScope(scope) {
+					return fmt.Errorf("unknown scope %q granted to %s %q", scope, kind, name)
+				}
+			}
+		}
+	}
+
+	var verifier *jwt.Verifier
+	if cfg.JWT.JWKSFile != "" {
+		var err error
+		verifier, err = jwt.NewVerifier(jwt.Options{
+			JWKSFile:    cfg.JWT.JWKSFile,
+			Issuer:      cfg.JWT.Issuer,
+			Audience:    cfg.JWT.Audience,
+			Leeway:      cfg.JWT.Leeway,
+			ScopesClaim: cfg.JWT.ScopesClaim,
+			ScopeMap:    cfg.JWT.ScopeMap,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.APISecret != "" {
+		appTokens.AddStatic("default", cfg.APISecret, []string{token.ScopeAdmin})
+	} else {
+		appTokens.RemoveStatic("default")
+	}
+	appTokens.SetIdentities(cfg.TLS.ClientScopes)
+
+	appHMAC.SetKeys(cfg.HMAC.Keys, cfg.HMAC.Scopes)
+	appHMAC.SetSkew(cfg.HMAC.MaxSkew)
+	appJWT.SetVerifier(verifier)
+
+	appAccess.Set(cfg.Access.Allow, cfg.Access.Deny)
+	appAdminAccess.Set(cfg.Access.AdminAllow, cfg.Access.AdminDeny)
+	// Allow bursts of twice the rate per second
+	appIPLimiter.SetRate(cfg.Access.IPRate, int(math.Ceil(2*cfg.Access.IPRate)))
+	appTokenLimiter.SetRate(cfg.Access.TokenRate, int(math.Ceil(2*cfg.Access.TokenRate)))
+	appBans.SetLimits(cfg.Access.BanThreshold, cfg.Access.BanWindow, cfg.Access.BanDuration)
+	return nil
+}
+
+// reloadConfig loads the configuration again and applies the settings that can
+// change while the server is running. The current configuration stays in use if
+// the new one is invalid.
+func reloadConfig() {
+	next, err := config.Load(appConfigPath, os.Getenv)
+	if err != nil {
+		logger.Errorf("Configuration not reloaded: %v", err)
+		return
+	}
+
+	merged, restart := appConfig.Load().Reloadable(next)
+	if err := applyConfig(merged); err != nil {
+		logger.Errorf("Configuration not reloaded: %v", err)
+		return
+	}
+	appConfig.Store(merged)
+	// Applies from the next collection on, and to the requests selecting no fields
+	appCollector.SetFilter(merged.Filter)
+
+	for _, name := range restart {
+		logger.Warnf("%s changed, restart SysCapture to apply it", name)
+	}
+	logger.Info("Configuration reloaded")
+}
+
+// watchReload reloads the configuration and the TLS certificate on SIGHUP, and
+// the configuration when its file changes, until ctx is cancelled.
+func watchReload(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+	modTime := fileModTime(appConfigPath)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if appTLS != nil {
+				if err := appTLS.Reload(); err != nil {
+					logger.Errorf("Unable to reload TLS certificate: %v", err)
+				} else {
+					logger.Info("TLS certificate reloaded")
+				}
+			}
+			reloadConfig()
+		case <-ticker.C:
+			if appConfigPath == "" {
+				continue
+			}
+			if current := fileModTime(appConfigPath); !current.Equal(modTime) {
+				modTime = current
+				reloadConfig()
+			}
+		}
+	}
+}
+
+// fileModTime returns the modification time of path, or the zero time if it cannot be read.
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,184 @@
+package anomaly
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+const (
+	// DefaultWarmup is the number of observations a bucket needs before it can flag anomalies.
+	DefaultWarmup = 30
+
+	// seasons is the number of hour-of-week buckets of a baseline.
+	seasons = 7 * 24
+
+	// maxAnomalies is the number of recent anomalies kept in memory.
+	maxAnomalies = 1000
+
+	// minRelativeDeviation keeps near-constant metrics from flagging tiny changes.
+	minRelativeDeviation = 0.01
+)
+
+// Config holds the settings of the Detector.
+type Config struct {
+	Metrics   []string // Metric names to watch, e.g. "cpu.usage_percent"
+	Threshold float64  // Absolute z-score above which a sample is anomalous
+	Alpha     float64  // EWMA smoothing factor in (0, 1]
+	Warmup    int      // Observations a bucket needs before it can flag anomalies
+}
+
+// bucket is the exponentially weighted mean and variance of one hour of the week.
+type bucket struct {
+	mean     float64
+	variance float64
+	count    int
+}
+
+// update folds x into the bucket.
+func (b *bucket) update(x, alpha float64) {
+	if b.count == 0 {
+		b.mean = x
+		b.count = 1
+		return
+	}
+	diff := x - b.mean
+	increment := alpha * diff
+	b.mean += increment
+	b.variance = (1 - alpha) * (b.variance + diff*increment)
+	b.count++
+}
+
+// baseline is the seasonal baseline of a single series.
+type baseline struct {
+	name    string
+	labels  map[string]string
+	buckets [seasons]bucket
+	zScore  float64 // z-score of the latest sample
+	active  bool    // Whether the latest sample was anomalous
+}
+
+// Detector keeps a seasonal (hour-of-day and day-of-week) baseline per series and
+// flags samples whose z-score exceeds the threshold.
+type Detector struct {
+	cfg     Config
+	watched map[string]bool
+
+	mu        sync.RWMutex
+	baselines map[string]*baseline
+	anomalies []metric.Anomaly
+}
+
+// New creates a Detector for the configured metrics.
+func New(cfg Config) *Detector {
+	watched := make(map[string]bool, len(cfg.Metrics))
+	for _, m := range cfg.Metrics {
+		watched[m] = true
+	}
+
+	return &Detector{
+		cfg:       cfg,
+		watched:   watched,
+		baselines: make(map[string]*baseline),
+	}
+}
+
+// Annotate implements collector.AnnotateFunc: it observes the collected samples
+// and adds the resulting anomaly.z_score and anomaly.active samples to m, so the
+// history, the streams and the exporters carry them.
+func (d *Detector) Annotate(ts time.Time, m *metric.AllMetrics) {
+	d.Observe(ts, m.Samples())
+	m.Derived = append(m.Derived, d.Samples()...)
+}
+
+// Observe scores the samples against their baseline, records the anomalous ones
+// and then updates the baseline. It returns the anomalies found.
+func (d *Detector) Observe(ts time.Time, samples []metric.Sample) []metric.Anomaly {
+	season := int(ts.Weekday())*24 + ts.Hour()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var found []metric.Anomaly
+	for _, s := range samples {
+		if !d.watched[s.Name] {
+			continue
+		}
+
+		key := s.Key()
+		base, ok := d.baselines[key]
+		if !ok {
+			base = &baseline{name: s.Name, labels: s.Labels}
+			d.baselines[key] = base
+		}
+
+		b := &base.buckets[season]
+		base.zScore = 0
+		base.active = false
+		if b.count >= d.cfg.Warmup {
+			deviation := math.Max(math.Sqrt(b.variance), math.Max(math.Abs(b.mean)*minRelativeDeviation, 1e-9))
+			base.zScore = (s.Value - b.mean) / deviation
+
+			if math.Abs(base.zScore) > d.cfg.Threshold {
+				base.active = true
+				found = append(found, metric.Anomaly{
+					Timestamp: ts,
+					Metric:    s.Name,
+					Labels:    s.Labels,
+					Value:     s.Value,
+					Expected:  metric.RoundFloat(b.mean, 4),
+					Deviation: metric.RoundFloat(deviation, 4),
+					ZScore:    metric.RoundFloat(base.zScore, 2),
+				})
+			}
+		}
+		b.update(s.Value, d.cfg.Alpha)
+	}
+
+	d.anomalies = append(d.anomalies, found...)
+	if len(d.anomalies) > maxAnomalies {
+		d.anomalies = append([]metric.Anomaly(nil), d.anomalies[len(d.anomalies)-maxAnomalies:]...)
+	}
+	return found
+}
+
+// Anomalies returns the recorded anomalies that occurred at or after since, oldest first.
+func (d *Detector) Anomalies(since time.Time) metric.AnomalySlice {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	result := make(metric.AnomalySlice, 0)
+	for _, a := range d.anomalies {
+		if !a.Timestamp.Before(since) {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// Samples returns the latest z-score and anomaly state of every watched series as
+// anomaly.z_score and anomaly.active samples, for use as alert-rule inputs.
+func (d *Detector) Samples() []metric.Sample {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	samples := make([]metric.Sample, 0, len(d.baselines)*2)
+	for _, base := range d.baselines {
+		labels := map[string]string{"metric": base.name}
+		for k, v := range base.labels {
+			labels[k] = v
+		}
+
+		active := 0.0
+		if base.active {
+			active = 1
+		}
+		samples = append(samples,
+			metric.Sample{Name: "anomaly.z_score", Labels: labels, Value: metric.RoundFloat(base.zScore, 2)},
+			metric.Sample{Name: "anomaly.active", Labels: labels, Value: active},
+		)
+	}
+	return samples
+}
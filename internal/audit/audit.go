@@ -0,0 +1,247 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Syslog is the destination that writes the audit log to the local syslog daemon.
+const Syslog = "syslog"
+
+// Defaults of the file destination.
+const (
+	DefaultMaxSize  = 100 << 20 // Size at which the file is rotated
+	DefaultMaxFiles = 5         // Rotated files that are kept
+)
+
+// memorySize is the number of entries kept in memory when the destination cannot be read back.
+const memorySize = 10000
+
+// Entry is a single audited request.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	ClientIP  string    `json:"client_ip"`
+	Token     string    `json:"token,omitempty"` // Name of the authenticated token, never the secret
+	Method    string    `json:"method"`
+	Route     string    `json:"route"` // Route pattern, e.g. /api/v1/tokens/:name
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"` // Reason of a failed authentication or authorization
+}
+
+// Query filters the entries returned by Log.Query.
+type Query struct {
+	Since  time.Time // Only entries at or after Since, unless zero
+	Until  time.Time // Only entries before Until, unless zero
+	Token  string    // Only entries of this token, unless empty
+	Status int       // Only entries with this status, unless zero
+	Failed bool      // Only entries with a status of 400 or above
+	Limit  int       // Maximum number of entries, the newest are kept
+}
+
+// Options configures a Log.
+type Options struct {
+	Destination string // File path, or Syslog
+	MaxSize     int64  // File size in bytes at which the file is rotated
+	MaxFiles    int    // Rotated files that are kept
+}
+
+// Log writes audit entries as JSON lines to a rotating file or to syslog.
+type Log struct {
+	opts Options
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	syslog io.WriteCloser
+	recent []Entry // Entries written to syslog, which cannot be read back
+}
+
+// Open opens the audit log destination.
+func Open(opts Options) (*Log, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.MaxFiles <= 0 {
+		opts.MaxFiles = DefaultMaxFiles
+	}
+
+	l := &Log{opts: opts}
+	if opts.Destination == Syslog {
+		w, err := openSyslog()
+		if err != nil {
+			return nil, fmt.Errorf("open syslog: %w", err)
+		}
+		l.syslog = w
+		return l, nil
+	}
+
+	if err := l.openFile(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Write records an entry.
+func (l *Log) Write(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.syslog != nil {
+		l.recent = append(l.recent, e)
+		if len(l.recent) > memorySize {
+			l.recent = slices.Delete(l.recent, 0, len(l.recent)-memorySize)
+		}
+		_, err := l.syslog.Write(line)
+		return err
+	}
+
+	if l.size+int64(len(line))+1 > l.opts.MaxSize && l.size > 0 {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(append(line, '\n'))
+	l.size += int64(n)
+	return err
+}
+
+// Query returns the entries matching q, oldest first.
+func (l *Log) Query(q Query) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var entries []Entry
+	keep := func(e Entry) {
+		if !q.matches(e) {
+			return
+		}
+		entries = append(entries, e)
+		if q.Limit > 0 && len(entries) > 2*q.Limit {
+			entries = slices.Delete(entries, 0, len(entries)-q.Limit)
+		}
+	}
+
+	if l.syslog != nil {
+		for _, e := range l.recent {
+			keep(e)
+		}
+	} else {
+		for i := l.opts.MaxFiles; i >= 0; i-- {
+			if err := readFile(l.rotatedPath(i), keep); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[len(entries)-q.Limit:]
+	}
+	return entries, nil
+}
+
+// Close closes the destination.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.syslog != nil {
+		return l.syslog.Close()
+	}
+	return l.file.Close()
+}
+
+// matches reports whether e passes the filters of q.
+func (q Query) matches(e Entry) bool {
+	switch {
+	case !q.Since.IsZero() && e.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !e.Time.Before(q.Until):
+		return false
+	case q.Token != "" && e.Token != q.Token:
+		return false
+	case q.Status != 0 && e.Status != q.Status:
+		return false
+	case q.Failed && e.Status < 400:
+		return false
+	}
+	return true
+}
+
+// openFile opens the current file for appending.
+func (l *Log) openFile() error {
+	f, err := os.OpenFile(l.opts.Destination, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("open audit log: %w", err)
+	}
+
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// rotate shifts the rotated files by one, dropping the oldest, and starts a new file.
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+
+	for i := l.opts.MaxFiles - 1; i >= 0; i-- {
+		err := os.Rename(l.rotatedPath(i), l.rotatedPath(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return l.openFile()
+}
+
+// rotatedPath returns the path of the i-th rotated file, the current file for 0.
+func (l *Log) rotatedPath(i int) string {
+	if i == 0 {
+		return l.opts.Destination
+	}
+	return l.opts.Destination + "." + strconv.Itoa(i)
+}
+
+// readFile passes the entries of a file to fn. A missing file is empty and
+// lines that cannot be parsed, such as a partially written last line, are skipped.
+func readFile(path string, fn func(Entry)) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			fn(e)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,138 @@
+// Package cli implements the commands of the syscapture binary other than the servers.
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/promtext"
+)
+
+// Output formats of "syscapture collect"
+const (
+	formatJSON       = "json"
+	formatTable      = "table"
+	formatPrometheus = "prometheus"
+)
+
+// Collect runs "syscapture collect": it runs the collectors of the given groups
+// or fields once with collect, metric.CollectFiltered outside of tests, all of
+// them by default, and prints the metrics. It returns the exit status: 1 when
+// a collector failed or no disk matches the requested devices, 2 for invalid
+// arguments.
+func Collect(args []string, stdout io.Writer, stderr io.Writer, collect func(metric.Filter) (metric.AllMetrics, []metric.CustomErr)) int {
+	flags := flag.NewFlagSet("collect", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", formatJSON, "Output format: json, table or prometheus")
+	device := flags.String("device", "", "Comma separated disk devices to collect, e.g. /dev/sda1, all of them when empty")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, `Usage: syscapture collect [flags] [cpu|memory|disk|host|<group>.<field>]...
+
+Runs the collectors once and prints the metrics, all of them without arguments.
+Exits with status 1 when a collector fails, the metrics it could collect being printed.
+
+Flags:
+`)
+		flags.PrintDefaults()
+	}
+
+	// Flags may also follow the collectors, e.g. "collect disk -format table"
+	var targets []string
+	for rest := args; ; rest = flags.Args()[1:] {
+		if err := flags.Parse(rest); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			return 2
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		targets = append(targets, flags.Arg(0))
+	}
+
+	if *format != formatJSON && *format != formatTable && *format != formatPrometheus {
+		fmt.Fprintf(stderr, "Unsupported format %q, use json, table or prometheus\n", *format)
+		return 2
+	}
+	filter, err := metric.ParseFilter("", targets, []string{*device})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v, use cpu, memory, disk, host or one of their fields such as cpu.usage_percent\n", err)
+		return 2
+	}
+
+	m, errs := collect(filter)
+	if filter.HasDevices() && filter.Includes("disk") && len(m.Disk) == 0 {
+		fmt.Fprintln(stderr, "No disk matches the requested devices")
+		return 1
+	}
+	errs = filter.Errors(errs)
+
+	if err := printMetrics(stdout, *format, filter, m, errs); err != nil {
+		fmt.Fprintf(stderr, "Unable to print the metrics: %v\n", err)
+		return 1
+	}
+
+	for _, e := range errs {
+		fmt.Fprintf(stderr, "%s: %s\n", strings.Join(e.Metric, ", "), e.Error)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printMetrics writes the requested part of m to w in the given format
+func printMetrics(w io.Writer, format string, filter metric.Filter, m metric.AllMetrics, errs []metric.CustomErr) error {
+	switch format {
+	case formatTable:
+		return printTable(w, filter, m)
+	case formatPrometheus:
+		b := promtext.AppendSamples(nil, filter.Samples(m))
+		b = promtext.AppendInfo(b, "host.info", filter.HostInfo(m.Host))
+		_, err := w.Write(b)
+		return err
+	default:
+		// The same document GET /api/v1/metrics responds with
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(metric.APIResponse{
+			Data:   filter.Select(m),
+			Errors: errs,
+		})
+	}
+}
+
+// printTable writes one row per host field and sample
+func printTable(w io.Writer, filter metric.Filter, m metric.AllMetrics) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "METRIC\tLABELS\tVALUE")
+
+	info := filter.HostInfo(m.Host)
+	fields := make([]string, 0, len(info))
+	for field := range info {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		fmt.Fprintf(table, "host.%s\t\t%s\n", field, info[field])
+	}
+
+	for _, s := range filter.Samples(m) {
+		labels := make([]string, 0, len(s.Labels))
+		for k, v := range s.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		fmt.Fprintf(table, "%s\t%s\t%s\n", s.Name, strings.Join(labels, ","), strconv.FormatFloat(s.Value, 'f', -1, 64))
+	}
+	return table.Flush()
+}
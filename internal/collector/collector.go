@@ -0,0 +1,235 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+// DefaultBufferSize is the number of recent snapshots kept in memory.
+const DefaultBufferSize = 360
+
+// subscriberBuffer is the number of snapshots queued for a slow subscriber before new ones are dropped.
+const subscriberBuffer = 8
+
+// Snapshot represents the result of a single collection pass.
+type Snapshot struct {
+	ID        uint64 // Increasing identifier, the collection time in unix milliseconds
+	Timestamp time.Time
+	Metrics   metric.AllMetrics
+	Errors    []metric.CustomErr
+	Filter    metric.Filter // Filter of the collection, the groups it leaves out are empty
+}
+
+// Samples returns the samples of the collected fields and disks.
+func (s Snapshot) Samples() []metric.Sample {
+	return s.Filter.Samples(s.Metrics)
+}
+
+// Sink receives every snapshot produced by the Collector.
+type Sink interface {
+	Consume(Snapshot)
+}
+
+// SinkFunc is an adapter to allow the use of ordinary functions as sinks.
+type SinkFunc func(Snapshot)
+
+// Consume calls f(s).
+func (f SinkFunc) Consume(s Snapshot) {
+	f(s)
+}
+
+// AnnotateFunc adds values derived from the collected metrics, such as disk
+// forecasts, to a collection before it is buffered and handed to the sinks.
+type AnnotateFunc func(ts time.Time, m *metric.AllMetrics)
+
+// Collector periodically collects all system metrics and hands them to its sinks.
+// The most recent snapshots are kept in a ring buffer so clients can catch up on missed ones.
+type Collector struct {
+	interval time.Duration
+	collect  func() (metric.AllMetrics, []metric.CustomErr)
+
+	mu          sync.RWMutex
+	annotators  []AnnotateFunc
+	sinks       []Sink
+	subscribers map[chan Snapshot]struct{}
+	filter      metric.Filter
+	buffer      []Snapshot // Ring buffer of recent snapshots
+	next        int        // Position of the next write in buffer
+	lastID      uint64
+}
+
+// New creates a Collector that collects the system metrics selected by its
+// filter, all of them by default, every interval and keeps the last bufferSize
+// snapshots in memory.
+func New(interval time.Duration, bufferSize int) *Collector {
+	c := NewWithFunc(interval, bufferSize, nil)
+	c.collect = func() (metric.AllMetrics, []metric.CustomErr) {
+		return metric.CollectFiltered(c.Filter())
+	}
+	return c
+}
+
+// NewWithFunc creates a Collector that gets its metrics from collect instead of
+// collecting the system metrics, e.g. in tests.
+func NewWithFunc(interval time.Duration, bufferSize int, collect func() (metric.AllMetrics, []metric.CustomErr)) *Collector {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &Collector{
+		interval:    interval,
+		collect:     collect,
+		subscribers: make(map[chan Snapshot]struct{}),
+		buffer:      make([]Snapshot, 0, bufferSize),
+	}
+}
+
+// AddSink registers a sink. Sinks are called sequentially from the collection loop.
+func (c *Collector) AddSink(s Sink) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sinks = append(c.sinks, s)
+}
+
+// SetFilter restricts the following collections to the fields and disks
+// selected by f. The zero Filter collects everything.
+func (c *Collector) SetFilter(f metric.Filter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.filter = f
+}
+
+// Filter returns the filter of the collections.
+func (c *Collector) Filter() metric.Filter {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.filter
+}
+
+// AddAnnotator registers fn. Annotators are called sequentially, in the order
+// they were added, before the snapshot is dispatched.
+func (c *Collector) AddAnnotator(fn AnnotateFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.annotators = append(c.annotators, fn)
+}
+
+// Subscribe returns a channel receiving every new snapshot and a function that
+// cancels the subscription. Snapshots are dropped while the channel is full.
+func (c *Collector) Subscribe() (<-chan Snapshot, func()) {
+	ch := make(chan Snapshot, subscriberBuffer)
+
+	c.mu.Lock()
+	c.subscribers[ch] = struct{}{}
+	c.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			c.mu.Lock()
+			delete(c.subscribers, ch)
+			c.mu.Unlock()
+		})
+	}
+}
+
+// Latest returns the most recent snapshot, if any has been collected yet.
+func (c *Collector) Latest() (Snapshot, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.lastID == 0 {
+		return Snapshot{}, false
+	}
+	return c.buffer[(c.next-1+len(c.buffer))%len(c.buffer)], true
+}
+
+// Since returns the buffered snapshots with an ID greater than id, oldest first.
+func (c *Collector) Since(id uint64) []Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var snapshots []Snapshot
+	for i := 0; i < len(c.buffer); i++ {
+		s := c.buffer[(c.next+i)%len(c.buffer)]
+		if s.ID > id {
+			snapshots = append(snapshots, s)
+		}
+	}
+	return snapshots
+}
+
+// Interval returns the collection interval.
+func (c *Collector) Interval() time.Duration {
+	return c.interval
+}
+
+// Run collects metrics every interval until ctx is cancelled.
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.collectOnce()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collectOnce runs a single collection pass and dispatches the snapshot.
+func (c *Collector) collectOnce() {
+	filter := c.Filter()
+	metrics, errs := c.collect()
+	errs = filter.Errors(errs)
+
+	now := time.Now()
+
+	c.mu.RLock()
+	annotators := c.annotators
+	c.mu.RUnlock()
+	for _, annotate := range annotators {
+		annotate(now, &metrics)
+	}
+
+	c.mu.Lock()
+	// Time based IDs keep increasing across restarts, so a client reconnecting after
+	// one is not sent events older than its last one. The snapshots collected before
+	// the restart are gone: only the in-memory buffer is replayed
+	c.lastID = max(c.lastID+1, uint64(now.UnixMilli()))
+	snapshot := Snapshot{
+		ID:        c.lastID,
+		Timestamp: now,
+		Metrics:   metrics,
+		Errors:    errs,
+		Filter:    filter,
+	}
+	if len(c.buffer) < cap(c.buffer) {
+		c.buffer = append(c.buffer, snapshot)
+		c.next = len(c.buffer) % cap(c.buffer)
+	} else {
+		c.buffer[c.next] = snapshot
+		c.next = (c.next + 1) % len(c.buffer)
+	}
+	sinks := c.sinks
+	for ch := range c.subscribers {
+		select {
+		case ch <- snapshot:
+		default:
+		}
+	}
+	c.mu.Unlock()
+
+	for _, s := range sinks {
+		s.Consume(snapshot)
+	}
+}
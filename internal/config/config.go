@@ -1,15 +1,136 @@
 package config
 
 import (
-	"github.com/sirupsen/logrus"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/listener"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/token"
 )
 
 type Config struct {
-	Port      string
-	APISecret string
+	Port            string
+	APISecret       string
+	TokensFile      string        // File holding the named API tokens
+	StoragePath     string        // Directory of the on-disk history, disabled when empty
+	CollectInterval time.Duration // Interval between background collections
+
+	AnomalyMetrics   []string // Metrics watched by the anomaly detector, disabled when empty
+	AnomalyThreshold float64  // Z-score above which a sample is anomalous
+	AnomalyAlpha     float64  // EWMA smoothing factor of the anomaly baselines
+
+	Collectors    []string      // Metric groups collected in the background, all of them when empty
+	FilterFields  []string      // Fields served when a request selects none, all collected ones when empty
+	FilterDevices []string      // Disk devices served when a request selects none, all of them when empty
+	Filter        metric.Filter // Filter combining Collectors, FilterFields and FilterDevices
+
+	problems []error // Invalid settings found by the setters, reported by Validate
+
+	Listen ListenConfig
+	TLS    TLSConfig
+	HMAC   HMACConfig
+	JWT    JWTConfig
+	Access AccessConfig
+	Audit  AuditConfig
+
+	RemoteWrite RemoteWriteConfig
+	Influx      InfluxConfig
+	OTLP        OTLPConfig
+	StatsD      StatsDConfig
+	Graphite    GraphiteConfig
+
+	Hub HubConfig
+}
+
+// ListenConfig holds the addresses the server listens on.
+type ListenConfig struct {
+	Addresses      []string    // TCP "host:port" or "unix:/path" addresses of the API
+	AdminAddresses []string    // Addresses serving only the health check and admin routes, which move off Addresses when set
+	GRPCAddresses  []string    // Addresses of the gRPC service, disabled when empty
+	SocketMode     fs.FileMode // Permissions of unix domain sockets
+	SocketGroup    string      // Group owning unix domain sockets
+}
+
+// AuditConfig holds the settings of the audit log.
+type AuditConfig struct {
+	Destination string // File path or "syslog", the audit log is disabled when empty
+	MaxSize     int64  // File size in bytes at which the file is rotated
+	MaxFiles    int    // Rotated files that are kept
+}
+
+// AccessConfig holds the client IP restrictions and rate limits.
+type AccessConfig struct {
+	TrustedProxies []string       // Proxies whose X-Forwarded-For header is trusted
+	Allow          []netip.Prefix // Networks allowed to use the API, everyone when empty
+	Deny           []netip.Prefix // Networks denied to use the API
+	AdminAllow     []netip.Prefix // Networks allowed to manage tokens, everyone when empty
+	AdminDeny      []netip.Prefix // Networks denied to manage tokens
+	IPRate         float64        // Requests per second per client IP, unlimited when 0
+	TokenRate      float64        // Requests per second per token, unlimited when 0
+	BanThreshold   int            // Failed authentications within BanWindow that ban a client, never when 0
+	BanWindow      time.Duration  // Window in which failed authentications are counted
+	BanDuration    time.Duration  // Length of a ban
+}
+
+// JWTConfig holds the settings of JWT bearer token verification.
+type JWTConfig struct {
+	JWKSFile    string              // JSON Web Key Set, JWT verification is enabled when set
+	Issuer      string              // Required "iss" claim
+	Audience    string              // Required member of the "aud" claim
+	Leeway      time.Duration       // Tolerated clock difference for "exp" and "nbf"
+	ScopesClaim string              // Claim holding the scopes
+	ScopeMap    map[string][]string // Claim values to scopes, claim values are used as scopes when empty
 }
 
-const defaultPort = "42000"
+// HMACConfig holds the shared keys of HMAC-signed requests.
+type HMACConfig struct {
+	Keys    map[string]string   // Key id to shared key
+	Scopes  map[string][]string // Key id to granted scopes
+	MaxSkew time.Duration       // Maximum difference between the request timestamp and the server clock
+}
+
+// TLSConfig holds the settings of the native HTTPS listener.
+type TLSConfig struct {
+	CertFile     string              // Certificate chain, HTTPS is enabled when set
+	KeyFile      string              // Private key
+	MinVersion   string              // Minimum TLS version (1.2 or 1.3)
+	CipherSuites []string            // Allowed TLS 1.2 cipher suites
+	ClientCAFile string              // CA bundle verifying client certificates
+	ClientAuth   string              // Client certificate mode (none, optional or require)
+	ClientScopes map[string][]string // Client certificate CN/SAN to granted scopes
+}
+
+// Enabled reports whether the HTTPS listener is configured.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+const (
+	defaultPort            = "42000"
+	defaultCollectInterval = 10 * time.Second
+	minCollectInterval     = time.Second
+
+	defaultAnomalyThreshold = 3.0
+	defaultAnomalyAlpha     = 0.1
+
+	defaultHMACSkew  = 5 * time.Minute
+	minHMACKeyLength = 32
+
+	defaultBanWindow   = 10 * time.Minute
+	defaultBanDuration = 15 * time.Minute
+
+	defaultAuditMaxSizeMB = 100
+	defaultAuditMaxFiles  = 5
+
+	defaultJWTLeeway = 30 * time.Second
+	maxJWTLeeway     = 5 * time.Minute
+)
 
 // NewConfig initializes a new Config struct with the provided values
 func NewConfig(port string, apiSecret string) *Config {
@@ -17,21 +138,400 @@ func NewConfig(port string, apiSecret string) *Config {
 		port = defaultPort
 	}
 
-	// Validate required fields
-	if apiSecret == "" {
-		logrus.Fatalln("API_SECRET environment variable is required for security purposes. Please set it before starting the server.")
+	return &Config{
+		Port:             port,
+		APISecret:        apiSecret,
+		CollectInterval:  defaultCollectInterval,
+		AnomalyThreshold: defaultAnomalyThreshold,
+		AnomalyAlpha:     defaultAnomalyAlpha,
 	}
+}
 
-	return &Config{
-		Port:      port,
-		APISecret: apiSecret,
+// SetListen configures the listen addresses from comma separated lists. The
+// API listens on all interfaces on PORT when no address is given.
+func (c *Config) SetListen(addresses string, adminAddresses string, grpcAddresses string, socketMode string, socketGroup string) {
+	c.Listen = ListenConfig{
+		Addresses:      splitList(addresses, ","),
+		AdminAddresses: splitList(adminAddresses, ","),
+		GRPCAddresses:  splitList(grpcAddresses, ","),
+		SocketMode:     listener.DefaultSocketMode,
+		SocketGroup:    socketGroup,
+	}
+	if len(c.Listen.Addresses) == 0 {
+		c.Listen.Addresses = []string{":" + c.Port}
+	}
+
+	seen := make(map[string]bool)
+	for _, list := range []struct {
+		variable  string
+		addresses []string
+	}{
+		{"LISTEN", c.Listen.Addresses},
+		{"ADMIN_LISTEN", c.Listen.AdminAddresses},
+		{"GRPC_LISTEN", c.Listen.GRPCAddresses},
+	} {
+		for _, address := range list.addresses {
+			if err := listener.Validate(address); err != nil {
+				c.fail("%s address %q is invalid: %v", list.variable, address, err)
+			}
+			if seen[address] {
+				c.fail("%s address %q is used twice", list.variable, address)
+			}
+			seen[address] = true
+		}
+	}
+
+	if socketMode != "" {
+		mode, err := strconv.ParseUint(socketMode, 8, 32)
+		if err != nil || mode > 0o777 {
+			c.fail("SOCKET_MODE must be an octal permission such as '0660'")
+		} else {
+			c.Listen.SocketMode = fs.FileMode(mode)
+		}
+	}
+}
+
+// SetTokens configures the file holding the named API tokens.
+func (c *Config) SetTokens(file string) {
+	c.TokensFile = file
+}
+
+// SetTLS configures the native HTTPS listener.
+// Client scopes are given as "identity=scope,scope;identity=scope".
+func (c *Config) SetTLS(certFile, keyFile, minVersion, cipherSuites, clientCAFile, clientAuth, clientScopes string) {
+	c.TLS = TLSConfig{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		MinVersion:   minVersion,
+		CipherSuites: splitList(cipherSuites, ","),
+		ClientCAFile: clientCAFile,
+		ClientAuth:   clientAuth,
+		ClientScopes: make(map[string][]string),
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		c.fail("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if clientCAFile != "" && certFile == "" {
+		c.fail("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+
+	for identity, scopes := range c.parseMapping("TLS_CLIENT_SCOPES", clientScopes, "identity=scope,scope") {
+		c.TLS.ClientScopes[identity] = splitList(scopes, ",")
+	}
+	if len(c.TLS.ClientScopes) > 0 && clientCAFile == "" {
+		c.fail("TLS_CLIENT_SCOPES requires TLS_CLIENT_CA_FILE")
+	}
+}
+
+// SetAccess configures the client IP restrictions and rate limits. Networks are
+// comma separated CIDRs or addresses. The per-IP rate limit and the bans are off
+// unless configured: without TRUSTED_PROXIES, clients behind a proxy or on a unix
+// socket share one address and would be limited and banned together. Empty ban
+// window and duration keep their defaults.
+func (c *Config) SetAccess(trustedProxies, allow, deny, adminAllow, adminDeny, ipRate, tokenRate, banThreshold, banWindow, banDuration string) {
+	c.Access = AccessConfig{
+		TrustedProxies: splitList(trustedProxies, ","),
+		Allow:          c.parsePrefixes("IP_ALLOW", allow),
+		Deny:           c.parsePrefixes("IP_DENY", deny),
+		AdminAllow:     c.parsePrefixes("ADMIN_IP_ALLOW", adminAllow),
+		AdminDeny:      c.parsePrefixes("ADMIN_IP_DENY", adminDeny),
+		BanWindow:      defaultBanWindow,
+		BanDuration:    defaultBanDuration,
+	}
+	c.parsePrefixes("TRUSTED_PROXIES", trustedProxies)
+
+	if ipRate != "" {
+		c.Access.IPRate = c.parseRate("RATE_LIMIT_IP", ipRate)
+	}
+	if tokenRate != "" {
+		c.Access.TokenRate = c.parseRate("RATE_LIMIT_TOKEN", tokenRate)
+	}
+
+	if banThreshold != "" {
+		n, err := strconv.Atoi(banThreshold)
+		if err != nil || n < 0 {
+			c.fail("AUTH_BAN_THRESHOLD must be a non-negative integer")
+		} else {
+			c.Access.BanThreshold = n
+		}
+	}
+	if banWindow != "" {
+		c.Access.BanWindow = c.parsePositiveDuration("AUTH_BAN_WINDOW", banWindow, defaultBanWindow)
+	}
+	if banDuration != "" {
+		c.Access.BanDuration = c.parsePositiveDuration("AUTH_BAN_DURATION", banDuration, defaultBanDuration)
+	}
+}
+
+// SetAudit configures the audit log. The maximum size is given in megabytes.
+// Empty size and file count values keep their defaults.
+func (c *Config) SetAudit(destination string, maxSizeMB string, maxFiles string) {
+	c.Audit = AuditConfig{
+		Destination: destination,
+		MaxSize:     defaultAuditMaxSizeMB << 20,
+		MaxFiles:    defaultAuditMaxFiles,
+	}
+
+	if maxSizeMB != "" {
+		n, err := strconv.Atoi(maxSizeMB)
+		if err != nil || n < 1 {
+			c.fail("AUDIT_LOG_MAX_SIZE must be a positive number of megabytes")
+		} else {
+			c.Audit.MaxSize = int64(n) << 20
+		}
+	}
+	if maxFiles != "" {
+		n, err := strconv.Atoi(maxFiles)
+		if err != nil || n < 1 {
+			c.fail("AUDIT_LOG_MAX_FILES must be a positive integer")
+		} else {
+			c.Audit.MaxFiles = n
+		}
+	}
+}
+
+// SetHMAC configures the shared keys of HMAC-signed requests.
+// Keys are given as "id=key;id=key" and scopes as "id=scope,scope;id=scope".
+// Keys without scopes are granted metrics:read. An empty skew keeps the default of 5 minutes.
+func (c *Config) SetHMAC(keys string, scopes string, maxSkew string) {
+	c.HMAC = HMACConfig{
+		Keys:    c.parseMapping("HMAC_KEYS", keys, "id=key"),
+		Scopes:  make(map[string][]string),
+		MaxSkew: defaultHMACSkew,
+	}
+
+	for id, key := range c.HMAC.Keys {
+		if len(key) < minHMACKeyLength {
+			c.fail("HMAC_KEYS key %q must be at least %d characters long", id, minHMACKeyLength)
+		}
+		c.HMAC.Scopes[id] = []string{token.ScopeMetricsRead}
+	}
+	for id, granted := range c.parseMapping("HMAC_KEY_SCOPES", scopes, "id=scope,scope") {
+		if _, ok := c.HMAC.Keys[id]; !ok {
+			c.fail("HMAC_KEY_SCOPES refers to unknown key %q", id)
+		}
+		c.HMAC.Scopes[id] = splitList(granted, ",")
+	}
+
+	if maxSkew != "" {
+		d, err := time.ParseDuration(maxSkew)
+		if err != nil || d <= 0 {
+			c.fail("HMAC_MAX_SKEW must be a positive duration such as '5m'")
+		} else {
+			c.HMAC.MaxSkew = d
+		}
+	}
+}
+
+// SetJWT configures JWT bearer token verification.
+// The scope map is given as "claim value=scope,scope;claim value=scope".
+// Empty leeway and scopes claim values keep their defaults.
+func (c *Config) SetJWT(jwksFile, issuer, audience, leeway, scopesClaim, scopeMap string) {
+	c.JWT = JWTConfig{
+		JWKSFile:    jwksFile,
+		Issuer:      issuer,
+		Audience:    audience,
+		Leeway:      defaultJWTLeeway,
+		ScopesClaim: scopesClaim,
+		ScopeMap:    make(map[string][]string),
+	}
+	if jwksFile == "" {
+		return
+	}
+
+	if issuer == "" || audience == "" {
+		c.fail("JWT_ISSUER and JWT_AUDIENCE are required when JWT_JWKS_FILE is set")
+	}
+	if leeway != "" {
+		d, err := time.ParseDuration(leeway)
+		if err != nil || d < 0 || d > maxJWTLeeway {
+			c.fail("JWT_LEEWAY must be a duration between 0s and %s", maxJWTLeeway)
+		} else {
+			c.JWT.Leeway = d
+		}
+	}
+	for value, scopes := range c.parseMapping("JWT_SCOPE_MAP", scopeMap, "claim value=scope,scope") {
+		c.JWT.ScopeMap[value] = splitList(scopes, ",")
+	}
+}
+
+// Validate checks the required fields and returns every problem found by the setters.
+func (c *Config) Validate() error {
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		c.fail("PORT must be a number between 1 and 65535")
+	}
+	if c.APISecret == "" && c.TokensFile == "" && len(c.TLS.ClientScopes) == 0 && len(c.HMAC.Keys) == 0 && c.JWT.JWKSFile == "" {
+		c.fail("API_SECRET or TOKENS_FILE environment variable is required for security purposes. Please set it before starting the server.")
+	}
+	return errors.Join(c.problems...)
+}
+
+// SetStorage configures the on-disk history storage.
+// An empty interval keeps the default collection interval.
+func (c *Config) SetStorage(path string, interval string) {
+	c.StoragePath = path
+
+	if interval == "" {
+		return
+	}
+
+	d, err := time.ParseDuration(interval)
+	switch {
+	case err != nil:
+		c.fail("COLLECT_INTERVAL must be a duration such as '10s': %v", err)
+	case d < minCollectInterval:
+		c.fail("COLLECT_INTERVAL must be at least %s", minCollectInterval)
+	default:
+		c.CollectInterval = d
+	}
+}
+
+// SetAnomaly configures the anomaly detector from a comma separated list of metric names.
+// Empty threshold or alpha values keep their defaults.
+func (c *Config) SetAnomaly(metrics string, threshold string, alpha string) {
+	c.AnomalyMetrics = splitList(metrics, ",")
+
+	if threshold != "" {
+		t, err := strconv.ParseFloat(threshold, 64)
+		if err != nil || t <= 0 {
+			c.fail("ANOMALY_THRESHOLD must be a positive number")
+		} else {
+			c.AnomalyThreshold = t
+		}
+	}
+
+	if alpha != "" {
+		a, err := strconv.ParseFloat(alpha, 64)
+		if err != nil || a <= 0 || a > 1 {
+			c.fail("ANOMALY_ALPHA must be a number between 0 (exclusive) and 1")
+		} else {
+			c.AnomalyAlpha = a
+		}
 	}
 }
 
+// SetCollect configures the metric groups collected in the background from a
+// comma separated list such as "cpu,memory", and the fields and disk devices
+// served by default, in the format of the 'fields' and 'device' query
+// parameters. The default fields must belong to the collected groups.
+func (c *Config) SetCollect(collectors string, fields string, devices string) {
+	c.Collectors = splitList(collectors, ",")
+	c.FilterFields = splitList(fields, ",")
+	c.FilterDevices = splitList(devices, ",")
+
+	collected := make(map[string]bool, len(c.Collectors))
+	for _, group := range c.Collectors {
+		if _, err := metric.ParseFilter(group, nil, nil); err != nil {
+			c.fail("COLLECTORS: %v, use cpu, memory, disk or host", err)
+			return
+		}
+		collected[group] = true
+	}
+
+	requested := c.FilterFields
+	if len(requested) == 0 {
+		requested = c.Collectors
+	}
+	filter, err := metric.ParseFilter("", requested, c.FilterDevices)
+	if err != nil {
+		c.fail("FILTER_FIELDS: %v", err)
+		return
+	}
+	for _, field := range c.FilterFields {
+		if group, _, _ := strings.Cut(field, "."); len(collected) > 0 && !collected[group] {
+			c.fail("FILTER_FIELDS: field %q is not collected, add %s to COLLECTORS", field, group)
+			return
+		}
+	}
+	if filter.HasDevices() && !filter.Includes("disk") {
+		c.fail("FILTER_DEVICE only applies to disk metrics, which are not collected")
+		return
+	}
+	c.Filter = filter
+}
+
 // Default returns a Config struct with default values
 func Default() *Config {
 	return &Config{
-		Port:      defaultPort,
-		APISecret: "",
+		Port:             defaultPort,
+		APISecret:        "",
+		CollectInterval:  defaultCollectInterval,
+		AnomalyThreshold: defaultAnomalyThreshold,
+		AnomalyAlpha:     defaultAnomalyAlpha,
+		Listen: ListenConfig{
+			Addresses:  []string{":" + defaultPort},
+			SocketMode: listener.DefaultSocketMode,
+		},
+	}
+}
+
+// splitList splits a separated list, trimming spaces and dropping empty entries.
+func splitList(value string, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// fail records a problem found while configuring. All problems are reported together by Validate.
+func (c *Config) fail(format string, args ...any) {
+	c.problems = append(c.problems, fmt.Errorf(format, args...))
+}
+
+// parseMapping parses a list of "name=value" entries separated by semicolons.
+// The format is shown in the error message of malformed entries.
+func (c *Config) parseMapping(variable string, value string, format string) map[string]string {
+	mapping := make(map[string]string)
+	for _, entry := range splitList(value, ";") {
+		name, v, found := strings.Cut(entry, "=")
+		name, v = strings.TrimSpace(name), strings.TrimSpace(v)
+		if !found || name == "" || v == "" {
+			c.fail("%s entry %q must look like '%s'", variable, entry, format)
+			continue
+		}
+		mapping[name] = v
+	}
+	return mapping
+}
+
+// parsePrefixes parses a comma separated list of CIDRs or addresses.
+func (c *Config) parsePrefixes(variable string, value string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, item := range splitList(value, ",") {
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				c.fail("%s entry %q must be a CIDR such as '10.0.0.0/8' or an address", variable, item)
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+// parseRate parses a non-negative number of requests per second.
+func (c *Config) parseRate(variable string, value string) float64 {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 {
+		c.fail("%s must be a non-negative number of requests per second", variable)
+		return 0
+	}
+	return rate
+}
+
+// parsePositiveDuration parses a duration greater than zero, returning fallback if it is invalid.
+func (c *Config) parsePositiveDuration(variable string, value string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		c.fail("%s must be a positive duration such as '10m'", variable)
+		return fallback
 	}
+	return d
 }
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/emit"
+)
+
+// RemoteWriteConfig holds the settings of the Prometheus remote_write exporter.
+type RemoteWriteConfig struct {
+	URL            string            // Endpoint, the exporter is disabled when empty
+	Username       string            // Basic auth user
+	Password       string            // Basic auth password
+	BearerToken    string            // Bearer token, instead of basic auth
+	ExternalLabels map[string]string // Labels added to every series
+	Interval       time.Duration     // Interval between pushes
+	QueueDir       string            // Directory of the on-disk queue, in memory when empty
+	QueueSize      int               // Collections kept while the endpoint is unreachable
+}
+
+// InfluxConfig holds the settings of the InfluxDB v2 pusher.
+type InfluxConfig struct {
+	URL           string            // Base URL of the server, the pusher is disabled when empty
+	Org           string            // Organization
+	Bucket        string            // Bucket
+	Token         string            // API token
+	Tags          map[string]string // Tags added to every point
+	BatchSize     int               // Lines written in one request
+	FlushInterval time.Duration     // Interval between writes
+}
+
+// OTLPConfig holds the settings of the OTLP/HTTP exporter.
+type OTLPConfig struct {
+	Endpoint           string            // Base URL of the receiver, the exporter is disabled when empty
+	Protocol           string            // "http/protobuf" or "http/json"
+	Headers            map[string]string // Headers sent with every export
+	ResourceAttributes map[string]string // Resource attributes overriding the detected ones
+	Interval           time.Duration     // Interval between exports
+}
+
+// StatsDConfig holds the settings of the StatsD emitter.
+type StatsDConfig struct {
+	Address  string // UDP "host:port" of the server, the emitter is disabled when empty
+	Template string // Metric path template
+}
+
+// GraphiteConfig holds the settings of the Graphite emitter.
+type GraphiteConfig struct {
+	Address    string // TCP "host:port" of the Carbon plaintext receiver, the emitter is disabled when empty
+	Template   string // Metric path template
+	BufferSize int    // Lines kept while the server is unreachable
+}
+
+const (
+	defaultRemoteWriteInterval  = 30 * time.Second
+	defaultRemoteWriteQueueSize = 8640
+
+	defaultInfluxBatchSize     = 5000
+	defaultInfluxFlushInterval = 30 * time.Second
+
+	defaultOTLPProtocol = "http/protobuf"
+	defaultOTLPInterval = 30 * time.Second
+
+	defaultGraphiteBufferSize = 100000
+)
+
+// labelNamePattern matches valid Prometheus label names.
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// SetRemoteWrite configures the Prometheus remote_write exporter.
+// External labels are given as "name=value;name=value".
+func (c *Config) SetRemoteWrite(endpoint, username, password, bearerToken, externalLabels, interval, queueDir, queueSize string) {
+	c.RemoteWrite = RemoteWriteConfig{
+		URL:            endpoint,
+		Username:       username,
+		Password:       password,
+		BearerToken:    bearerToken,
+		ExternalLabels: c.parseMapping("REMOTE_WRITE_EXTERNAL_LABELS", externalLabels, "name=value"),
+		Interval:       defaultRemoteWriteInterval,
+		QueueDir:       queueDir,
+		QueueSize:      defaultRemoteWriteQueueSize,
+	}
+
+	if endpoint != "" {
+		c.checkURL("REMOTE_WRITE_URL", endpoint)
+	}
+	if bearerToken != "" && username != "" {
+		c.fail("REMOTE_WRITE_BEARER_TOKEN and REMOTE_WRITE_USERNAME cannot be used together")
+	}
+	for name := range c.RemoteWrite.ExternalLabels {
+		if !labelNamePattern.MatchString(name) || name == "__name__" {
+			c.fail("REMOTE_WRITE_EXTERNAL_LABELS name %q is not a valid label name", name)
+		}
+	}
+	if interval != "" {
+		c.RemoteWrite.Interval = c.parsePositiveDuration("REMOTE_WRITE_INTERVAL", interval, defaultRemoteWriteInterval)
+	}
+	if queueSize != "" {
+		n, err := strconv.Atoi(queueSize)
+		if err != nil || n < 1 {
+			c.fail("REMOTE_WRITE_QUEUE_SIZE must be a positive number of collections")
+		} else {
+			c.RemoteWrite.QueueSize = n
+		}
+	}
+}
+
+// SetInflux configures the InfluxDB v2 pusher. Tags are given as "name=value;name=value".
+func (c *Config) SetInflux(endpoint, org, bucket, apiToken, tags, batchSize, flushInterval string) {
+	c.Influx = InfluxConfig{
+		URL:           endpoint,
+		Org:           org,
+		Bucket:        bucket,
+		Token:         apiToken,
+		Tags:          c.parseMapping("INFLUX_TAGS", tags, "name=value"),
+		BatchSize:     defaultInfluxBatchSize,
+		FlushInterval: defaultInfluxFlushInterval,
+	}
+
+	if endpoint != "" {
+		c.checkURL("INFLUX_URL", endpoint)
+		if org == "" || bucket == "" {
+			c.fail("INFLUX_ORG and INFLUX_BUCKET are required with INFLUX_URL")
+		}
+	}
+	if batchSize != "" {
+		n, err := strconv.Atoi(batchSize)
+		if err != nil || n < 1 {
+			c.fail("INFLUX_BATCH_SIZE must be a positive number of lines")
+		} else {
+			c.Influx.BatchSize = n
+		}
+	}
+	if flushInterval != "" {
+		c.Influx.FlushInterval = c.parsePositiveDuration("INFLUX_FLUSH_INTERVAL", flushInterval, defaultInfluxFlushInterval)
+	}
+}
+
+// SetOTLP configures the OTLP/HTTP exporter. Headers and resource attributes
+// are given as "name=value;name=value".
+func (c *Config) SetOTLP(endpoint, protocol, headers, resourceAttributes, interval string) {
+	c.OTLP = OTLPConfig{
+		Endpoint:           endpoint,
+		Protocol:           defaultOTLPProtocol,
+		Headers:            c.parseMapping("OTLP_HEADERS", headers, "name=value"),
+		ResourceAttributes: c.parseMapping("OTLP_RESOURCE_ATTRIBUTES", resourceAttributes, "key=value"),
+		Interval:           defaultOTLPInterval,
+	}
+
+	if endpoint != "" {
+		c.checkURL("OTLP_ENDPOINT", endpoint)
+	}
+	switch protocol {
+	case "":
+	case "http/protobuf", "http/json":
+		c.OTLP.Protocol = protocol
+	default:
+		c.fail("OTLP_PROTOCOL must be http/protobuf or http/json")
+	}
+	if interval != "" {
+		c.OTLP.Interval = c.parsePositiveDuration("OTLP_INTERVAL", interval, defaultOTLPInterval)
+	}
+}
+
+// SetStatsD configures the StatsD emitter.
+func (c *Config) SetStatsD(address, template string) {
+	c.StatsD = StatsDConfig{Address: address, Template: emit.DefaultTemplate}
+
+	if address != "" {
+		c.checkHostPort("STATSD_ADDRESS", address)
+	}
+	if template != "" {
+		c.StatsD.Template = template
+		c.checkTemplate("STATSD_TEMPLATE", template)
+	}
+}
+
+// SetGraphite configures the Graphite emitter.
+func (c *Config) SetGraphite(address, template, bufferSize string) {
+	c.Graphite = GraphiteConfig{Address: address, Template: emit.DefaultTemplate, BufferSize: defaultGraphiteBufferSize}
+
+	if address != "" {
+		c.checkHostPort("GRAPHITE_ADDRESS", address)
+	}
+	if template != "" {
+		c.Graphite.Template = template
+		c.checkTemplate("GRAPHITE_TEMPLATE", template)
+	}
+	if bufferSize != "" {
+		n, err := strconv.Atoi(bufferSize)
+		if err != nil || n < 1 {
+			c.fail("GRAPHITE_BUFFER_SIZE must be a positive number of lines")
+		} else {
+			c.Graphite.BufferSize = n
+		}
+	}
+}
+
+// checkHostPort records a problem if value is not a "host:port" address.
+func (c *Config) checkHostPort(variable string, value string) {
+	if host, port, err := net.SplitHostPort(value); err != nil || host == "" || port == "" {
+		c.fail("%s must look like 'host:port'", variable)
+	}
+}
+
+// checkTemplate records a problem if value is not a valid metric path template.
+func (c *Config) checkTemplate(variable string, value string) {
+	if _, err := emit.ParseTemplate(value); err != nil {
+		c.fail("%s: %v", variable, err)
+	}
+}
+
+// checkURL records a problem if value is not an absolute http or https URL.
+func (c *Config) checkURL(variable string, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.fail("%s must be an http:// or https:// URL", variable)
+	}
+}
@@ -0,0 +1,556 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the layout of the configuration file. Every setting has an
+// environment variable of the same meaning, which overrides the file value.
+type fileConfig struct {
+	Port        int      `yaml:"port" toml:"port"`
+	Listen      []string `yaml:"listen" toml:"listen"`
+	AdminListen []string `yaml:"admin_listen" toml:"admin_listen"`
+	GRPCListen  []string `yaml:"grpc_listen" toml:"grpc_listen"`
+	SocketMode  string   `yaml:"socket_mode" toml:"socket_mode"`
+	SocketGroup string   `yaml:"socket_group" toml:"socket_group"`
+
+	Auth    fileAuth    `yaml:"auth" toml:"auth"`
+	TLS     fileTLS     `yaml:"tls" toml:"tls"`
+	Access  fileAccess  `yaml:"access" toml:"access"`
+	Audit   fileAudit   `yaml:"audit" toml:"audit"`
+	Storage fileStorage `yaml:"storage" toml:"storage"`
+	Anomaly fileAnomaly `yaml:"anomaly" toml:"anomaly"`
+
+	Collectors []string   `yaml:"collectors" toml:"collectors"`
+	Filter     fileFilter `yaml:"filter" toml:"filter"`
+
+	RemoteWrite fileRemoteWrite `yaml:"remote_write" toml:"remote_write"`
+	Influx      fileInflux      `yaml:"influx" toml:"influx"`
+	OTLP        fileOTLP        `yaml:"otlp" toml:"otlp"`
+	StatsD      fileStatsD      `yaml:"statsd" toml:"statsd"`
+	Graphite    fileGraphite    `yaml:"graphite" toml:"graphite"`
+
+	Hub fileHub `yaml:"hub" toml:"hub"`
+}
+
+type fileAuth struct {
+	APISecret     string   `yaml:"api_secret" toml:"api_secret"`
+	APISecretFile string   `yaml:"api_secret_file" toml:"api_secret_file"`
+	TokensFile    string   `yaml:"tokens_file" toml:"tokens_file"`
+	HMAC          fileHMAC `yaml:"hmac" toml:"hmac"`
+	JWT           fileJWT  `yaml:"jwt" toml:"jwt"`
+}
+
+type fileHMAC struct {
+	Keys      map[string]string   `yaml:"keys" toml:"keys"`
+	KeysFile  string              `yaml:"keys_file" toml:"keys_file"`
+	KeyScopes map[string][]string `yaml:"key_scopes" toml:"key_scopes"`
+	MaxSkew   string              `yaml:"max_skew" toml:"max_skew"`
+}
+
+type fileJWT struct {
+	JWKSFile    string              `yaml:"jwks_file" toml:"jwks_file"`
+	Issuer      string              `yaml:"issuer" toml:"issuer"`
+	Audience    string              `yaml:"audience" toml:"audience"`
+	Leeway      string              `yaml:"leeway" toml:"leeway"`
+	ScopesClaim string              `yaml:"scopes_claim" toml:"scopes_claim"`
+	ScopeMap    map[string][]string `yaml:"scope_map" toml:"scope_map"`
+}
+
+type fileTLS struct {
+	CertFile     string              `yaml:"cert_file" toml:"cert_file"`
+	KeyFile      string              `yaml:"key_file" toml:"key_file"`
+	MinVersion   string              `yaml:"min_version" toml:"min_version"`
+	CipherSuites []string            `yaml:"cipher_suites" toml:"cipher_suites"`
+	ClientCAFile string              `yaml:"client_ca_file" toml:"client_ca_file"`
+	ClientAuth   string              `yaml:"client_auth" toml:"client_auth"`
+	ClientScopes map[string][]string `yaml:"client_scopes" toml:"client_scopes"`
+}
+
+type fileAccess struct {
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+	Allow          []string `yaml:"allow" toml:"allow"`
+	Deny           []string `yaml:"deny" toml:"deny"`
+	AdminAllow     []string `yaml:"admin_allow" toml:"admin_allow"`
+	AdminDeny      []string `yaml:"admin_deny" toml:"admin_deny"`
+	RateLimitIP    *float64 `yaml:"rate_limit_ip" toml:"rate_limit_ip"`
+	RateLimitToken *float64 `yaml:"rate_limit_token" toml:"rate_limit_token"`
+	BanThreshold   *int     `yaml:"ban_threshold" toml:"ban_threshold"`
+	BanWindow      string   `yaml:"ban_window" toml:"ban_window"`
+	BanDuration    string   `yaml:"ban_duration" toml:"ban_duration"`
+}
+
+type fileAudit struct {
+	Log      string `yaml:"log" toml:"log"`
+	MaxSize  int    `yaml:"max_size" toml:"max_size"`
+	MaxFiles int    `yaml:"max_files" toml:"max_files"`
+}
+
+type fileStorage struct {
+	Path            string `yaml:"path" toml:"path"`
+	CollectInterval string `yaml:"collect_interval" toml:"collect_interval"`
+}
+
+type fileRemoteWrite struct {
+	URL             string            `yaml:"url" toml:"url"`
+	Username        string            `yaml:"username" toml:"username"`
+	Password        string            `yaml:"password" toml:"password"`
+	PasswordFile    string            `yaml:"password_file" toml:"password_file"`
+	BearerToken     string            `yaml:"bearer_token" toml:"bearer_token"`
+	BearerTokenFile string            `yaml:"bearer_token_file" toml:"bearer_token_file"`
+	ExternalLabels  map[string]string `yaml:"external_labels" toml:"external_labels"`
+	Interval        string            `yaml:"interval" toml:"interval"`
+	QueueDir        string            `yaml:"queue_dir" toml:"queue_dir"`
+	QueueSize       int               `yaml:"queue_size" toml:"queue_size"`
+}
+
+type fileInflux struct {
+	URL           string            `yaml:"url" toml:"url"`
+	Org           string            `yaml:"org" toml:"org"`
+	Bucket        string            `yaml:"bucket" toml:"bucket"`
+	Token         string            `yaml:"token" toml:"token"`
+	TokenFile     string            `yaml:"token_file" toml:"token_file"`
+	Tags          map[string]string `yaml:"tags" toml:"tags"`
+	BatchSize     int               `yaml:"batch_size" toml:"batch_size"`
+	FlushInterval string            `yaml:"flush_interval" toml:"flush_interval"`
+}
+
+type fileOTLP struct {
+	Endpoint           string            `yaml:"endpoint" toml:"endpoint"`
+	Protocol           string            `yaml:"protocol" toml:"protocol"`
+	Headers            map[string]string `yaml:"headers" toml:"headers"`
+	HeadersFile        string            `yaml:"headers_file" toml:"headers_file"`
+	ResourceAttributes map[string]string `yaml:"resource_attributes" toml:"resource_attributes"`
+	Interval           string            `yaml:"interval" toml:"interval"`
+}
+
+type fileStatsD struct {
+	Address  string `yaml:"address" toml:"address"`
+	Template string `yaml:"template" toml:"template"`
+}
+
+type fileGraphite struct {
+	Address    string `yaml:"address" toml:"address"`
+	Template   string `yaml:"template" toml:"template"`
+	BufferSize int    `yaml:"buffer_size" toml:"buffer_size"`
+}
+
+type fileHub struct {
+	Agents         map[string]string `yaml:"agents" toml:"agents"`
+	AgentToken     string            `yaml:"agent_token" toml:"agent_token"`
+	AgentTokenFile string            `yaml:"agent_token_file" toml:"agent_token_file"`
+	PollInterval   string            `yaml:"poll_interval" toml:"poll_interval"`
+	Timeout        string            `yaml:"timeout" toml:"timeout"`
+	StaleAfter     string            `yaml:"stale_after" toml:"stale_after"`
+
+	URL               string `yaml:"url" toml:"url"`
+	Token             string `yaml:"token" toml:"token"`
+	TokenFile         string `yaml:"token_file" toml:"token_file"`
+	NodeName          string `yaml:"node_name" toml:"node_name"`
+	AdvertiseURL      string `yaml:"advertise_url" toml:"advertise_url"`
+	HeartbeatInterval string `yaml:"heartbeat_interval" toml:"heartbeat_interval"`
+}
+
+type fileFilter struct {
+	Fields []string `yaml:"fields" toml:"fields"`
+	Device []string `yaml:"device" toml:"device"`
+}
+
+type fileAnomaly struct {
+	Metrics   []string `yaml:"metrics" toml:"metrics"`
+	Threshold *float64 `yaml:"threshold" toml:"threshold"`
+	Alpha     *float64 `yaml:"alpha" toml:"alpha"`
+}
+
+// Load reads the configuration file at path, if any, and applies the
+// environment variables returned by getenv on top of it. Non-empty environment
+// variables override the file, and secrets can be read from files, see
+// lookupSecret. All invalid settings are reported together.
+func Load(path string, getenv func(string) string) (*Config, error) {
+	var problems []error
+
+	values, keys := make(map[string]string), make(map[string]string)
+	if path != "" {
+		var err error
+		if values, keys, err = readFile(path); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+		for _, name := range secretVariables {
+			if values[name] == "" {
+				continue
+			}
+			if err := checkPrivate(path); err != nil {
+				problems = append(problems, fmt.Errorf("config file holds %s: %w", name, err))
+			}
+			break
+		}
+	}
+
+	get := func(name string) string {
+		if value := getenv(name); value != "" {
+			return value
+		}
+		return values[name]
+	}
+	secret := func(name string) string {
+		value, err := lookupSecret(name, getenv, values)
+		if err != nil {
+			problems = append(problems, err)
+		}
+		return value
+	}
+
+	if keyFile := get("TLS_KEY_FILE"); keyFile != "" {
+		if err := checkKeyFile(keyFile); err != nil {
+			problems = append(problems, fmt.Errorf("TLS_KEY_FILE: %w", err))
+		}
+	}
+
+	apiSecret, hmacKeys := secret("API_SECRET"), secret("HMAC_KEYS")
+	remoteWritePassword, remoteWriteToken := secret("REMOTE_WRITE_PASSWORD"), secret("REMOTE_WRITE_BEARER_TOKEN")
+	influxToken, otlpHeaders := secret("INFLUX_TOKEN"), secret("OTLP_HEADERS")
+	hubAgentToken, hubToken := secret("HUB_AGENT_TOKEN"), secret("HUB_TOKEN")
+
+	c := NewConfig(get("PORT"), apiSecret)
+	c.problems = append(problems, c.problems...)
+	c.SetListen(get("LISTEN"), get("ADMIN_LISTEN"), get("GRPC_LISTEN"), get("SOCKET_MODE"), get("SOCKET_GROUP"))
+	c.SetStorage(get("STORAGE_PATH"), get("COLLECT_INTERVAL"))
+	c.SetAnomaly(get("ANOMALY_METRICS"), get("ANOMALY_THRESHOLD"), get("ANOMALY_ALPHA"))
+	c.SetCollect(get("COLLECTORS"), get("FILTER_FIELDS"), get("FILTER_DEVICE"))
+	c.SetTokens(get("TOKENS_FILE"))
+	c.SetTLS(
+		get("TLS_CERT_FILE"),
+		get("TLS_KEY_FILE"),
+		get("TLS_MIN_VERSION"),
+		get("TLS_CIPHER_SUITES"),
+		get("TLS_CLIENT_CA_FILE"),
+		get("TLS_CLIENT_AUTH"),
+		get("TLS_CLIENT_SCOPES"),
+	)
+	c.SetHMAC(hmacKeys, get("HMAC_KEY_SCOPES"), get("HMAC_MAX_SKEW"))
+	c.SetJWT(
+		get("JWT_JWKS_FILE"),
+		get("JWT_ISSUER"),
+		get("JWT_AUDIENCE"),
+		get("JWT_LEEWAY"),
+		get("JWT_SCOPES_CLAIM"),
+		get("JWT_SCOPE_MAP"),
+	)
+	c.SetAccess(
+		get("TRUSTED_PROXIES"),
+		get("IP_ALLOW"),
+		get("IP_DENY"),
+		get("ADMIN_IP_ALLOW"),
+		get("ADMIN_IP_DENY"),
+		get("RATE_LIMIT_IP"),
+		get("RATE_LIMIT_TOKEN"),
+		get("AUTH_BAN_THRESHOLD"),
+		get("AUTH_BAN_WINDOW"),
+		get("AUTH_BAN_DURATION"),
+	)
+	c.SetAudit(get("AUDIT_LOG"), get("AUDIT_LOG_MAX_SIZE"), get("AUDIT_LOG_MAX_FILES"))
+	c.SetRemoteWrite(
+		get("REMOTE_WRITE_URL"),
+		get("REMOTE_WRITE_USERNAME"),
+		remoteWritePassword,
+		remoteWriteToken,
+		get("REMOTE_WRITE_EXTERNAL_LABELS"),
+		get("REMOTE_WRITE_INTERVAL"),
+		get("REMOTE_WRITE_QUEUE_DIR"),
+		get("REMOTE_WRITE_QUEUE_SIZE"),
+	)
+	c.SetInflux(
+		get("INFLUX_URL"),
+		get("INFLUX_ORG"),
+		get("INFLUX_BUCKET"),
+		influxToken,
+		get("INFLUX_TAGS"),
+		get("INFLUX_BATCH_SIZE"),
+		get("INFLUX_FLUSH_INTERVAL"),
+	)
+	c.SetOTLP(get("OTLP_ENDPOINT"), get("OTLP_PROTOCOL"), otlpHeaders, get("OTLP_RESOURCE_ATTRIBUTES"), get("OTLP_INTERVAL"))
+	c.SetStatsD(get("STATSD_ADDRESS"), get("STATSD_TEMPLATE"))
+	c.SetGraphite(get("GRAPHITE_ADDRESS"), get("GRAPHITE_TEMPLATE"), get("GRAPHITE_BUFFER_SIZE"))
+	c.SetHub(get("HUB_AGENTS"), hubAgentToken, get("HUB_POLL_INTERVAL"), get("HUB_TIMEOUT"), get("HUB_STALE_AFTER"))
+	c.SetHubRegistration(get("HUB_URL"), hubToken, get("HUB_NODE_NAME"), get("HUB_ADVERTISE_URL"), get("HUB_HEARTBEAT_INTERVAL"))
+
+	if err := c.Validate(); err != nil {
+		// Name the file keys of the invalid values that were read from the file
+		fileKeys := make(map[string]string)
+		for name, key := range keys {
+			if getenv(name) == "" {
+				fileKeys[name] = key
+			}
+		}
+		return nil, renameVariables(c.problems, fileKeys)
+	}
+	return c, nil
+}
+
+// renamedError is a problem whose message names file keys instead of variables.
+type renamedError struct {
+	message string
+	err     error
+}
+
+func (e renamedError) Error() string {
+	return e.message
+}
+
+func (e renamedError) Unwrap() error {
+	return e.err
+}
+
+// renameVariables replaces the variables in the messages of problems by the
+// file keys they were read from, e.g. "auth.hmac.keys" for "HMAC_KEYS".
+func renameVariables(problems []error, fileKeys map[string]string) error {
+	renamed := make([]error, 0, len(problems))
+	for _, problem := range problems {
+		message := variablePattern.ReplaceAllStringFunc(problem.Error(), func(name string) string {
+			if key, ok := fileKeys[name]; ok {
+				return key
+			}
+			return name
+		})
+		renamed = append(renamed, renamedError{message: message, err: problem})
+	}
+	return errors.Join(renamed...)
+}
+
+// variablePattern matches the names of environment variables.
+var variablePattern = regexp.MustCompile(`\b[A-Z][A-Z0-9_]+\b`)
+
+// readFile strictly decodes a YAML or TOML configuration file, chosen by its
+// extension, and returns its settings and their file keys, both keyed by their
+// environment variable.
+func readFile(path string) (map[string]string, map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var file fileConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, err
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&file); err != nil {
+			var strict *toml.StrictMissingError
+			if errors.As(err, &strict) {
+				return nil, nil, errors.New(strict.String())
+			}
+			var decode *toml.DecodeError
+			if errors.As(err, &decode) {
+				return nil, nil, errors.New(decode.String())
+			}
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, errors.New("unsupported format: use a .yaml, .yml or .toml file")
+	}
+
+	return file.values()
+}
+
+// values flattens the file into the format of the environment variables and
+// returns the file key of every variable set.
+func (f fileConfig) values() (map[string]string, map[string]string, error) {
+	values, keys := make(map[string]string), make(map[string]string)
+	var problems []error
+
+	set := func(name string, key string, value string) {
+		if value != "" {
+			values[name] = value
+			keys[name] = key
+		}
+	}
+	setMapping := func(name string, key string, mapping map[string][]string) {
+		value, err := joinMapping(mapping)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", key, err))
+		}
+		set(name, key, value)
+	}
+
+	if f.Port != 0 {
+		set("PORT", "port", strconv.Itoa(f.Port))
+	}
+	set("LISTEN", "listen", strings.Join(f.Listen, ","))
+	set("ADMIN_LISTEN", "admin_listen", strings.Join(f.AdminListen, ","))
+	set("GRPC_LISTEN", "grpc_listen", strings.Join(f.GRPCListen, ","))
+	set("SOCKET_MODE", "socket_mode", f.SocketMode)
+	set("SOCKET_GROUP", "socket_group", f.SocketGroup)
+
+	set("API_SECRET", "auth.api_secret", f.Auth.APISecret)
+	set("API_SECRET_FILE", "auth.api_secret_file", f.Auth.APISecretFile)
+	set("TOKENS_FILE", "auth.tokens_file", f.Auth.TokensFile)
+
+	hmacKeys := make(map[string][]string, len(f.Auth.HMAC.Keys))
+	for id, key := range f.Auth.HMAC.Keys {
+		hmacKeys[id] = []string{key}
+	}
+	setMapping("HMAC_KEYS", "auth.hmac.keys", hmacKeys)
+	set("HMAC_KEYS_FILE", "auth.hmac.keys_file", f.Auth.HMAC.KeysFile)
+	setMapping("HMAC_KEY_SCOPES", "auth.hmac.key_scopes", f.Auth.HMAC.KeyScopes)
+	set("HMAC_MAX_SKEW", "auth.hmac.max_skew", f.Auth.HMAC.MaxSkew)
+
+	set("JWT_JWKS_FILE", "auth.jwt.jwks_file", f.Auth.JWT.JWKSFile)
+	set("JWT_ISSUER", "auth.jwt.issuer", f.Auth.JWT.Issuer)
+	set("JWT_AUDIENCE", "auth.jwt.audience", f.Auth.JWT.Audience)
+	set("JWT_LEEWAY", "auth.jwt.leeway", f.Auth.JWT.Leeway)
+	set("JWT_SCOPES_CLAIM", "auth.jwt.scopes_claim", f.Auth.JWT.ScopesClaim)
+	setMapping("JWT_SCOPE_MAP", "auth.jwt.scope_map", f.Auth.JWT.ScopeMap)
+
+	set("TLS_CERT_FILE", "tls.cert_file", f.TLS.CertFile)
+	set("TLS_KEY_FILE", "tls.key_file", f.TLS.KeyFile)
+	set("TLS_MIN_VERSION", "tls.min_version", f.TLS.MinVersion)
+	set("TLS_CIPHER_SUITES", "tls.cipher_suites", strings.Join(f.TLS.CipherSuites, ","))
+	set("TLS_CLIENT_CA_FILE", "tls.client_ca_file", f.TLS.ClientCAFile)
+	set("TLS_CLIENT_AUTH", "tls.client_auth", f.TLS.ClientAuth)
+	setMapping("TLS_CLIENT_SCOPES", "tls.client_scopes", f.TLS.ClientScopes)
+
+	set("TRUSTED_PROXIES", "access.trusted_proxies", strings.Join(f.Access.TrustedProxies, ","))
+	set("IP_ALLOW", "access.allow", strings.Join(f.Access.Allow, ","))
+	set("IP_DENY", "access.deny", strings.Join(f.Access.Deny, ","))
+	set("ADMIN_IP_ALLOW", "access.admin_allow", strings.Join(f.Access.AdminAllow, ","))
+	set("ADMIN_IP_DENY", "access.admin_deny", strings.Join(f.Access.AdminDeny, ","))
+	if f.Access.RateLimitIP != nil {
+		set("RATE_LIMIT_IP", "access.rate_limit_ip", strconv.FormatFloat(*f.Access.RateLimitIP, 'f', -1, 64))
+	}
+	if f.Access.RateLimitToken != nil {
+		set("RATE_LIMIT_TOKEN", "access.rate_limit_token", strconv.FormatFloat(*f.Access.RateLimitToken, 'f', -1, 64))
+	}
+	if f.Access.BanThreshold != nil {
+		set("AUTH_BAN_THRESHOLD", "access.ban_threshold", strconv.Itoa(*f.Access.BanThreshold))
+	}
+	set("AUTH_BAN_WINDOW", "access.ban_window", f.Access.BanWindow)
+	set("AUTH_BAN_DURATION", "access.ban_duration", f.Access.BanDuration)
+
+	set("AUDIT_LOG", "audit.log", f.Audit.Log)
+	if f.Audit.MaxSize != 0 {
+		set("AUDIT_LOG_MAX_SIZE", "audit.max_size", strconv.Itoa(f.Audit.MaxSize))
+	}
+	if f.Audit.MaxFiles != 0 {
+		set("AUDIT_LOG_MAX_FILES", "audit.max_files", strconv.Itoa(f.Audit.MaxFiles))
+	}
+
+	set("STORAGE_PATH", "storage.path", f.Storage.Path)
+	set("COLLECT_INTERVAL", "storage.collect_interval", f.Storage.CollectInterval)
+
+	set("ANOMALY_METRICS", "anomaly.metrics", strings.Join(f.Anomaly.Metrics, ","))
+	if f.Anomaly.Threshold != nil {
+		set("ANOMALY_THRESHOLD", "anomaly.threshold", strconv.FormatFloat(*f.Anomaly.Threshold, 'f', -1, 64))
+	}
+	if f.Anomaly.Alpha != nil {
+		set("ANOMALY_ALPHA", "anomaly.alpha", strconv.FormatFloat(*f.Anomaly.Alpha, 'f', -1, 64))
+	}
+
+	set("COLLECTORS", "collectors", strings.Join(f.Collectors, ","))
+	set("FILTER_FIELDS", "filter.fields", strings.Join(f.Filter.Fields, ","))
+	set("FILTER_DEVICE", "filter.device", strings.Join(f.Filter.Device, ","))
+
+	set("REMOTE_WRITE_URL", "remote_write.url", f.RemoteWrite.URL)
+	set("REMOTE_WRITE_USERNAME", "remote_write.username", f.RemoteWrite.Username)
+	set("REMOTE_WRITE_PASSWORD", "remote_write.password", f.RemoteWrite.Password)
+	set("REMOTE_WRITE_PASSWORD_FILE", "remote_write.password_file", f.RemoteWrite.PasswordFile)
+	set("REMOTE_WRITE_BEARER_TOKEN", "remote_write.bearer_token", f.RemoteWrite.BearerToken)
+	set("REMOTE_WRITE_BEARER_TOKEN_FILE", "remote_write.bearer_token_file", f.RemoteWrite.BearerTokenFile)
+	setMapping("REMOTE_WRITE_EXTERNAL_LABELS", "remote_write.external_labels", singleValues(f.RemoteWrite.ExternalLabels))
+	set("REMOTE_WRITE_INTERVAL", "remote_write.interval", f.RemoteWrite.Interval)
+	set("REMOTE_WRITE_QUEUE_DIR", "remote_write.queue_dir", f.RemoteWrite.QueueDir)
+	if f.RemoteWrite.QueueSize != 0 {
+		set("REMOTE_WRITE_QUEUE_SIZE", "remote_write.queue_size", strconv.Itoa(f.RemoteWrite.QueueSize))
+	}
+
+	set("INFLUX_URL", "influx.url", f.Influx.URL)
+	set("INFLUX_ORG", "influx.org", f.Influx.Org)
+	set("INFLUX_BUCKET", "influx.bucket", f.Influx.Bucket)
+	set("INFLUX_TOKEN", "influx.token", f.Influx.Token)
+	set("INFLUX_TOKEN_FILE", "influx.token_file", f.Influx.TokenFile)
+	setMapping("INFLUX_TAGS", "influx.tags", singleValues(f.Influx.Tags))
+	if f.Influx.BatchSize != 0 {
+		set("INFLUX_BATCH_SIZE", "influx.batch_size", strconv.Itoa(f.Influx.BatchSize))
+	}
+	set("INFLUX_FLUSH_INTERVAL", "influx.flush_interval", f.Influx.FlushInterval)
+
+	set("OTLP_ENDPOINT", "otlp.endpoint", f.OTLP.Endpoint)
+	set("OTLP_PROTOCOL", "otlp.protocol", f.OTLP.Protocol)
+	setMapping("OTLP_HEADERS", "otlp.headers", singleValues(f.OTLP.Headers))
+	set("OTLP_HEADERS_FILE", "otlp.headers_file", f.OTLP.HeadersFile)
+	setMapping("OTLP_RESOURCE_ATTRIBUTES", "otlp.resource_attributes", singleValues(f.OTLP.ResourceAttributes))
+	set("OTLP_INTERVAL", "otlp.interval", f.OTLP.Interval)
+
+	set("STATSD_ADDRESS", "statsd.address", f.StatsD.Address)
+	set("STATSD_TEMPLATE", "statsd.template", f.StatsD.Template)
+
+	set("GRAPHITE_ADDRESS", "graphite.address", f.Graphite.Address)
+	set("GRAPHITE_TEMPLATE", "graphite.template", f.Graphite.Template)
+	if f.Graphite.BufferSize != 0 {
+		set("GRAPHITE_BUFFER_SIZE", "graphite.buffer_size", strconv.Itoa(f.Graphite.BufferSize))
+	}
+
+	setMapping("HUB_AGENTS", "hub.agents", singleValues(f.Hub.Agents))
+	set("HUB_AGENT_TOKEN", "hub.agent_token", f.Hub.AgentToken)
+	set("HUB_AGENT_TOKEN_FILE", "hub.agent_token_file", f.Hub.AgentTokenFile)
+	set("HUB_POLL_INTERVAL", "hub.poll_interval", f.Hub.PollInterval)
+	set("HUB_TIMEOUT", "hub.timeout", f.Hub.Timeout)
+	set("HUB_STALE_AFTER", "hub.stale_after", f.Hub.StaleAfter)
+	set("HUB_URL", "hub.url", f.Hub.URL)
+	set("HUB_TOKEN", "hub.token", f.Hub.Token)
+	set("HUB_TOKEN_FILE", "hub.token_file", f.Hub.TokenFile)
+	set("HUB_NODE_NAME", "hub.node_name", f.Hub.NodeName)
+	set("HUB_ADVERTISE_URL", "hub.advertise_url", f.Hub.AdvertiseURL)
+	set("HUB_HEARTBEAT_INTERVAL", "hub.heartbeat_interval", f.Hub.HeartbeatInterval)
+
+	return values, keys, errors.Join(problems...)
+}
+
+// singleValues converts a mapping to one value per name to the form of joinMapping.
+func singleValues(mapping map[string]string) map[string][]string {
+	values := make(map[string][]string, len(mapping))
+	for name, value := range mapping {
+		values[name] = []string{value}
+	}
+	return values
+}
+
+// joinMapping formats a mapping as "name=value,value;name=value", sorted by name.
+func joinMapping(mapping map[string][]string) (string, error) {
+	names := make([]string, 0, len(mapping))
+	for name := range mapping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.ContainsAny(name, "=;") {
+			return "", fmt.Errorf("name %q must not contain '=' or ';'", name)
+		}
+		for _, value := range mapping[name] {
+			if strings.Contains(value, ";") {
+				return "", fmt.Errorf("value of %q must not contain ';'", name)
+			}
+		}
+		entries = append(entries, name+"="+strings.Join(mapping[name], ","))
+	}
+	return strings.Join(entries, ";"), nil
+}
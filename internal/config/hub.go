@@ -0,0 +1,77 @@
+package config
+
+import (
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/hub"
+)
+
+// HubConfig holds the settings of the hub mode and of the registration of an
+// agent with a hub.
+type HubConfig struct {
+	Agents       map[string]string // Base URLs of the agents to poll by node name
+	AgentToken   string            // Bearer token sent to the agents
+	PollInterval time.Duration     // Interval between two polls of an agent
+	Timeout      time.Duration     // Timeout of a poll
+	StaleAfter   time.Duration     // Age after which the metrics of a node are stale
+
+	URL               string        // Hub the agent registers with, registration is disabled when empty
+	Token             string        // Bearer token sent to the hub
+	NodeName          string        // Name the agent registers as, the hostname when empty
+	AdvertiseURL      string        // URL the hub is told the agent can be reached at
+	HeartbeatInterval time.Duration // Interval between two heartbeats
+}
+
+// SetHub configures the hub mode. Agents are given as "name=url;name=url".
+// The timeout defaults to the poll interval and the stale threshold to three
+// poll intervals.
+func (c *Config) SetHub(agents, agentToken, pollInterval, timeout, staleAfter string) {
+	c.Hub = HubConfig{
+		Agents:       c.parseMapping("HUB_AGENTS", agents, "name=url"),
+		AgentToken:   agentToken,
+		PollInterval: hub.DefaultInterval,
+	}
+
+	for name, agentURL := range c.Hub.Agents {
+		if !hub.ValidName(name) {
+			c.fail("HUB_AGENTS name %q may only contain letters, digits, '.', '_' and '-'", name)
+		}
+		c.checkURL("HUB_AGENTS "+name, agentURL)
+	}
+	if pollInterval != "" {
+		c.Hub.PollInterval = c.parsePositiveDuration("HUB_POLL_INTERVAL", pollInterval, hub.DefaultInterval)
+	}
+	c.Hub.Timeout = c.Hub.PollInterval
+	if timeout != "" {
+		c.Hub.Timeout = c.parsePositiveDuration("HUB_TIMEOUT", timeout, c.Hub.PollInterval)
+	}
+	c.Hub.StaleAfter = 3 * c.Hub.PollInterval
+	if staleAfter != "" {
+		c.Hub.StaleAfter = c.parsePositiveDuration("HUB_STALE_AFTER", staleAfter, 3*c.Hub.PollInterval)
+	}
+	if c.Hub.StaleAfter < c.Hub.PollInterval {
+		c.fail("HUB_STALE_AFTER must not be shorter than HUB_POLL_INTERVAL")
+	}
+}
+
+// SetHubRegistration configures the registration of the agent with a hub.
+func (c *Config) SetHubRegistration(hubURL, hubToken, nodeName, advertiseURL, heartbeatInterval string) {
+	c.Hub.URL = hubURL
+	c.Hub.Token = hubToken
+	c.Hub.NodeName = nodeName
+	c.Hub.AdvertiseURL = advertiseURL
+	c.Hub.HeartbeatInterval = hub.DefaultHeartbeatInterval
+
+	if hubURL != "" {
+		c.checkURL("HUB_URL", hubURL)
+	}
+	if nodeName != "" && !hub.ValidName(nodeName) {
+		c.fail("HUB_NODE_NAME may only contain letters, digits, '.', '_' and '-'")
+	}
+	if advertiseURL != "" {
+		c.checkURL("HUB_ADVERTISE_URL", advertiseURL)
+	}
+	if heartbeatInterval != "" {
+		c.Hub.HeartbeatInterval = c.parsePositiveDuration("HUB_HEARTBEAT_INTERVAL", heartbeatInterval, hub.DefaultHeartbeatInterval)
+	}
+}
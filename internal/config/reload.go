@@ -0,0 +1,74 @@
+package config
+
+import (
+	"reflect"
+)
+
+// Reloadable returns the configuration to use after a reload: next with the
+// settings that only take effect on a restart kept at their current values.
+// The environment variables of the changed settings that need a restart are
+// returned as well, so they can be reported.
+func (c *Config) Reloadable(next *Config) (*Config, []string) {
+	restart := []struct {
+		name    string
+		current any
+		next    any
+	}{
+		{"PORT", c.Port, next.Port},
+		{"LISTEN", c.Listen.Addresses, next.Listen.Addresses},
+		{"ADMIN_LISTEN", c.Listen.AdminAddresses, next.Listen.AdminAddresses},
+		{"GRPC_LISTEN", c.Listen.GRPCAddresses, next.Listen.GRPCAddresses},
+		{"SOCKET_MODE", c.Listen.SocketMode, next.Listen.SocketMode},
+		{"SOCKET_GROUP", c.Listen.SocketGroup, next.Listen.SocketGroup},
+		{"TOKENS_FILE", c.TokensFile, next.TokensFile},
+		{"STORAGE_PATH", c.StoragePath, next.StoragePath},
+		{"COLLECT_INTERVAL", c.CollectInterval, next.CollectInterval},
+		{"ANOMALY_METRICS", c.AnomalyMetrics, next.AnomalyMetrics},
+		{"ANOMALY_THRESHOLD", c.AnomalyThreshold, next.AnomalyThreshold},
+		{"ANOMALY_ALPHA", c.AnomalyAlpha, next.AnomalyAlpha},
+		{"TLS_CERT_FILE", c.TLS.CertFile, next.TLS.CertFile},
+		{"TLS_KEY_FILE", c.TLS.KeyFile, next.TLS.KeyFile},
+		{"TLS_MIN_VERSION", c.TLS.MinVersion, next.TLS.MinVersion},
+		{"TLS_CIPHER_SUITES", c.TLS.CipherSuites, next.TLS.CipherSuites},
+		{"TLS_CLIENT_CA_FILE", c.TLS.ClientCAFile, next.TLS.ClientCAFile},
+		{"TLS_CLIENT_AUTH", c.TLS.ClientAuth, next.TLS.ClientAuth},
+		{"TRUSTED_PROXIES", c.Access.TrustedProxies, next.Access.TrustedProxies},
+		{"AUDIT_LOG", c.Audit, next.Audit},
+		{"REMOTE_WRITE_*", c.RemoteWrite, next.RemoteWrite},
+		{"INFLUX_*", c.Influx, next.Influx},
+		{"OTLP_*", c.OTLP, next.OTLP},
+		{"STATSD_*", c.StatsD, next.StatsD},
+		{"GRAPHITE_*", c.Graphite, next.Graphite},
+		{"HUB_*", c.Hub, next.Hub},
+	}
+
+	var changed []string
+	for _, setting := range restart {
+		if !reflect.DeepEqual(setting.current, setting.next) {
+			changed = append(changed, setting.name)
+		}
+	}
+
+	merged := *next
+	merged.Port = c.Port
+	merged.Listen = c.Listen
+	merged.TokensFile = c.TokensFile
+	merged.StoragePath = c.StoragePath
+	merged.CollectInterval = c.CollectInterval
+	merged.AnomalyMetrics = c.AnomalyMetrics
+	merged.AnomalyThreshold = c.AnomalyThreshold
+	merged.AnomalyAlpha = c.AnomalyAlpha
+	clientScopes := next.TLS.ClientScopes
+	merged.TLS = c.TLS
+	merged.TLS.ClientScopes = clientScopes
+	merged.Access.TrustedProxies = c.Access.TrustedProxies
+	merged.Audit = c.Audit
+	merged.RemoteWrite = c.RemoteWrite
+	merged.Influx = c.Influx
+	merged.OTLP = c.OTLP
+	merged.StatsD = c.StatsD
+	merged.Graphite = c.Graphite
+	merged.Hub = c.Hub
+
+	return &merged, changed
+}
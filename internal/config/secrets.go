@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// secretVariables are the settings holding credentials. Besides the variable
+// itself, each can be read from the file named by <NAME>_FILE or from the
+// systemd credential <NAME> in $CREDENTIALS_DIRECTORY.
+var secretVariables = []string{
+	"API_SECRET",
+	"HMAC_KEYS",
+	"REMOTE_WRITE_PASSWORD",
+	"REMOTE_WRITE_BEARER_TOKEN",
+	"INFLUX_TOKEN",
+	"OTLP_HEADERS",
+	"HUB_AGENT_TOKEN",
+	"HUB_TOKEN",
+}
+
+// lookupSecret returns the secret name from, in order, the environment, a file
+// named by the environment, a systemd credential or the configuration file.
+func lookupSecret(name string, getenv func(string) string, values map[string]string) (string, error) {
+	fileName := name + "_FILE"
+
+	value, file := getenv(name), getenv(fileName)
+	if value != "" && file != "" {
+		return "", fmt.Errorf("set only one of %s and %s", name, fileName)
+	}
+	if value != "" {
+		return value, nil
+	}
+	if file != "" {
+		return readSecret(fileName, file)
+	}
+
+	if dir := getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+		credential := filepath.Join(dir, name)
+		if _, err := os.Stat(credential); err == nil {
+			return readSecret("credential "+name, credential)
+		}
+	}
+
+	value, file = values[name], values[fileName]
+	if value != "" && file != "" {
+		return "", fmt.Errorf("set only one of %s and %s", name, fileName)
+	}
+	if file != "" {
+		return readSecret(fileName, file)
+	}
+	return value, nil
+}
+
+// readSecret reads a secret from path without its trailing line break,
+// refusing files that other users can read.
+func readSecret(variable string, path string) (string, error) {
+	if err := checkPrivate(path); err != nil {
+		return "", fmt.Errorf("%s: %w", variable, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", variable, err)
+	}
+
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("%s: %s is empty", variable, path)
+	}
+	return secret, nil
+}
+
+// checkPrivate returns an error if path can be read by its group or other
+// users. Windows does not report such permissions and is not checked.
+func checkPrivate(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%s is accessible by group or other users (mode %04o), restrict it with chmod 600", path, info.Mode().Perm())
+	}
+	return nil
+}
+
+// checkKeyFile returns an error if the private key at path can be read by other
+// users. Unlike secrets, keys may be readable by their group, as in the Debian
+// /etc/ssl/private layout (mode 0640, group ssl-cert). A missing file is
+// reported when the key is loaded.
+func checkKeyFile(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o007 != 0 {
+		return fmt.Errorf("%s is accessible by other users (mode %04o), restrict it with chmod 640", path, info.Mode().Perm())
+	}
+	return nil
+}
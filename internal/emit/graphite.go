@@ -0,0 +1,191 @@
+package emit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/sendbuf"
+)
+
+// Defaults of the GraphiteOptions.
+const (
+	DefaultMaxBuffered = 100000
+	DefaultTimeout     = 10 * time.Second
+	DefaultMinBackoff  = time.Second
+	DefaultMaxBackoff  = time.Minute
+)
+
+// GraphiteOptions configures a Graphite emitter.
+type GraphiteOptions struct {
+	Address     string        // TCP "host:port" of the Carbon plaintext receiver
+	Template    string        // Path template, DefaultTemplate when empty
+	MaxBuffered int           // Lines kept while the server is unreachable, the oldest are dropped beyond it
+	Timeout     time.Duration // Timeout of connecting and of a write
+	MinBackoff  time.Duration // First delay before reconnecting
+	MaxBackoff  time.Duration // Maximum delay between reconnects
+}
+
+// Graphite is a collector sink sending every sample as a plaintext
+// "path value timestamp" line over a persistent TCP connection. Lines are
+// buffered while the server is unreachable and sent once it reconnects.
+type Graphite struct {
+	opts     GraphiteOptions
+	template *Template
+	hostname string
+	onError  func(error)
+	ready    chan struct{}
+
+	lines *sendbuf.Buffer[[]byte]
+	conn  net.Conn // Only used by Flush
+}
+
+// NewGraphite creates a Graphite emitter. onError is called with failed
+// writes and dropped lines.
+func NewGraphite(opts GraphiteOptions, onError func(error)) (*Graphite, error) {
+	if opts.Template == "" {
+		opts.Template = DefaultTemplate
+	}
+	if opts.MaxBuffered <= 0 {
+		opts.MaxBuffered = DefaultMaxBuffered
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(DefaultMaxBackoff, opts.MinBackoff)
+	}
+
+	template, err := ParseTemplate(opts.Template)
+	if err != nil {
+		return nil, err
+	}
+	return &Graphite{
+		opts:     opts,
+		template: template,
+		hostname: hostname(),
+		onError:  onError,
+		ready:    make(chan struct{}, 1),
+		lines:    sendbuf.New[[]byte](opts.MaxBuffered, nil),
+	}, nil
+}
+
+// Consume buffers the samples of a snapshot and wakes up the sender.
+func (g *Graphite) Consume(s collector.Snapshot) {
+	host := hostOf(s.Metrics.Host, g.hostname)
+	timestamp := strconv.FormatInt(s.Timestamp.Unix(), 10)
+
+	var lines [][]byte
+	for _, sample := range s.Samples() {
+		var line []byte
+		line = append(line, g.template.Path(sample, host)...)
+		line = append(line, ' ')
+		line = strconv.AppendFloat(line, sample.Value, 'f', -1, 64)
+		line = append(line, ' ')
+		line = append(line, timestamp...)
+		line = append(line, '\n')
+		lines = append(lines, line)
+	}
+	dropped := g.lines.Push(lines...)
+
+	if dropped > 0 {
+		g.onError(fmt.Errorf("Graphite buffer is full, dropped %d lines", dropped))
+	}
+	select {
+	case g.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Buffered returns the number of lines waiting to be sent.
+func (g *Graphite) Buffered() int {
+	return g.lines.Len()
+}
+
+// Run sends the buffered lines whenever a snapshot arrives, until ctx is
+// cancelled. After a failure it reconnects with exponential backoff.
+func (g *Graphite) Run(ctx context.Context) {
+	defer g.disconnect()
+
+	backoff := g.opts.MinBackoff
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-g.ready:
+		}
+
+		for g.Buffered() > 0 {
+			err := g.Flush(ctx)
+			if err == nil {
+				backoff = g.opts.MinBackoff
+				continue
+			}
+			g.onError(err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, g.opts.MaxBackoff)
+		}
+	}
+}
+
+// Flush connects if needed and sends the buffered lines. On failure the
+// connection is closed and the lines stay buffered; Flush must not be called
+// concurrently.
+func (g *Graphite) Flush(ctx context.Context) error {
+	lines, end := g.lines.Peek(g.opts.MaxBuffered)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	if g.conn != nil && !alive(g.conn) {
+		g.disconnect()
+	}
+	if g.conn == nil {
+		dialer := net.Dialer{Timeout: g.opts.Timeout}
+		conn, err := dialer.DialContext(ctx, "tcp", g.opts.Address)
+		if err != nil {
+			return fmt.Errorf("Graphite connection failed: %w", err)
+		}
+		g.conn = conn
+	}
+
+	g.conn.SetWriteDeadline(time.Now().Add(g.opts.Timeout))
+	if _, err := g.conn.Write(bytes.Join(lines, nil)); err != nil {
+		g.disconnect()
+		return fmt.Errorf("Graphite write failed: %w", err)
+	}
+
+	// Lines dropped while writing are skipped, so none of the new ones are lost
+	g.lines.Remove(end)
+	return nil
+}
+
+// disconnect closes the connection, if any.
+func (g *Graphite) disconnect() {
+	if g.conn != nil {
+		g.conn.Close()
+		g.conn = nil
+	}
+}
+
+// alive reports whether the server has not closed the connection. Carbon never
+// writes to the client, so anything but a timeout on a read means it is gone.
+func alive(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	_, err := conn.Read(make([]byte, 1))
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
@@ -0,0 +1,120 @@
+package emit
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/nodebytehosting/syscapture/internal/collector"
+)
+
+// DefaultMaxPacketSize keeps StatsD datagrams below the common Ethernet MTU.
+const DefaultMaxPacketSize = 1432
+
+// StatsDOptions configures a StatsD emitter.
+type StatsDOptions struct {
+	Address       string // UDP "host:port" of the StatsD server
+	Template      string // Path template, DefaultTemplate when empty
+	MaxPacketSize int    // Maximum size of a datagram
+}
+
+// StatsD is a collector sink sending every sample as a StatsD gauge over UDP.
+// Gauges are packed into as few datagrams as the packet size allows.
+type StatsD struct {
+	opts     StatsDOptions
+	template *Template
+	hostname string
+	onError  func(error)
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewStatsD creates a StatsD emitter. onError is called with failed sends.
+func NewStatsD(opts StatsDOptions, onError func(error)) (*StatsD, error) {
+	if opts.Template == "" {
+		opts.Template = DefaultTemplate
+	}
+	if opts.MaxPacketSize <= 0 {
+		opts.MaxPacketSize = DefaultMaxPacketSize
+	}
+
+	template, err := ParseTemplate(opts.Template)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsD{
+		opts:     opts,
+		template: template,
+		hostname: hostname(),
+		onError:  onError,
+	}, nil
+}
+
+// Consume sends the samples of a snapshot.
+func (s *StatsD) Consume(snapshot collector.Snapshot) {
+	host := hostOf(snapshot.Metrics.Host, s.hostname)
+
+	var packet []byte
+	for _, sample := range snapshot.Samples() {
+		path := s.template.Path(sample, host)
+		var line []byte
+		if sample.Value < 0 {
+			// A signed value changes a gauge instead of setting it, so it is reset first
+			line = appendGauge(line, path, 0)
+		}
+		line = appendGauge(line, path, sample.Value)
+
+		if len(packet) > 0 && len(packet)+len(line) > s.opts.MaxPacketSize {
+			s.send(packet)
+			packet = packet[:0]
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		s.send(packet)
+	}
+}
+
+// appendGauge appends a "path:value|g" line to b.
+func appendGauge(b []byte, path string, value float64) []byte {
+	b = append(b, path...)
+	b = append(b, ':')
+	b = strconv.AppendFloat(b, value, 'f', -1, 64)
+	return append(b, "|g\n"...)
+}
+
+// send writes one datagram without its trailing newline. The socket is
+// reopened after a failed write, so a changed address of the server is picked up.
+func (s *StatsD) send(packet []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, err := net.Dial("udp", s.opts.Address)
+		if err != nil {
+			s.onError(fmt.Errorf("StatsD: %w", err))
+			return
+		}
+		s.conn = conn
+	}
+	if _, err := s.conn.Write(packet[:len(packet)-1]); err != nil {
+		s.onError(fmt.Errorf("StatsD: %w", err))
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// Close closes the socket.
+func (s *StatsD) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,192 @@
+// Package emit sends every collected metric to StatsD over UDP and to Graphite
+// over TCP, named by a path template.
+package emit
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+// DefaultTemplate is the path template used when none is configured.
+const DefaultTemplate = "syscapture.{host}.{metric}"
+
+// placeholders are the names a template may refer to. Sample labels that are
+// not referred to are appended to the path.
+var placeholders = map[string]bool{
+	"host":     true, // Host name
+	"platform": true, // Host platform, e.g. ubuntu
+	"os":       true, // Host operating system, e.g. linux
+	"metric":   true, // Metric name, e.g. cpu.usage_percent
+	"group":    true, // Metric group, e.g. cpu
+	"field":    true, // Metric name within the group, e.g. usage_percent
+	"device":   true, // Disk device label
+	"sensor":   true, // Temperature sensor label
+}
+
+// Template builds the dotted path of a sample from literal text and
+// placeholders such as "servers.{platform}.{metric}". Labels of a sample the
+// template does not refer to are appended, so every series gets its own path.
+type Template struct {
+	parts []templatePart
+	used  map[string]bool
+}
+
+// templatePart is literal text or, when name is set, a placeholder.
+type templatePart struct {
+	literal string
+	name    string
+}
+
+// ParseTemplate parses a path template. It must refer to {metric} or {field}.
+func ParseTemplate(s string) (*Template, error) {
+	t := &Template{used: make(map[string]bool)}
+	for rest := s; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			open = len(rest)
+		}
+		if strings.IndexFunc(rest[:open], func(r rune) bool { return r != '.' && !pathRune(r) }) >= 0 {
+			return nil, fmt.Errorf("template %q may only contain letters, digits, '.', '_' and '-' besides placeholders", s)
+		}
+		if open == len(rest) {
+			t.parts = append(t.parts, templatePart{literal: rest})
+			break
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder in %q", s)
+		}
+		name := rest[open+1 : open+end]
+		if !placeholders[name] {
+			return nil, fmt.Errorf("unknown placeholder {%s}, use one of %s", name, placeholderList())
+		}
+		if open > 0 {
+			t.parts = append(t.parts, templatePart{literal: rest[:open]})
+		}
+		t.parts = append(t.parts, templatePart{name: name})
+		t.used[name] = true
+		rest = rest[open+end+1:]
+	}
+	if !t.used["metric"] && !t.used["field"] {
+		return nil, fmt.Errorf("template %q must contain {metric} or {field}", s)
+	}
+	return t, nil
+}
+
+// placeholderList returns the known placeholders, sorted.
+func placeholderList() string {
+	names := make([]string, 0, len(placeholders))
+	for name := range placeholders {
+		names = append(names, "{"+name+"}")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Path returns the path of a sample collected on host. Placeholder values are
+// sanitized to letters, digits, '_' and '-', and empty path components are dropped.
+func (t *Template) Path(s metric.Sample, host Host) string {
+	group, field, _ := strings.Cut(s.Name, ".")
+	value := func(name string) string {
+		switch name {
+		case "host":
+			return sanitize(host.Name)
+		case "platform":
+			return sanitize(host.Platform)
+		case "os":
+			return sanitize(host.OS)
+		case "metric":
+			return sanitizePath(s.Name)
+		case "group":
+			return sanitize(group)
+		case "field":
+			return sanitizePath(field)
+		default:
+			return sanitize(s.Labels[name])
+		}
+	}
+
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.name == "" {
+			b.WriteString(p.literal)
+		} else {
+			b.WriteString(value(p.name))
+		}
+	}
+
+	labels := make([]string, 0, len(s.Labels))
+	for name := range s.Labels {
+		if !t.used[name] {
+			labels = append(labels, name)
+		}
+	}
+	sort.Strings(labels)
+	for _, name := range labels {
+		b.WriteByte('.')
+		b.WriteString(value(name))
+	}
+
+	return joinComponents(strings.Split(b.String(), "."))
+}
+
+// Host holds the host information available to templates.
+type Host struct {
+	Name     string
+	Platform string
+	OS       string
+}
+
+// hostOf returns the template values of the host the metrics were collected on.
+func hostOf(m metric.HostData, name string) Host {
+	return Host{Name: name, Platform: m.Platform, OS: m.Os}
+}
+
+// hostname returns the host name, or "unknown".
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// sanitize replaces every character of a path component that is not a letter,
+// digit, '_' or '-' with '_', and trims leading and trailing '_'.
+func sanitize(s string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		if pathRune(r) {
+			return r
+		}
+		return '_'
+	}, s), "_")
+}
+
+// pathRune reports whether r may appear in a path component.
+func pathRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-'
+}
+
+// sanitizePath sanitizes each component of a dotted path.
+func sanitizePath(s string) string {
+	components := strings.Split(s, ".")
+	for i, c := range components {
+		components[i] = sanitize(c)
+	}
+	return joinComponents(components)
+}
+
+// joinComponents joins the non-empty components with dots.
+func joinComponents(components []string) string {
+	kept := components[:0]
+	for _, c := range components {
+		if c != "" {
+			kept = append(kept, c)
+		}
+	}
+	return strings.Join(kept, ".")
+}
@@ -0,0 +1,186 @@
+package forecast
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/storage"
+)
+
+const (
+	// DefaultWindow is the amount of history the growth trend is fitted on.
+	DefaultWindow = 7 * 24 * time.Hour
+
+	// minPoints is the minimum number of history points required for a forecast.
+	minPoints = 10
+
+	// cacheTTL is how long a forecast is reused before the history is read again.
+	cacheTTL = time.Minute
+)
+
+// Confidence levels of a forecast.
+const (
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+	ConfidenceHigh   = "high"
+)
+
+// DiskForecaster predicts when filesystems run out of space from the history of their free bytes.
+type DiskForecaster struct {
+	store  *storage.Store
+	window time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedForecast
+}
+
+type cachedForecast struct {
+	forecast *metric.DiskForecast
+	expires  time.Time
+}
+
+// NewDiskForecaster creates a forecaster reading the given window of history from store.
+func NewDiskForecaster(store *storage.Store, window time.Duration) *DiskForecaster {
+	return &DiskForecaster{
+		store:  store,
+		window: window,
+		cache:  make(map[string]cachedForecast),
+	}
+}
+
+// Annotate sets the forecast of every disk in disks. Disks without enough history are left untouched.
+func (f *DiskForecaster) Annotate(disks metric.MetricsSlice) {
+	if f == nil || f.store == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, d := range disks {
+		disk, ok := d.(*metric.DiskData)
+		if !ok {
+			continue
+		}
+		if forecast, err := f.Disk(disk.Device, now); err == nil {
+			disk.Forecast = forecast
+		}
+	}
+}
+
+// Disk returns the forecast for device at now, or nil if there is not enough history.
+func (f *DiskForecaster) Disk(device string, now time.Time) (*metric.DiskForecast, error) {
+	f.mu.Lock()
+	cached, ok := f.cache[device]
+	f.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.forecast, nil
+	}
+
+	// The resolution is explicit: picked from the range, a window as long as the
+	// retention of a tier would always be read from the next, coarser one
+	series, err := f.store.Query(storage.Query{
+		Name:       "disk.free_bytes",
+		Labels:     map[string]string{"device": device},
+		From:       now.Add(-f.window),
+		To:         now,
+		Resolution: f.store.ResolutionFor(f.window),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var forecast *metric.DiskForecast
+	if len(series) > 0 {
+		forecast = FreeBytes(series[0].Points, now)
+	}
+
+	f.mu.Lock()
+	f.cache[device] = cachedForecast{forecast: forecast, expires: now.Add(cacheTTL)}
+	f.mu.Unlock()
+	return forecast, nil
+}
+
+// FreeBytes fits a linear trend to the free bytes history and extrapolates the
+// time the free space reaches zero. It returns nil if there are fewer than
+// minPoints points.
+func FreeBytes(points []metric.Point, now time.Time) *metric.DiskForecast {
+	if len(points) < minPoints {
+		return nil
+	}
+
+	origin := points[0].Timestamp
+	xs := make([]float64, len(points))
+	ys := make([]float64, len(points))
+	for i, p := range points {
+		xs[i] = p.Timestamp.Sub(origin).Hours() / 24
+		ys[i] = p.Value
+	}
+
+	slope, intercept, r2 := linearRegression(xs, ys)
+	span := points[len(points)-1].Timestamp.Sub(origin)
+
+	forecast := &metric.DiskForecast{
+		GrowthBytesPerDay: metric.RoundFloat(-slope, 0),
+		Confidence:        confidence(r2, len(points), span),
+		Samples:           len(points),
+	}
+
+	// Free space is not shrinking, the disk is not predicted to fill up
+	if slope >= 0 {
+		return forecast
+	}
+
+	nowDays := now.Sub(origin).Hours() / 24
+	fullDays := -intercept / slope
+	days := math.Max(fullDays-nowDays, 0)
+
+	fullAt := now.Add(time.Duration(days * 24 * float64(time.Hour)))
+	forecast.PredictedFullAt = &fullAt
+	forecast.DaysUntilFull = metric.RoundFloatPtr(days, 2)
+	return forecast
+}
+
+// linearRegression returns the least squares fit y = slope*x + intercept and its coefficient of determination.
+func linearRegression(xs, ys []float64) (float64, float64, float64) {
+	n := float64(len(xs))
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, sumY / n, 0
+	}
+
+	slope := (n*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - slope*sumX) / n
+
+	meanY := sumY / n
+	var ssTot, ssRes float64
+	for i := range xs {
+		predicted := slope*xs[i] + intercept
+		ssRes += (ys[i] - predicted) * (ys[i] - predicted)
+		ssTot += (ys[i] - meanY) * (ys[i] - meanY)
+	}
+	if ssTot == 0 {
+		return slope, intercept, 1
+	}
+	return slope, intercept, 1 - ssRes/ssTot
+}
+
+// confidence grades a fit by how well the trend explains the history and how much history there is.
+func confidence(r2 float64, points int, span time.Duration) string {
+	switch {
+	case r2 >= 0.9 && points >= 100 && span >= 24*time.Hour:
+		return ConfidenceHigh
+	case r2 >= 0.6 && span >= 6*time.Hour:
+		return ConfidenceMedium
+	default:
+		return ConfidenceLow
+	}
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/anomaly"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+// Anomalies responds with the anomalies detected since the 'since' query parameter (def. 24 hours ago).
+func Anomalies(c *gin.Context, detector *anomaly.Detector) {
+	if detector == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Anomaly detection is disabled"})
+		return
+	}
+
+	since, err := parseTime(c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'since' parameter: " + err.Error()})
+		return
+	}
+	if since.IsZero() {
+		since = time.Now().Add(-24 * time.Hour)
+	}
+
+	respond(c, http.StatusOK, metric.APIResponse{
+		Data:   detector.Anomalies(since),
+		Errors: nil,
+	})
+}
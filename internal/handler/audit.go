@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/audit"
+)
+
+// Limits of the number of audit entries returned at once.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// Audit responds with the audit log entries matching the since, until, token,
+// status and failed query parameters, oldest first.
+func Audit(c *gin.Context, log *audit.Log) {
+	if log == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Audit log is disabled"})
+		return
+	}
+
+	query := audit.Query{
+		Token:  c.Query("token"),
+		Failed: c.Query("failed") == "true",
+		Limit:  defaultAuditLimit,
+	}
+
+	var err error
+	if query.Since, err = parseTime(c.Query("since")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'since' parameter: " + err.Error()})
+		return
+	}
+	if query.Until, err = parseTime(c.Query("until")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'until' parameter: " + err.Error()})
+		return
+	}
+	if value := c.Query("status"); value != "" {
+		if query.Status, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'status' must be an HTTP status code"})
+			return
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'limit' must be between 1 and " + strconv.Itoa(maxAuditLimit)})
+			return
+		}
+		query.Limit = limit
+	}
+
+	entries, err := log.Query(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": entries})
+}
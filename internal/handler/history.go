@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/negotiate"
+	"github.com/nodebytehosting/syscapture/internal/storage"
+)
+
+// historyParams are the query parameters that are not treated as label filters.
+var historyParams = map[string]bool{
+	"metric":     true,
+	"from":       true,
+	"to":         true,
+	"resolution": true,
+}
+
+// MetricsHistory responds with the stored history of a metric. With an Accept
+// header of application/x-ndjson every point is written as a line of its own.
+// Query parameters other than metric, from, to and resolution filter the series by label,
+// e.g. /metrics/history?metric=disk.free_bytes&device=/dev/sda1.
+func MetricsHistory(c *gin.Context, store *storage.Store) {
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "History storage is disabled"})
+		return
+	}
+
+	query, err := parseHistoryQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	series, err := store.Query(query)
+	if err != nil {
+		if errors.Is(err, storage.ErrUnknownResolution) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	mediaType := negotiate.Negotiate(c.GetHeader("Accept"), negotiate.JSON, negotiate.CBOR, negotiate.MessagePack, negotiate.NDJSON)
+	if mediaType == negotiate.NDJSON {
+		writeHistoryLines(c, series)
+		return
+	}
+	respondAs(c, http.StatusOK, mediaType, metric.APIResponse{
+		Data:   series,
+		Errors: nil,
+	})
+}
+
+// historyLine is a point of a series as written in NDJSON responses.
+type historyLine struct {
+	Name       string            `json:"name"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Resolution string            `json:"resolution"`
+	metric.Point
+}
+
+// writeHistoryLines writes every point of the series as one line of JSON, so
+// that clients can process long histories without holding them in memory.
+func writeHistoryLines(c *gin.Context, series metric.SeriesSlice) {
+	c.Writer.Header().Add("Vary", "Accept")
+	c.Header("Content-Type", negotiate.NDJSON)
+	c.Status(http.StatusOK)
+
+	for _, s := range series {
+		for _, p := range s.Points {
+			line := historyLine{Name: s.Name, Labels: s.Labels, Resolution: s.Resolution, Point: p}
+			if err := negotiate.Encode(c.Writer, negotiate.NDJSON, line); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// parseHistoryQuery builds a storage query from the request's query parameters.
+func parseHistoryQuery(c *gin.Context) (storage.Query, error) {
+	query := storage.Query{
+		Name:       c.Query("metric"),
+		Resolution: c.Query("resolution"),
+		Labels:     make(map[string]string),
+	}
+	if query.Name == "" {
+		return query, errors.New("query parameter 'metric' is required")
+	}
+
+	var err error
+	if query.From, err = parseTime(c.Query("from")); err != nil {
+		return query, errors.New("invalid 'from' parameter: " + err.Error())
+	}
+	if query.To, err = parseTime(c.Query("to")); err != nil {
+		return query, errors.New("invalid 'to' parameter: " + err.Error())
+	}
+
+	for key, values := range c.Request.URL.Query() {
+		if !historyParams[key] && len(values) > 0 {
+			query.Labels[key] = values[0]
+		}
+	}
+	return query, nil
+}
+
+// parseTime parses an RFC 3339 timestamp, a unix timestamp in seconds or a
+// duration relative to now such as "-6h". An empty value yields the zero time.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(d), nil
+	}
+	return time.Time{}, errors.New("expected an RFC 3339 timestamp, unix seconds or a relative duration")
+}
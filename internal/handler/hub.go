@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/hub"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+// defaultTopLimit is the number of nodes returned by FleetTop by default.
+const defaultTopLimit = 10
+
+// pushRequest is the body of a metrics push, the response body of GET /api/v1/metrics.
+type pushRequest struct {
+	Data   metric.AllMetrics  `json:"data"`
+	Errors []metric.CustomErr `json:"errors"`
+}
+
+// ListNodes responds with the nodes known to the hub and their reachability.
+func ListNodes(c *gin.Context, h *hub.Hub) {
+	respond(c, http.StatusOK, metric.APIResponse{
+		Data:   h.Nodes(),
+		Errors: nil,
+	})
+}
+
+// GetNode responds with a node and its latest metrics.
+func GetNode(c *gin.Context, h *hub.Hub) {
+	node, ok := h.Node(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown node"})
+		return
+	}
+	respond(c, http.StatusOK, metric.APIResponse{
+		Data:   node,
+		Errors: nil,
+	})
+}
+
+// NodeMetrics responds with the latest metrics of a node like GET /api/v1/metrics
+// on the node itself. Nodes without metrics respond with 503 Service Unavailable.
+func NodeMetrics(c *gin.Context, h *hub.Hub) {
+	node, ok := h.Node(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown node"})
+		return
+	}
+	if node.Metrics == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No metrics received from the node yet"})
+		return
+	}
+	handleMetricResponse(c, node.Metrics, node.Errors)
+}
+
+// PushNodeMetrics records the metrics pushed by a node, sent in the format of
+// the GET /api/v1/metrics response.
+func PushNodeMetrics(c *gin.Context, h *hub.Hub) {
+	var req pushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	err := h.Push(c.Param("name"), req.Data, req.Errors)
+	switch {
+	case errors.Is(err, hub.ErrInvalidName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, hub.ErrPolled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// RegisterNode adds the node sending its registration to the inventory, or
+// updates it, and responds with the node.
+func RegisterNode(c *gin.Context, h *hub.Hub) {
+	var req hub.Registration
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	node, err := h.Register(c.Param("name"), req)
+	switch {
+	case errors.Is(err, hub.ErrInvalidName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, hub.ErrPolled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		respond(c, http.StatusOK, metric.APIResponse{
+			Data:   node,
+			Errors: nil,
+		})
+	}
+}
+
+// NodeHeartbeat records the heartbeat of a registered node. Unknown nodes
+// respond with 404 Not Found, telling the agent to register again.
+func NodeHeartbeat(c *gin.Context, h *hub.Hub) {
+	var req hub.Heartbeat
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	err := h.Heartbeat(c.Param("name"), req)
+	switch {
+	case errors.Is(err, hub.ErrNotRegistered):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Node is not registered"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// FleetTop responds with the nodes with the highest usage of the resource
+// named by the 'by' query parameter (cpu, memory or disk, def. cpu), up to
+// 'limit' of them (def. 10). Nodes with stale metrics are left out.
+func FleetTop(c *gin.Context, h *hub.Hub) {
+	limit := defaultTopLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'limit' must be a positive number"})
+			return
+		}
+		limit = n
+	}
+
+	ranking, err := h.Top(c.DefaultQuery("by", hub.ByCPU), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	respond(c, http.StatusOK, metric.APIResponse{
+		Data:   ranking,
+		Errors: nil,
+	})
+}
+
+// FleetSummary responds with the totals of the fleet.
+func FleetSummary(c *gin.Context, h *hub.Hub) {
+	respond(c, http.StatusOK, metric.APIResponse{
+		Data:   h.Summary(),
+		Errors: nil,
+	})
+}
@@ -1,50 +1,116 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/forecast"
+	"github.com/nodebytehosting/syscapture/internal/influx"
 	"github.com/nodebytehosting/syscapture/internal/metric"
 )
 
-// handleMetricResponse sends a JSON response with the collected metrics and any errors.
+// handleMetricResponse sends a response with the collected metrics and any errors.
 func handleMetricResponse(c *gin.Context, metrics metric.Metric, errs []metric.CustomErr) {
 	statusCode := http.StatusOK
 	if len(errs) > 0 {
 		statusCode = http.StatusMultiStatus
 	}
-	c.JSON(statusCode, metric.APIResponse{
+	respond(c, statusCode, metric.APIResponse{
 		Data:   metrics,
 		Errors: errs,
 	})
 }
 
-// Metrics collects and responds with all system metrics.
-func Metrics(c *gin.Context) {
-	metrics, metricsErrs := metric.GetAllSystemMetrics()
-	handleMetricResponse(c, metrics, metricsErrs)
+// Metrics collects and responds with all system metrics, as JSON, CBOR or
+// MessagePack depending on the Accept header or, with ?format=influx, as
+// InfluxDB line protocol.
+// Disks are annotated with their disk-full forecast when history is available.
+// Requests without 'fields' and 'device' parameters get the defaults.
+func Metrics(c *gin.Context, forecaster *forecast.DiskForecaster, defaults metric.Filter) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "influx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported format %q, use json or influx", format)})
+		return
+	}
+
+	filter, metrics, metricsErrs, ok := collectFiltered(c, "", forecaster, defaults)
+	if !ok {
+		return
+	}
+
+	if format == "influx" {
+		// Line protocol has no room for errors, the metrics that could be collected are returned
+		var tags map[string]string
+		if metrics.Host.Platform != "" {
+			tags = map[string]string{"platform": metrics.Host.Platform}
+		}
+		c.Data(http.StatusOK, influx.ContentType, influx.AppendSamples(nil, filter.Samples(metrics), time.Now(), tags))
+		return
+	}
+	handleMetricResponse(c, filter.Select(metrics), filter.Errors(metricsErrs))
 }
 
 // MetricsCPU collects and responds with CPU metrics.
-func MetricsCPU(c *gin.Context) {
-	cpuMetrics, metricsErrs := metric.CollectCPUMetrics()
-	handleMetricResponse(c, cpuMetrics, metricsErrs)
+func MetricsCPU(c *gin.Context, defaults metric.Filter) {
+	metricsGroup(c, "cpu", nil, defaults)
 }
 
 // MetricsMemory collects and responds with memory metrics.
-func MetricsMemory(c *gin.Context) {
-	memoryMetrics, metricsErrs := metric.CollectMemoryMetrics()
-	handleMetricResponse(c, memoryMetrics, metricsErrs)
+func MetricsMemory(c *gin.Context, defaults metric.Filter) {
+	metricsGroup(c, "memory", nil, defaults)
 }
 
 // MetricsDisk collects and responds with disk metrics.
-func MetricsDisk(c *gin.Context) {
-	diskMetrics, metricsErrs := metric.CollectDiskMetrics()
-	handleMetricResponse(c, diskMetrics, metricsErrs)
+// Disks are annotated with their disk-full forecast when history is available.
+func MetricsDisk(c *gin.Context, forecaster *forecast.DiskForecaster, defaults metric.Filter) {
+	metricsGroup(c, "disk", forecaster, defaults)
 }
 
 // MetricsHost collects and responds with host information.
-func MetricsHost(c *gin.Context) {
-	hostMetrics, metricsErrs := metric.GetHostInformation()
-	handleMetricResponse(c, hostMetrics, metricsErrs)
+func MetricsHost(c *gin.Context, defaults metric.Filter) {
+	metricsGroup(c, "host", nil, defaults)
+}
+
+// metricsGroup collects and responds with the metrics of a group, restricted
+// to the fields and devices of the defaults within the group if the request
+// selects none.
+func metricsGroup(c *gin.Context, group string, forecaster *forecast.DiskForecaster, defaults metric.Filter) {
+	filter, metrics, metricsErrs, ok := collectFiltered(c, group, forecaster, defaults)
+	if !ok {
+		return
+	}
+	handleMetricResponse(c, filter.Select(metrics), filter.Errors(metricsErrs))
+}
+
+// collectFiltered runs the collectors needed for the 'fields' and 'device'
+// query parameters, or for the defaults without them. It responds with an error
+// and returns false if the parameters are invalid or no disk matches the
+// requested devices.
+func collectFiltered(c *gin.Context, group string, forecaster *forecast.DiskForecaster, defaults metric.Filter) (metric.Filter, metric.AllMetrics, []metric.CustomErr, bool) {
+	filter, err := requestFilter(c, group, defaults)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return filter, metric.AllMetrics{}, nil, false
+	}
+
+	metrics, metricsErrs := metric.CollectFiltered(filter)
+	if filter.HasDevices() && filter.Includes("disk") && len(metrics.Disk) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no disk matches the requested devices"})
+		return filter, metrics, nil, false
+	}
+	forecaster.Annotate(metrics.Disk)
+	return filter, metrics, metricsErrs, true
+}
+
+// requestFilter parses the 'fields' and 'device' query parameters of a request
+// to the route of group, all groups when empty. Without either parameter the
+// part of defaults within the group applies.
+func requestFilter(c *gin.Context, group string, defaults metric.Filter) (metric.Filter, error) {
+	fields, devices := c.QueryArray("fields"), c.QueryArray("device")
+	if len(fields) == 0 && len(devices) == 0 {
+		return defaults.Within(group), nil
+	}
+	return metric.ParseFilter(group, fields, devices)
 }
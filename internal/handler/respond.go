@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/negotiate"
+)
+
+// respond sends v encoded as JSON, CBOR or MessagePack, whichever the Accept
+// header of the request prefers. JSON is used when it accepts none of them.
+func respond(c *gin.Context, statusCode int, v any) {
+	respondAs(c, statusCode, negotiate.Negotiate(c.GetHeader("Accept"), negotiate.JSON, negotiate.CBOR, negotiate.MessagePack), v)
+}
+
+// respondAs sends v in the given media type.
+func respondAs(c *gin.Context, statusCode int, mediaType string, v any) {
+	c.Writer.Header().Add("Vary", "Accept")
+	if mediaType == negotiate.JSON {
+		c.JSON(statusCode, v)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := negotiate.Encode(&buf, mediaType, v); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(statusCode, mediaType, buf.Bytes())
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/negotiate"
+)
+
+const (
+	// maxStreamInterval is the longest interval a client can ask for between two events.
+	maxStreamInterval = 5 * time.Minute
+
+	// keepaliveInterval is how often a comment is sent to keep idle connections and proxies alive.
+	keepaliveInterval = 15 * time.Second
+
+	// streamRetry is the reconnection delay suggested to clients, in milliseconds.
+	streamRetry = 5000
+
+	// eventStreamType is the media type of Server-Sent Events.
+	eventStreamType = "text/event-stream"
+)
+
+// MetricsStream pushes all system metrics as Server-Sent Events.
+// The 'interval' query parameter selects the seconds between events, from the
+// collection interval up to 5 minutes. Clients reconnecting with a Last-Event-ID
+// header first receive the snapshots they missed that are still buffered.
+// The 'fields' and 'device' query parameters narrow down the events like on
+// /metrics, the filter of the collector applying without them.
+// With an Accept header of application/x-ndjson the responses are written as
+// lines of JSON instead, idle connections being kept alive with empty lines.
+func MetricsStream(c *gin.Context, col *collector.Collector) {
+	interval, err := streamInterval(c.Query("interval"), col.Interval())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := requestFilter(c, "", col.Filter())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var lastID uint64
+	if header := c.GetHeader("Last-Event-ID"); header != "" {
+		if lastID, err = strconv.ParseUint(header, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'Last-Event-ID' header"})
+			return
+		}
+	}
+
+	// Subscribe before replaying so nothing collected in between is lost
+	snapshots, unsubscribe := col.Subscribe()
+	defer unsubscribe()
+
+	ndjson := negotiate.Negotiate(c.GetHeader("Accept"), eventStreamType, negotiate.NDJSON) == negotiate.NDJSON
+	keepaliveLine := ": keepalive\n\n"
+	if ndjson {
+		c.Header("Content-Type", negotiate.NDJSON)
+		keepaliveLine = "\n"
+	} else {
+		c.Header("Content-Type", eventStreamType)
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable response buffering in NGINX
+	c.Status(http.StatusOK)
+
+	stream := &eventStream{w: c.Writer, ndjson: ndjson, filter: filter, interval: interval, tolerance: col.Interval() / 2}
+	if !ndjson {
+		if _, err := fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetry); err != nil {
+			return
+		}
+	}
+
+	if lastID > 0 {
+		stream.lastID = lastID
+		for _, s := range col.Since(lastID) {
+			if err := stream.send(s); err != nil {
+				return
+			}
+		}
+	} else if latest, ok := col.Latest(); ok {
+		if err := stream.send(latest); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case s := <-snapshots:
+			if err := stream.send(s); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := io.WriteString(c.Writer, keepaliveLine); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// streamInterval parses the requested interval in seconds, defaulting to the collection interval.
+func streamInterval(value string, minimum time.Duration) (time.Duration, error) {
+	if value == "" {
+		return minimum, nil
+	}
+
+	seconds, err := strconv.Atoi(value)
+	interval := time.Duration(seconds) * time.Second
+	if err != nil || interval < minimum || interval > maxStreamInterval {
+		return 0, fmt.Errorf("'interval' must be between %d and %d seconds",
+			int(minimum.Seconds()), int(maxStreamInterval.Seconds()))
+	}
+	return interval, nil
+}
+
+// eventStream writes snapshots as events, thinning them out to the requested interval.
+type eventStream struct {
+	w         io.Writer
+	ndjson    bool // Whether to write lines of JSON instead of events
+	filter    metric.Filter
+	interval  time.Duration
+	tolerance time.Duration // Allowed jitter of the collection timestamps
+	lastID    uint64
+	lastSent  time.Time
+}
+
+// send writes the snapshot as a 'metrics' event, or a line, unless it was already sent or
+// is too close to the previous event.
+func (s *eventStream) send(snapshot collector.Snapshot) error {
+	if snapshot.ID <= s.lastID {
+		return nil
+	}
+	if !s.lastSent.IsZero() && snapshot.Timestamp.Sub(s.lastSent) < s.interval-s.tolerance {
+		return nil
+	}
+
+	data, err := json.Marshal(metric.APIResponse{
+		Data:   s.filter.Select(snapshot.Metrics),
+		Errors: s.filter.Errors(snapshot.Errors),
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.ndjson {
+		_, err = fmt.Fprintf(s.w, "%s\n", data)
+	} else {
+		_, err = fmt.Fprintf(s.w, "id: %d\nevent: metrics\ndata: %s\n\n", snapshot.ID, data)
+	}
+	if err != nil {
+		return err
+	}
+	s.lastID = snapshot.ID
+	s.lastSent = snapshot.Timestamp
+	return nil
+}
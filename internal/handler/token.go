@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/token"
+)
+
+// tokenView is the representation of a token returned by the API. It never contains the hash.
+type tokenView struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Static    bool       `json:"static"` // Configured through the environment, cannot be deleted
+}
+
+// createTokenRequest is the body of a token creation request.
+type createTokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"` // Absolute expiry time
+	TTL       string     `json:"ttl"`        // Relative expiry, e.g. "720h"
+}
+
+func newTokenView(t token.Token) tokenView {
+	return tokenView{
+		Name:      t.Name,
+		Scopes:    t.Scopes,
+		CreatedAt: t.CreatedAt,
+		ExpiresAt: t.ExpiresAt,
+		Static:    t.Static,
+	}
+}
+
+// ListTokens responds with all API tokens.
+func ListTokens(c *gin.Context, store *token.Store) {
+	tokens := store.List()
+	views := make([]tokenView, 0, len(tokens))
+	for _, t := range tokens {
+		views = append(views, newTokenView(t))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": views})
+}
+
+// CreateToken creates an API token and responds with its secret, which is only shown once.
+func CreateToken(c *gin.Context, store *token.Store) {
+	var req createTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	expiresAt := req.ExpiresAt
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'ttl' must be a positive duration such as '720h'"})
+			return
+		}
+		expires := time.Now().Add(ttl).UTC()
+		expiresAt = &expires
+	}
+
+	t, secret, err := store.Create(req.Name, req.Scopes, expiresAt)
+	if errors.Is(err, token.ErrExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":   newTokenView(*t),
+		"secret": secret,
+	})
+}
+
+// DeleteToken revokes an API token.
+func DeleteToken(c *gin.Context, store *token.Store) {
+	err := store.Delete(c.Param("name"))
+	switch {
+	case errors.Is(err, token.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, token.ErrStatic):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,353 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/nodebytehosting/syscapture/internal/anomaly"
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+const (
+	// wsProtocol is the subprotocol spoken on the WebSocket endpoint.
+	wsProtocol = "syscapture.v1"
+
+	// wsTokenPrefix prefixes a base64url encoded token offered as a subprotocol.
+	wsTokenPrefix = "bearer."
+
+	// wsAlertsChannel is the channel carrying detected anomalies.
+	wsAlertsChannel = "alerts"
+
+	wsAuthTimeout  = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+	wsWriteTimeout = 10 * time.Second
+	wsMaxMessage   = 4096
+)
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: []string{wsProtocol},
+	// Authentication is token based, never cookie based, so cross-origin
+	// connections from control panels are allowed.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// wsRequest is a message sent by the client.
+type wsRequest struct {
+	Type     string `json:"type"`               // auth, subscribe or unsubscribe
+	Token    string `json:"token,omitempty"`    // Token of an auth message
+	Channel  string `json:"channel,omitempty"`  // Channel to (un)subscribe, e.g. cpu or disk./dev/sda1
+	Interval int    `json:"interval,omitempty"` // Seconds between updates of a subscription
+
+	invalid error // Set when the message could not be decoded
+}
+
+// wsMessage is a message sent to the client.
+type wsMessage struct {
+	Type      string              `json:"type"`                // authenticated, subscribed, unsubscribed, update or error
+	Channel   string              `json:"channel,omitempty"`   // Channel the message belongs to
+	Interval  int                 `json:"interval,omitempty"`  // Seconds between updates of a subscription
+	Timestamp *time.Time          `json:"timestamp,omitempty"` // Collection time of an update
+	Data      map[string]any      `json:"data,omitempty"`      // Values changed since the previous update of the channel
+	Anomalies metric.AnomalySlice `json:"anomalies,omitempty"` // New anomalies of the alerts channel
+	Error     string              `json:"error,omitempty"`     // Error description
+}
+
+// wsSubscription tracks what was last sent on a channel.
+type wsSubscription struct {
+	interval time.Duration
+	nextDue  time.Time
+	sent     map[string]any // Last value sent per key, to compute deltas
+	since    time.Time      // Anomalies after this time are new (alerts channel)
+}
+
+// MetricsWebSocket serves per-channel metric subscriptions over a WebSocket.
+//
+// Browsers cannot set the Authorization header, so the token is either offered as a
+// "bearer.<base64url token>" subprotocol next to "syscapture.v1", or sent in a first
+// {"type":"auth","token":"..."} message. Clients then send {"type":"subscribe",
+// "channel":"cpu","interval":5} and receive updates holding only the values that
+// changed since the previous update of that channel. Connections already
+// authenticated, e.g. by their client certificate, skip the token.
+func MetricsWebSocket(c *gin.Context, col *collector.Collector, detector *anomaly.Detector, authenticated bool, authenticate func(string) error) {
+	for _, protocol := range websocket.Subprotocols(c.Request) {
+		if authenticated || !strings.HasPrefix(protocol, wsTokenPrefix) {
+			continue
+		}
+		token, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(protocol, wsTokenPrefix))
+		if err != nil || authenticate(string(token)) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid token provided"})
+			return
+		}
+		authenticated = true
+	}
+
+	// The upgrader writes the error response itself
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	session := &wsSession{
+		ctx:      c.Request.Context(),
+		conn:     conn,
+		col:      col,
+		detector: detector,
+		subs:     make(map[string]*wsSubscription),
+	}
+	session.serve(authenticated, authenticate)
+}
+
+// wsSession is a single WebSocket connection.
+type wsSession struct {
+	ctx      context.Context
+	conn     *websocket.Conn
+	col      *collector.Collector
+	detector *anomaly.Detector
+	subs     map[string]*wsSubscription
+}
+
+// serve authenticates the connection if needed and then runs the session until
+// the client disconnects.
+func (s *wsSession) serve(authenticated bool, authenticate func(string) error) {
+	s.conn.SetReadLimit(wsMaxMessage)
+
+	if !authenticated {
+		if err := s.conn.SetReadDeadline(time.Now().Add(wsAuthTimeout)); err != nil {
+			return
+		}
+		var req wsRequest
+		if err := s.conn.ReadJSON(&req); err != nil {
+			return
+		}
+		if req.Type != "auth" || authenticate(req.Token) != nil {
+			s.close(websocket.ClosePolicyViolation, "Invalid token provided")
+			return
+		}
+	}
+	if s.write(wsMessage{Type: "authenticated"}) != nil {
+		return
+	}
+
+	// Reads happen on their own goroutine; everything else, including all
+	// writes, happens on this one.
+	requests := make(chan wsRequest)
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+	go s.read(requests, done, quit)
+
+	snapshots, unsubscribe := s.col.Subscribe()
+	defer unsubscribe()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case <-s.ctx.Done():
+			s.close(websocket.CloseGoingAway, "Server is shutting down")
+			return
+		case req := <-requests:
+			err = s.handle(req)
+		case snapshot := <-snapshots:
+			err = s.publish(snapshot)
+		case <-ping.C:
+			err = s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// read forwards client messages until the connection fails or the session quits.
+func (s *wsSession) read(requests chan<- wsRequest, done chan<- struct{}, quit <-chan struct{}) {
+	defer close(done)
+
+	resetDeadline := func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(2 * wsPingInterval))
+	}
+	s.conn.SetPongHandler(resetDeadline)
+	if resetDeadline("") != nil {
+		return
+	}
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req wsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			req = wsRequest{invalid: err}
+		}
+		select {
+		case requests <- req:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// handle applies a subscription change requested by the client.
+func (s *wsSession) handle(req wsRequest) error {
+	if req.invalid != nil {
+		return s.write(wsMessage{Type: "error", Error: "Invalid message: " + req.invalid.Error()})
+	}
+
+	switch req.Type {
+	case "subscribe":
+		if req.Channel == "" {
+			return s.write(wsMessage{Type: "error", Error: "'channel' is required"})
+		}
+		if req.Channel == wsAlertsChannel && s.detector == nil {
+			return s.write(wsMessage{Type: "error", Channel: req.Channel, Error: "Anomaly detection is disabled"})
+		}
+
+		interval := s.col.Interval()
+		if req.Interval != 0 {
+			var err error
+			if interval, err = streamInterval(strconv.Itoa(req.Interval), s.col.Interval()); err != nil {
+				return s.write(wsMessage{Type: "error", Channel: req.Channel, Error: err.Error()})
+			}
+		}
+
+		// Re-subscribing changes the interval and starts over with a full update
+		s.subs[req.Channel] = &wsSubscription{interval: interval, since: time.Now()}
+		if err := s.write(wsMessage{Type: "subscribed", Channel: req.Channel, Interval: int(interval.Seconds())}); err != nil {
+			return err
+		}
+		if latest, ok := s.col.Latest(); ok {
+			return s.publish(latest)
+		}
+		return nil
+	case "unsubscribe":
+		delete(s.subs, req.Channel)
+		return s.write(wsMessage{Type: "unsubscribed", Channel: req.Channel})
+	case "auth":
+		return s.write(wsMessage{Type: "error", Error: "Already authenticated"})
+	default:
+		return s.write(wsMessage{Type: "error", Error: fmt.Sprintf("Unknown message type '%s'", req.Type)})
+	}
+}
+
+// publish sends an update to every subscription that is due.
+func (s *wsSession) publish(snapshot collector.Snapshot) error {
+	values := snapshotValues(snapshot)
+	tolerance := s.col.Interval() / 2
+
+	for channel, sub := range s.subs {
+		if snapshot.Timestamp.Add(tolerance).Before(sub.nextDue) {
+			continue
+		}
+		sub.nextDue = snapshot.Timestamp.Add(sub.interval)
+
+		msg := wsMessage{Type: "update", Channel: channel, Timestamp: &snapshot.Timestamp}
+		if channel == wsAlertsChannel {
+			for _, a := range s.detector.Anomalies(sub.since) {
+				msg.Anomalies = append(msg.Anomalies, a)
+				sub.since = a.Timestamp.Add(time.Nanosecond)
+			}
+			if len(msg.Anomalies) == 0 {
+				continue
+			}
+		} else {
+			msg.Data = sub.delta(channel, values)
+			if len(msg.Data) == 0 {
+				continue
+			}
+		}
+
+		if err := s.write(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delta returns the values of the channel that changed since the previous update.
+func (sub *wsSubscription) delta(channel string, values map[string]any) map[string]any {
+	if sub.sent == nil {
+		sub.sent = make(map[string]any)
+	}
+
+	changed := make(map[string]any)
+	for key, value := range values {
+		if !matchChannel(channel, key) {
+			continue
+		}
+		if previous, ok := sub.sent[key]; ok && previous == value {
+			continue
+		}
+		sub.sent[key] = value
+		changed[key] = value
+	}
+	return changed
+}
+
+// matchChannel reports whether a series key belongs to a channel. A channel is a
+// collector (cpu), a metric (cpu.usage_percent), a series key, or a collector
+// followed by a label value (disk./dev/sda1, network.eth0).
+func matchChannel(channel, key string) bool {
+	if channel == key {
+		return true
+	}
+
+	name, labels := metric.ParseSeriesKey(key)
+	if name == channel || strings.HasPrefix(name, channel+".") {
+		return true
+	}
+
+	collectorName, value, found := strings.Cut(channel, ".")
+	if !found || !strings.HasPrefix(name, collectorName+".") {
+		return false
+	}
+	for _, v := range labels {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotValues flattens a snapshot into values keyed by series key,
+// including the non-numeric host information.
+func snapshotValues(snapshot collector.Snapshot) map[string]any {
+	samples := snapshot.Samples()
+	values := make(map[string]any, len(samples)+3)
+	for _, sample := range samples {
+		values[sample.Key()] = sample.Value
+	}
+
+	host := snapshot.Metrics.Host
+	values["host.os"] = host.Os
+	values["host.platform"] = host.Platform
+	values["host.kernel_version"] = host.KernelVersion
+	return values
+}
+
+// write sends a message to the client.
+func (s *wsSession) write(msg wsMessage) error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	return s.conn.WriteJSON(msg)
+}
+
+// close sends a close frame with the given code and reason.
+func (s *wsSession) close(code int, reason string) {
+	_ = s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteTimeout))
+}
@@ -0,0 +1,167 @@
+package hub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+// DefaultHeartbeatInterval is the default interval between two heartbeats of an agent.
+const DefaultHeartbeatInterval = 30 * time.Second
+
+// AgentOptions configure an Agent.
+type AgentOptions struct {
+	HubURL       string                            // Base URL of the hub
+	Token        string                            // Bearer token with the nodes:write scope
+	Name         string                            // Name of the node
+	Interval     time.Duration                     // Interval between heartbeats
+	Timeout      time.Duration                     // Timeout of a request, 10 seconds when zero
+	Registration func() Registration               // Returns what the agent announces
+	Summary      func() (metric.NodeSummary, bool) // Returns the usage sent in heartbeats, if known yet
+	Client       *http.Client                      // HTTP client, a default client when nil
+}
+
+// Agent registers a node with a hub and keeps it up to date with heartbeats.
+type Agent struct {
+	opts       AgentOptions
+	client     *http.Client
+	registered bool
+}
+
+// NewAgent creates an Agent.
+func NewAgent(opts AgentOptions) *Agent {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultHeartbeatInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+	opts.HubURL = strings.TrimSuffix(opts.HubURL, "/")
+	return &Agent{opts: opts, client: client}
+}
+
+// Run registers the node and sends a heartbeat each interval until ctx is
+// cancelled. Failed registrations are retried each interval, and the node
+// registers again when the hub no longer knows it. Failures are passed to
+// onError.
+func (a *Agent) Run(ctx context.Context, onError func(error)) {
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.Report(ctx); err != nil && ctx.Err() == nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Report registers the node if it is not registered yet and sends a heartbeat.
+func (a *Agent) Report(ctx context.Context) error {
+	if !a.registered {
+		if err := a.register(ctx); err != nil {
+			return err
+		}
+	}
+
+	err := a.heartbeat(ctx)
+	if errors.Is(err, ErrNotRegistered) {
+		// The hub forgot the node, e.g. after a restart
+		a.registered = false
+		if err := a.register(ctx); err != nil {
+			return err
+		}
+		err = a.heartbeat(ctx)
+	}
+	return err
+}
+
+// register announces the node to the hub.
+func (a *Agent) register(ctx context.Context) error {
+	r := a.opts.Registration()
+	r.HeartbeatSeconds = heartbeatSeconds(a.opts.Interval)
+	if err := a.post(ctx, "register", r); err != nil {
+		return fmt.Errorf("unable to register with the hub: %w", err)
+	}
+	a.registered = true
+	return nil
+}
+
+// heartbeat sends the usage of the node, once it is known.
+func (a *Agent) heartbeat(ctx context.Context) error {
+	summary, ok := a.opts.Summary()
+	if !ok {
+		return nil
+	}
+	err := a.post(ctx, "heartbeat", Heartbeat{Summary: summary})
+	if err != nil && !errors.Is(err, ErrNotRegistered) {
+		return fmt.Errorf("unable to send heartbeat to the hub: %w", err)
+	}
+	return err
+}
+
+// post sends body as JSON to the node route action of the hub. A 404 Not
+// Found response is returned as ErrNotRegistered.
+func (a *Agent) post(ctx context.Context, action string, body any) error {
+	ctx, cancel := context.WithTimeout(ctx, a.opts.Timeout)
+	defer cancel()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	endpoint := a.opts.HubURL + "/api/v1/nodes/" + url.PathEscape(a.opts.Name) + "/" + action
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.opts.Token)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode == http.StatusNotFound && action == "heartbeat":
+		return ErrNotRegistered
+	}
+
+	var response struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&response)
+	if response.Error != "" {
+		return fmt.Errorf("hub responded with %s: %s", resp.Status, response.Error)
+	}
+	return fmt.Errorf("hub responded with %s", resp.Status)
+}
+
+// heartbeatSeconds returns interval in whole seconds, at least one.
+func heartbeatSeconds(interval time.Duration) int {
+	return max(int(interval/time.Second), 1)
+}
@@ -0,0 +1,420 @@
+// Package hub keeps the latest metrics of a fleet of agents, polled by the hub
+// or pushed by the agents, and aggregates them.
+package hub
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+const (
+	// DefaultInterval is the default interval between two polls of an agent.
+	DefaultInterval = 30 * time.Second
+
+	// DefaultConcurrency is how many agents are polled at once by default.
+	DefaultConcurrency = 64
+
+	// MetricsPath is the path of the agent API polled by the hub.
+	MetricsPath = "/api/v1/metrics"
+
+	// maxResponseSize is the largest metrics response read from an agent.
+	maxResponseSize = 4 << 20
+)
+
+// Rankings accepted by Top.
+const (
+	ByCPU    = "cpu"
+	ByMemory = "memory"
+	ByDisk   = "disk"
+)
+
+var (
+	// ErrInvalidName is returned for node names that are empty or hold other characters than letters, digits, '.', '_' and '-'.
+	ErrInvalidName = errors.New("node names may only contain letters, digits, '.', '_' and '-'")
+
+	// ErrPolled is returned when a node polled by the hub pushes metrics or registers.
+	ErrPolled = errors.New("node is polled by the hub")
+
+	// ErrNotRegistered is returned for heartbeats of nodes the hub does not know,
+	// which have to register again, e.g. after a restart of the hub.
+	ErrNotRegistered = errors.New("node is not registered")
+
+	// ErrUnknownRanking is returned by Top for an unknown ranking.
+	ErrUnknownRanking = errors.New("unknown ranking, use cpu, memory or disk")
+)
+
+// namePattern matches valid node names.
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,128}$`)
+
+// Options configure a Hub.
+type Options struct {
+	Agents      map[string]string // Base URLs of the agents to poll by node name
+	Token       string            // Bearer token sent to the agents
+	Interval    time.Duration     // Interval between two polls of an agent
+	Timeout     time.Duration     // Timeout of a poll, the interval when zero
+	StaleAfter  time.Duration     // Age after which metrics are stale, three intervals when zero
+	Concurrency int               // Agents polled at once
+	Client      *http.Client      // HTTP client, a default client when nil
+}
+
+// Hub keeps the latest metrics of every node and their reachability.
+type Hub struct {
+	opts   Options
+	client *http.Client
+	now    func() time.Time
+
+	mu    sync.RWMutex
+	nodes map[string]*node
+}
+
+// node is the state of a node.
+type node struct {
+	url          string
+	metrics      *metric.AllMetrics
+	errors       []metric.CustomErr
+	summary      *metric.NodeSummary
+	lastSeen     time.Time
+	lastError    string
+	reachable    bool
+	registration *Registration
+	registeredAt time.Time
+}
+
+// New creates a Hub polling the configured agents.
+func New(opts Options) *Hub {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = opts.Interval
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = 3 * opts.Interval
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	h := &Hub{opts: opts, client: client, now: time.Now, nodes: make(map[string]*node, len(opts.Agents))}
+	for name, url := range opts.Agents {
+		h.nodes[name] = &node{url: strings.TrimSuffix(url, "/")}
+	}
+	return h
+}
+
+// Run polls every agent each interval until ctx is cancelled.
+func (h *Hub) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		h.PollAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollAll polls every agent once, Concurrency of them at a time, and returns
+// once all polls are done.
+func (h *Hub) PollAll(ctx context.Context) {
+	h.mu.RLock()
+	targets := make(map[string]string)
+	for name, n := range h.nodes {
+		if n.url != "" {
+			targets[name] = n.url
+		}
+	}
+	h.mu.RUnlock()
+
+	slots := make(chan struct{}, h.opts.Concurrency)
+	var wg sync.WaitGroup
+	for name, url := range targets {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			h.poll(ctx, name, url)
+		}()
+	}
+	wg.Wait()
+}
+
+// poll fetches the metrics of an agent and records them, or the failure.
+func (h *Hub) poll(ctx context.Context, name string, url string) {
+	ctx, cancel := context.WithTimeout(ctx, h.opts.Timeout)
+	defer cancel()
+
+	m, errs, err := h.fetch(ctx, url)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, ok := h.nodes[name]
+	if !ok {
+		return
+	}
+	if err != nil {
+		n.reachable = false
+		n.lastError = err.Error()
+		return
+	}
+	n.reachable = true
+	n.lastError = ""
+	n.record(m, errs, h.now())
+}
+
+// record stores the metrics of a node received at now.
+func (n *node) record(m metric.AllMetrics, errs []metric.CustomErr, now time.Time) {
+	summary := metric.Summarize(m, errs)
+	n.metrics, n.errors, n.summary, n.lastSeen = &m, errs, &summary, now
+}
+
+// fetch requests the metrics of the agent at url.
+func (h *Hub) fetch(ctx context.Context, url string) (metric.AllMetrics, []metric.CustomErr, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+MetricsPath, nil)
+	if err != nil {
+		return metric.AllMetrics{}, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if h.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.opts.Token)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return metric.AllMetrics{}, nil, err
+	}
+	defer resp.Body.Close()
+
+	// 207 Multi-Status carries the metrics that could be collected
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusMultiStatus {
+		return metric.AllMetrics{}, nil, fmt.Errorf("agent responded with %s", resp.Status)
+	}
+
+	var response struct {
+		Data   metric.AllMetrics  `json:"data"`
+		Errors []metric.CustomErr `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&response); err != nil {
+		return metric.AllMetrics{}, nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return response.Data, response.Errors, nil
+}
+
+// ValidName reports whether name can name a node.
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// Push records the metrics a node pushed. Nodes that are not polled by the
+// hub are added on their first push.
+func (h *Hub) Push(name string, m metric.AllMetrics, errs []metric.CustomErr) error {
+	if !ValidName(name) {
+		return ErrInvalidName
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, ok := h.nodes[name]
+	if !ok {
+		n = &node{}
+		h.nodes[name] = n
+	}
+	if n.url != "" {
+		return ErrPolled
+	}
+	n.record(m, errs, h.now())
+	return nil
+}
+
+// Nodes returns every node without its metrics, ordered by name.
+func (h *Hub) Nodes() metric.NodeSlice {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	nodes := make(metric.NodeSlice, 0, len(h.nodes))
+	now := h.now()
+	for name, n := range h.nodes {
+		nodes = append(nodes, h.describe(name, n, now, false))
+	}
+	slices.SortFunc(nodes, func(a, b metric.Node) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return nodes
+}
+
+// Node returns a node with its latest metrics.
+func (h *Hub) Node(name string) (metric.Node, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	n, ok := h.nodes[name]
+	if !ok {
+		return metric.Node{}, false
+	}
+	return h.describe(name, n, h.now(), true), true
+}
+
+// describe returns the state of a node at now.
+func (h *Hub) describe(name string, n *node, now time.Time, withMetrics bool) metric.Node {
+	reachable := h.reachable(n, now)
+	described := metric.Node{
+		Name:      name,
+		Status:    metric.NodeOffline,
+		URL:       n.url,
+		Reachable: reachable,
+		Stale:     h.stale(n, now),
+		LastError: n.lastError,
+		Summary:   n.summary,
+	}
+	if reachable {
+		described.Status = metric.NodeOnline
+	}
+	if !n.lastSeen.IsZero() {
+		lastSeen := n.lastSeen
+		described.LastSeen = &lastSeen
+	}
+	if r := n.registration; r != nil {
+		host := r.Host
+		registeredAt := n.registeredAt
+		described.Version = r.Version
+		described.Host = &host
+		described.Address = r.Address
+		described.Listen = r.Listen
+		described.Capabilities = r.Capabilities
+		described.RegisteredAt = &registeredAt
+	}
+	if withMetrics {
+		described.Metrics = n.metrics
+		described.Errors = n.errors
+	}
+	return described
+}
+
+// stale reports whether a node never reported or its latest report is older
+// than its stale threshold: three heartbeat intervals for registered nodes
+// announcing one, StaleAfter otherwise.
+func (h *Hub) stale(n *node, now time.Time) bool {
+	staleAfter := h.opts.StaleAfter
+	if n.registration != nil && n.registration.HeartbeatSeconds > 0 {
+		staleAfter = 3 * time.Duration(n.registration.HeartbeatSeconds) * time.Second
+	}
+	return n.lastSeen.IsZero() || now.Sub(n.lastSeen) > staleAfter
+}
+
+// reachable reports whether the last poll of a polled node succeeded, or
+// whether another node reported recently.
+func (h *Hub) reachable(n *node, now time.Time) bool {
+	if n.url != "" {
+		return n.reachable
+	}
+	return !h.stale(n, now)
+}
+
+// Top returns up to limit nodes with a fresh summary ranked by their CPU
+// usage, memory usage or the usage of their fullest disk, highest first.
+func (h *Hub) Top(by string, limit int) (metric.NodeRanking, error) {
+	var value func(s *metric.NodeSummary) (float64, string, bool)
+	switch by {
+	case ByCPU:
+		value = func(s *metric.NodeSummary) (float64, string, bool) {
+			return s.CPUUsagePercent, "", true
+		}
+	case ByMemory:
+		value = func(s *metric.NodeSummary) (float64, string, bool) {
+			if s.MemoryUsagePercent == nil {
+				return 0, "", false
+			}
+			return *s.MemoryUsagePercent, "", true
+		}
+	case ByDisk:
+		value = func(s *metric.NodeSummary) (float64, string, bool) {
+			if s.DiskUsagePercent == nil {
+				return 0, "", false
+			}
+			return *s.DiskUsagePercent, s.DiskDevice, true
+		}
+	default:
+		return nil, ErrUnknownRanking
+	}
+
+	h.mu.RLock()
+	ranking := make(metric.NodeRanking, 0, len(h.nodes))
+	now := h.now()
+	for name, n := range h.nodes {
+		if n.summary == nil || h.stale(n, now) {
+			continue
+		}
+		if v, device, ok := value(n.summary); ok {
+			ranking = append(ranking, metric.NodeRank{Name: name, Value: v, Device: device})
+		}
+	}
+	h.mu.RUnlock()
+
+	slices.SortFunc(ranking, func(a, b metric.NodeRank) int {
+		if c := cmp.Compare(b.Value, a.Value); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	if limit > 0 && len(ranking) > limit {
+		ranking = ranking[:limit]
+	}
+	return ranking, nil
+}
+
+// Summary returns the totals of the fleet. Nodes with stale or no summaries are only counted.
+func (h *Hub) Summary() metric.FleetSummary {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	summary := metric.FleetSummary{Nodes: len(h.nodes)}
+	now := h.now()
+	var cpuTotal float64
+	var fresh int
+	for _, n := range h.nodes {
+		if h.reachable(n, now) {
+			summary.Reachable++
+		}
+		if h.stale(n, now) {
+			summary.Stale++
+			continue
+		}
+		if n.summary == nil {
+			continue
+		}
+
+		fresh++
+		cpuTotal += n.summary.CPUUsagePercent
+		summary.MemoryTotalBytes += n.summary.MemoryTotalBytes
+		summary.MemoryUsedBytes += n.summary.MemoryUsedBytes
+		summary.DiskTotalBytes += n.summary.DiskTotalBytes
+		summary.DiskFreeBytes += n.summary.DiskFreeBytes
+	}
+	if fresh > 0 {
+		summary.CPUUsagePercent = metric.RoundFloatPtr(cpuTotal/float64(fresh), 4)
+	}
+	return summary
+}
@@ -0,0 +1,53 @@
+package hub
+
+import "github.com/nodebytehosting/syscapture/internal/metric"
+
+// Registration is what an agent announces to the hub on startup.
+type Registration struct {
+	Version          string          `json:"version"`                    // Version of the agent
+	Host             metric.HostData `json:"host"`                       // Host of the agent
+	Address          string          `json:"address,omitempty"`          // URL the agent can be reached at
+	Listen           []string        `json:"listen"`                     // Addresses the agent listens on
+	Capabilities     []string        `json:"capabilities"`               // Features the agent serves
+	HeartbeatSeconds int             `json:"heartbeat_interval_seconds"` // Seconds between heartbeats
+}
+
+// Heartbeat is what an agent sends to the hub periodically after registering.
+type Heartbeat struct {
+	Summary metric.NodeSummary `json:"summary"`
+}
+
+// Register adds a node to the inventory, or updates it when it registers
+// again, and returns it.
+func (h *Hub) Register(name string, r Registration) (metric.Node, error) {
+	if !ValidName(name) {
+		return metric.Node{}, ErrInvalidName
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, ok := h.nodes[name]
+	if !ok {
+		n = &node{}
+		h.nodes[name] = n
+	}
+	if n.url != "" {
+		return metric.Node{}, ErrPolled
+	}
+	now := h.now()
+	n.registration, n.registeredAt, n.lastSeen = &r, now, now
+	return h.describe(name, n, now, false), nil
+}
+
+// Heartbeat records the summary sent by a registered node. ErrNotRegistered
+// is returned for nodes that have to register first.
+func (h *Hub) Heartbeat(name string, hb Heartbeat) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, ok := h.nodes[name]
+	if !ok || n.registration == nil {
+		return ErrNotRegistered
+	}
+	n.summary, n.lastSeen = &hb.Summary, h.now()
+	return nil
+}
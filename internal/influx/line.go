@@ -0,0 +1,109 @@
+// Package influx renders the collected metrics as InfluxDB line protocol and
+// pushes them to the InfluxDB v2 write API.
+package influx
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+// ContentType is the media type of line protocol.
+const ContentType = "text/plain; charset=utf-8"
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	keyEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+)
+
+// line is a point being built: the fields sharing a measurement and tag set.
+type line struct {
+	measurement string
+	tags        string // Encoded, sorted tag set including the leading comma
+	fields      []string
+}
+
+// AppendLines appends the samples of a snapshot to b as line protocol, tagged
+// with the host platform and the given tags. See AppendSamples.
+func AppendLines(b []byte, s collector.Snapshot, tags map[string]string) []byte {
+	if platform := s.Metrics.Host.Platform; platform != "" {
+		withPlatform := make(map[string]string, len(tags)+1)
+		for k, v := range tags {
+			withPlatform[k] = v
+		}
+		withPlatform["platform"] = platform
+		tags = withPlatform
+	}
+	return AppendSamples(b, s.Samples(), s.Timestamp, tags)
+}
+
+// AppendSamples appends the samples at ts to b as line protocol. The part of a
+// sample name before the first dot is the measurement and the rest the field,
+// e.g. "cpu usage_percent=12.5". Sample labels become tags, as do the given
+// tags; sample labels win over the given tags.
+func AppendSamples(b []byte, samples []metric.Sample, ts time.Time, base map[string]string) []byte {
+	var lines []*line
+	index := make(map[string]*line)
+	for _, sample := range samples {
+		measurement, field, found := strings.Cut(sample.Name, ".")
+		if !found {
+			field = "value"
+		}
+
+		set := base
+		if len(sample.Labels) > 0 {
+			set = make(map[string]string, len(base)+len(sample.Labels))
+			for k, v := range base {
+				set[k] = v
+			}
+			for k, v := range sample.Labels {
+				set[k] = v
+			}
+		}
+
+		tagSet := encodeTags(set)
+		l, ok := index[measurement+tagSet]
+		if !ok {
+			l = &line{measurement: measurement, tags: tagSet}
+			index[measurement+tagSet] = l
+			lines = append(lines, l)
+		}
+		l.fields = append(l.fields, keyEscaper.Replace(field)+"="+strconv.FormatFloat(sample.Value, 'f', -1, 64))
+	}
+
+	timestamp := strconv.FormatInt(ts.UnixNano(), 10)
+	for _, l := range lines {
+		b = append(b, measurementEscaper.Replace(l.measurement)...)
+		b = append(b, l.tags...)
+		b = append(b, ' ')
+		b = append(b, strings.Join(l.fields, ",")...)
+		b = append(b, ' ')
+		b = append(b, timestamp...)
+		b = append(b, '\n')
+	}
+	return b
+}
+
+// encodeTags encodes a tag set sorted by key, as InfluxDB recommends. Tags with empty values are omitted.
+func encodeTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteByte(',')
+		b.WriteString(keyEscaper.Replace(k))
+		b.WriteByte('=')
+		b.WriteString(keyEscaper.Replace(tags[k]))
+	}
+	return b.String()
+}
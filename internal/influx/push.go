@@ -0,0 +1,198 @@
+package influx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/sendbuf"
+)
+
+// Defaults of the PushOptions.
+const (
+	DefaultBatchSize     = 5000
+	DefaultFlushInterval = 30 * time.Second
+	DefaultTimeout       = 30 * time.Second
+	DefaultMaxBuffered   = 100000
+)
+
+// PushOptions configures a Pusher.
+type PushOptions struct {
+	URL           string            // Base URL of the InfluxDB server, e.g. "http://localhost:8086"
+	Org           string            // Organization
+	Bucket        string            // Bucket
+	Token         string            // API token
+	Tags          map[string]string // Tags added to every point
+	BatchSize     int               // Lines that trigger a write before the flush interval
+	FlushInterval time.Duration     // Interval between writes
+	MaxBuffered   int               // Lines kept while the server is unreachable, the oldest are dropped beyond it
+	Timeout       time.Duration     // Timeout of a write
+	Client        *http.Client      // HTTP client, http.DefaultClient when nil
+}
+
+// ErrRejected is returned when InfluxDB rejects a batch as invalid.
+var ErrRejected = errors.New("InfluxDB write rejected")
+
+// Pusher is a collector sink buffering every snapshot as line protocol and
+// writing the buffer gzip-compressed to the InfluxDB v2 write API every flush
+// interval, or as soon as a batch is full. Failed writes are retried with the
+// next flush.
+type Pusher struct {
+	opts     PushOptions
+	writeURL string
+	onError  func(error)
+	full     chan struct{}
+
+	lines *sendbuf.Buffer[[]byte]
+}
+
+// NewPusher creates a Pusher. onError is called with failed writes and dropped lines.
+func NewPusher(opts PushOptions, onError func(error)) *Pusher {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+	if opts.MaxBuffered < opts.BatchSize {
+		opts.MaxBuffered = max(DefaultMaxBuffered, opts.BatchSize)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	query := url.Values{}
+	query.Set("org", opts.Org)
+	query.Set("bucket", opts.Bucket)
+	query.Set("precision", "ns")
+
+	return &Pusher{
+		opts:     opts,
+		writeURL: strings.TrimSuffix(opts.URL, "/") + "/api/v2/write?" + query.Encode(),
+		onError:  onError,
+		full:     make(chan struct{}, 1),
+		lines:    sendbuf.New[[]byte](opts.MaxBuffered, nil),
+	}
+}
+
+// Consume buffers the samples of a snapshot.
+func (p *Pusher) Consume(s collector.Snapshot) {
+	encoded := AppendLines(nil, s, p.opts.Tags)
+
+	var lines [][]byte
+	for _, l := range bytes.SplitAfter(encoded, []byte("\n")) {
+		if len(l) > 0 {
+			lines = append(lines, l)
+		}
+	}
+	dropped := p.lines.Push(lines...)
+	full := p.lines.Len() >= p.opts.BatchSize
+
+	if dropped > 0 {
+		p.onError(fmt.Errorf("InfluxDB buffer is full, dropped %d lines", dropped))
+	}
+	if full {
+		select {
+		case p.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Buffered returns the number of lines waiting to be written.
+func (p *Pusher) Buffered() int {
+	return p.lines.Len()
+}
+
+// Run writes the buffer every flush interval and whenever a batch is full, until ctx is cancelled.
+func (p *Pusher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.full:
+		}
+		if err := p.Flush(ctx); err != nil {
+			p.onError(err)
+		}
+	}
+}
+
+// Flush writes the buffered lines in batches. It stops at the first failed
+// write and keeps the unwritten lines for the next flush. Batches rejected as
+// invalid are dropped.
+func (p *Pusher) Flush(ctx context.Context) error {
+	for {
+		batch, end := p.lines.Peek(p.opts.BatchSize)
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := p.write(ctx, bytes.Join(batch, nil)); err != nil && !errors.Is(err, ErrRejected) {
+			return err
+		} else if err != nil {
+			// Retrying a batch the server rejects as invalid would never succeed
+			p.onError(err)
+		}
+
+		// Lines dropped while writing are skipped, so none of the new ones are lost
+		p.lines.Remove(end)
+	}
+}
+
+// write sends one gzip-compressed batch of lines.
+func (p *Pusher) write(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(body); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.writeURL, &compressed)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("User-Agent", "SysCapture")
+	if p.opts.Token != "" {
+		req.Header.Set("Authorization", "Token "+p.opts.Token)
+	}
+
+	resp, err := p.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("InfluxDB write failed: %w", err)
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5:
+		return fmt.Errorf("InfluxDB write failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	default:
+		return fmt.Errorf("%w with status %d, dropping the batch: %s", ErrRejected, resp.StatusCode, bytes.TrimSpace(message))
+	}
+}
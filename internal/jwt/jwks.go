@@ -0,0 +1,127 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// jwk is a single JSON Web Key. Only the public key members are read.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// key is a public key of the key set and the algorithm it verifies.
+type key struct {
+	id        string
+	algorithm string
+	public    crypto.PublicKey
+}
+
+// parseKeySet parses a JWKS document. Keys that are not meant for signatures
+// or use an unsupported type are skipped.
+func parseKeySet(data []byte) ([]key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make([]key, 0, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		parsed, err := parseKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("key %d (%q): %w", i, k.Kid, err)
+		}
+		if k.Alg != "" && k.Alg != parsed.algorithm {
+			return nil, fmt.Errorf("key %d (%q): algorithm %q does not match key type %s", i, k.Kid, k.Alg, k.Kty)
+		}
+		keys = append(keys, parsed)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys found")
+	}
+	return keys, nil
+}
+
+// parseKey decodes the public key of an RSA, P-256 or Ed25519 JWK.
+func parseKey(k jwk) (key, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return key{}, fmt.Errorf("modulus: %w", err)
+		}
+		e, err := decodeInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 {
+			return key{}, errors.New("invalid exponent")
+		}
+		if n.BitLen() < 2048 {
+			return key{}, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return key{id: k.Kid, algorithm: RS256, public: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return key{}, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return key{}, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return key{}, fmt.Errorf("y: %w", err)
+		}
+		public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if _, err := public.ECDH(); err != nil {
+			return key{}, errors.New("point is not on the curve")
+		}
+		return key{id: k.Kid, algorithm: ES256, public: public}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return key{}, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return key{}, errors.New("invalid Ed25519 public key")
+		}
+		return key{id: k.Kid, algorithm: EdDSA, public: ed25519.PublicKey(x)}, nil
+
+	default:
+		return key{}, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeInt decodes a base64url encoded big-endian unsigned integer.
+func decodeInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
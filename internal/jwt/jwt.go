@@ -0,0 +1,344 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported signature algorithms.
+const (
+	RS256 = "RS256" // RSASSA-PKCS1-v1_5 with SHA-256
+	ES256 = "ES256" // ECDSA on P-256 with SHA-256
+	EdDSA = "EdDSA" // Ed25519
+)
+
+// DefaultScopesClaim is the claim holding the scopes when none is configured.
+const DefaultScopesClaim = "scope"
+
+// reloadInterval is how often the JWKS file is checked for changes.
+const reloadInterval = 5 * time.Second
+
+var (
+	// ErrMalformed is returned when a token is not a valid compact JWS.
+	ErrMalformed = errors.New("malformed token")
+
+	// ErrAlgorithm is returned when a token uses an unsupported algorithm.
+	ErrAlgorithm = errors.New("unsupported signature algorithm")
+
+	// ErrUnknownKey is returned when no key of the key set matches the token.
+	ErrUnknownKey = errors.New("no matching key")
+
+	// ErrSignature is returned when the signature does not verify.
+	ErrSignature = errors.New("invalid signature")
+
+	// ErrExpired is returned when a token is expired or has no expiry.
+	ErrExpired = errors.New("token expired")
+
+	// ErrNotYetValid is returned when the not-before time of a token is in the future.
+	ErrNotYetValid = errors.New("token not yet valid")
+
+	// ErrIssuer is returned when the issuer does not match.
+	ErrIssuer = errors.New("unexpected issuer")
+
+	// ErrAudience is returned when the audience does not contain the expected audience.
+	ErrAudience = errors.New("unexpected audience")
+)
+
+// Options configures a Verifier.
+type Options struct {
+	JWKSFile    string              // JSON Web Key Set holding the public keys
+	Issuer      string              // Required "iss" claim
+	Audience    string              // Required member of the "aud" claim
+	Leeway      time.Duration       // Tolerated clock difference for "exp" and "nbf"
+	ScopesClaim string              // Claim holding the scopes, DefaultScopesClaim when empty
+	ScopeMap    map[string][]string // Claim values to scopes, claim values are used as scopes when empty
+}
+
+// Claims are the verified claims of a token.
+type Claims struct {
+	Subject   string    // "sub" claim
+	Scopes    []string  // Scopes mapped from the scopes claim
+	ExpiresAt time.Time // "exp" claim
+}
+
+// Verifier verifies JWTs against the keys of a JWKS file, which is reloaded when it changes.
+type Verifier struct {
+	opts Options
+
+	mu        sync.RWMutex
+	keys      []key
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// NewVerifier loads the JWKS file.
+func NewVerifier(opts Options) (*Verifier, error) {
+	if opts.ScopesClaim == "" {
+		opts.ScopesClaim = DefaultScopesClaim
+	}
+
+	v := &Verifier{opts: opts}
+	if err := v.Reload(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Reload reads the JWKS file again. On error the previously loaded keys stay in use.
+func (v *Verifier) Reload() error {
+	info, err := os.Stat(v.opts.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("load JWKS file: %w", err)
+	}
+	data, err := os.ReadFile(v.opts.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("load JWKS file: %w", err)
+	}
+	keys, err := parseKeySet(data)
+	if err != nil {
+		return fmt.Errorf("parse JWKS file %s: %w", v.opts.JWKSFile, err)
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.modTime = info.ModTime()
+	v.mu.Unlock()
+	return nil
+}
+
+// Verify checks the signature and the registered claims of a compact JWT at now.
+func (v *Verifier) Verify(raw string, now time.Time) (*Claims, error) {
+	v.reloadIfChanged()
+
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var header struct {
+		Alg  string `json:"alg"`
+		Kid  string `json:"kid"`
+		Crit []any  `json:"crit"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrMalformed
+	}
+	if header.Alg != RS256 && header.Alg != ES256 && header.Alg != EdDSA {
+		return nil, ErrAlgorithm
+	}
+	if len(header.Crit) > 0 {
+		return nil, ErrMalformed
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if err := v.verifySignature(header.Alg, header.Kid, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims struct {
+		Issuer    string          `json:"iss"`
+		Subject   string          `json:"sub"`
+		Audience  json.RawMessage `json:"aud"`
+		ExpiresAt *json.Number    `json:"exp"`
+		NotBefore *json.Number    `json:"nbf"`
+	}
+	var all map[string]json.RawMessage
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformed
+	}
+	if err := decodeSegment(parts[1], &all); err != nil {
+		return nil, ErrMalformed
+	}
+
+	if claims.ExpiresAt == nil {
+		return nil, ErrExpired
+	}
+	expiresAt, err := numericDate(*claims.ExpiresAt)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !now.Before(expiresAt.Add(v.opts.Leeway)) {
+		return nil, ErrExpired
+	}
+	if claims.NotBefore != nil {
+		notBefore, err := numericDate(*claims.NotBefore)
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		if now.Add(v.opts.Leeway).Before(notBefore) {
+			return nil, ErrNotYetValid
+		}
+	}
+
+	if v.opts.Issuer != "" && claims.Issuer != v.opts.Issuer {
+		return nil, ErrIssuer
+	}
+	if v.opts.Audience != "" {
+		audiences, err := stringList(claims.Audience, false)
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		if !slices.Contains(audiences, v.opts.Audience) {
+			return nil, ErrAudience
+		}
+	}
+
+	values, err := stringList(all[v.opts.ScopesClaim], true)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	return &Claims{
+		Subject:   claims.Subject,
+		Scopes:    v.mapScopes(values),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// verifySignature checks the signature of the signing input with the key
+// identified by kid, or with every key of the algorithm when kid is empty.
+func (v *Verifier) verifySignature(alg string, kid string, input string, signature []byte) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	digest := sha256.Sum256([]byte(input))
+	found := false
+	for _, k := range v.keys {
+		if k.algorithm != alg || (kid != "" && k.id != kid) {
+			continue
+		}
+		found = true
+
+		switch public := k.public.(type) {
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			// JWS encodes ECDSA signatures as the fixed size concatenation of r and s
+			if len(signature) == 64 {
+				r := new(big.Int).SetBytes(signature[:32])
+				s := new(big.Int).SetBytes(signature[32:])
+				if ecdsa.Verify(public, digest[:], r, s) {
+					return nil
+				}
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(public, []byte(input), signature) {
+				return nil
+			}
+		}
+	}
+
+	if !found {
+		return ErrUnknownKey
+	}
+	return ErrSignature
+}
+
+// mapScopes maps the values of the scopes claim through the scope map.
+func (v *Verifier) mapScopes(values []string) []string {
+	if len(v.opts.ScopeMap) == 0 {
+		return values
+	}
+
+	var scopes []string
+	for _, value := range values {
+		for _, scope := range v.opts.ScopeMap[value] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// reloadIfChanged reloads the JWKS file if it was modified, checking at most every reloadInterval.
+func (v *Verifier) reloadIfChanged() {
+	v.mu.Lock()
+	if time.Since(v.lastCheck) < reloadInterval {
+		v.mu.Unlock()
+		return
+	}
+	v.lastCheck = time.Now()
+	modTime := v.modTime
+	v.mu.Unlock()
+
+	info, err := os.Stat(v.opts.JWKSFile)
+	if err != nil || info.ModTime().Equal(modTime) {
+		return
+	}
+	// Keep verifying with the keys loaded so far if the file is invalid
+	_ = v.Reload()
+}
+
+// LooksLikeJWT reports whether a bearer token has the shape of a compact JWT,
+// so it can be told apart from opaque API tokens.
+func LooksLikeJWT(raw string) bool {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	return decodeSegment(parts[0], &header) == nil && header.Alg != ""
+}
+
+// decodeSegment decodes a base64url encoded JSON segment.
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// numericDate converts a JSON NumericDate to a time.
+func numericDate(n json.Number) (time.Time, error) {
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), nil
+}
+
+// stringList decodes a claim that is either a single string or an array of strings.
+// With fields, a single string is split on spaces as in OAuth 2.0 scopes. A missing claim is empty.
+func stringList(raw json.RawMessage, fields bool) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		if fields {
+			return strings.Fields(single), nil
+		}
+		return []string{single}, nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,124 @@
+// Package listener opens the TCP and unix domain socket listeners of the server.
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnixPrefix marks a unix domain socket address, e.g. "unix:/run/syscapture.sock".
+const UnixPrefix = "unix:"
+
+// DefaultSocketMode is the permission of unix domain sockets: the owner and its group.
+const DefaultSocketMode fs.FileMode = 0o660
+
+// Options configures the unix domain sockets.
+type Options struct {
+	SocketMode  fs.FileMode // Permissions of the socket file
+	SocketGroup string      // Group owning the socket file, the group of the process when empty
+}
+
+// IsUnix reports whether address is a unix domain socket address.
+func IsUnix(address string) bool {
+	return strings.HasPrefix(address, UnixPrefix)
+}
+
+// Validate checks that address is "host:port", "[ipv6]:port", ":port" or "unix:/path".
+func Validate(address string) error {
+	if IsUnix(address) {
+		if strings.TrimPrefix(address, UnixPrefix) == "" {
+			return errors.New("unix socket address needs a path")
+		}
+		return nil
+	}
+
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("port %q must be a number between 1 and 65535", port)
+	}
+	return nil
+}
+
+// Listen opens a listener on a TCP or unix domain socket address. A stale
+// socket file left behind by a previous run is replaced, one that still
+// accepts connections is not.
+func Listen(address string, opts Options) (net.Listener, error) {
+	if !IsUnix(address) {
+		return net.Listen("tcp", address)
+	}
+
+	path := strings.TrimPrefix(address, UnixPrefix)
+	if err := removeStale(path); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := opts.SocketMode
+	if mode == 0 {
+		mode = DefaultSocketMode
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if opts.SocketGroup != "" {
+		group, err := user.LookupGroup(opts.SocketGroup)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		gid, _ := strconv.Atoi(group.Gid)
+		if err := os.Chown(path, -1, gid); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// removeStale removes a socket file at path that no process listens on anymore.
+func removeStale(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
+
+// LocalClients reports clients of unix domain sockets, which have no address,
+// as 127.0.0.1 so that access lists, rate limits and TRUSTED_PROXIES apply to
+// them like to local TCP clients.
+func LocalClients(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := net.SplitHostPort(r.RemoteAddr); err != nil {
+			r.RemoteAddr = "127.0.0.1:0"
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,19 @@
+package metric
+
+import "time"
+
+// Anomaly represents a sample that deviates from its seasonal baseline.
+type Anomaly struct {
+	Timestamp time.Time         `json:"timestamp"`        // Time the sample was collected
+	Metric    string            `json:"metric"`           // Metric name
+	Labels    map[string]string `json:"labels,omitempty"` // Series labels
+	Value     float64           `json:"value"`            // Observed value
+	Expected  float64           `json:"expected"`         // Baseline mean for the hour of the week
+	Deviation float64           `json:"deviation"`        // Baseline standard deviation for the hour of the week
+	ZScore    float64           `json:"z_score"`          // Number of deviations the value is away from the baseline
+}
+
+// AnomalySlice represents a slice of anomalies.
+type AnomalySlice []Anomaly
+
+func (a AnomalySlice) isMetric() {}
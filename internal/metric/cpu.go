@@ -9,6 +9,12 @@ import (
 
 // CollectCPUMetrics collects various CPU metrics and returns them along with any errors encountered.
 func CollectCPUMetrics() (*CPUData, []CustomErr) {
+	return collectCPUMetrics(true)
+}
+
+// collectCPUMetrics collects the CPU metrics. Without usage, the one second
+// usage sampling is skipped and the usage and free percentages are left at zero.
+func collectCPUMetrics(usage bool) (*CPUData, []CustomErr) {
 	var cpuErrors []CustomErr
 
 	// Collect CPU Core Counts
@@ -44,16 +50,19 @@ func CollectCPUMetrics() (*CPUData, []CustomErr) {
 	}
 
 	// Collect CPU Usage
-	cpuPercents, cpuPercentsErr := cpu.Percent(time.Second, false)
-	var cpuUsagePercent float64
-	if cpuPercentsErr != nil {
-		cpuErrors = append(cpuErrors, CustomErr{
-			Metric: []string{"cpu.usage_percent"},
-			Error:  cpuPercentsErr.Error(),
-		})
-		cpuUsagePercent = 0
-	} else if len(cpuPercents) > 0 {
-		cpuUsagePercent = cpuPercents[0] / 100.0
+	var cpuUsagePercent, cpuFreePercent float64
+	if usage {
+		cpuPercents, cpuPercentsErr := cpu.Percent(time.Second, false)
+		if cpuPercentsErr != nil {
+			cpuErrors = append(cpuErrors, CustomErr{
+				Metric: []string{"cpu.usage_percent"},
+				Error:  cpuPercentsErr.Error(),
+			})
+			cpuUsagePercent = 0
+		} else if len(cpuPercents) > 0 {
+			cpuUsagePercent = cpuPercents[0] / 100.0
+		}
+		cpuFreePercent = 1 - cpuUsagePercent
 	}
 
 	// Collect CPU Temperature from sysfs
@@ -82,7 +91,7 @@ func CollectCPUMetrics() (*CPUData, []CustomErr) {
 		Frequency:        cpuFrequency,
 		CurrentFrequency: cpuCurrentFrequency,
 		Temperature:      cpuTemp,
-		FreePercent:      *RoundFloatPtr(1-cpuUsagePercent, 4),
+		FreePercent:      *RoundFloatPtr(cpuFreePercent, 4),
 		UsagePercent:     *RoundFloatPtr(cpuUsagePercent, 4),
 	}, cpuErrors
 }
@@ -9,6 +9,12 @@ import (
 
 // CollectDiskMetrics collects various disk metrics and returns them along with any errors encountered.
 func CollectDiskMetrics() (MetricsSlice, []CustomErr) {
+	return collectDiskMetrics(nil)
+}
+
+// collectDiskMetrics collects the disk metrics of the devices for which match
+// returns true, or of all devices when match is nil.
+func collectDiskMetrics(match func(device string) bool) (MetricsSlice, []CustomErr) {
 	defaultDiskData := []*DiskData{
 		{
 			Device:       "unknown",
@@ -37,6 +43,9 @@ func CollectDiskMetrics() (MetricsSlice, []CustomErr) {
 		if slices.Contains(checkedSlice, p.Device) || !strings.HasPrefix(p.Device, "/dev") || strings.HasPrefix(p.Device, "/dev/loop") {
 			continue
 		}
+		if match != nil && !match(p.Device) {
+			continue
+		}
 
 		diskUsage, diskUsageErr := disk.Usage(p.Mountpoint)
 		if diskUsageErr != nil {
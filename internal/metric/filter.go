@@ -0,0 +1,304 @@
+package metric
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// groupTypes are the metric groups with the type holding their fields.
+var groupTypes = map[string]reflect.Type{
+	"cpu":    reflect.TypeOf(CPUData{}),
+	"memory": reflect.TypeOf(MemoryData{}),
+	"disk":   reflect.TypeOf(DiskData{}),
+	"host":   reflect.TypeOf(HostData{}),
+}
+
+// groupFields are the JSON field names of every metric group.
+var groupFields = func() map[string]map[string]bool {
+	fields := make(map[string]map[string]bool, len(groupTypes))
+	for group, t := range groupTypes {
+		fields[group] = make(map[string]bool, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			if name := jsonName(t.Field(i)); name != "" {
+				fields[group][name] = true
+			}
+		}
+	}
+	return fields
+}()
+
+// jsonName returns the JSON name of a struct field, or "" if it is not encoded.
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// Selection holds the requested fields of a metric group by their JSON name.
+type Selection map[string]any
+
+func (s Selection) isMetric() {}
+
+// Filter selects the metric fields and disk devices a client asked for, so
+// that only the collectors needed for them run. The zero Filter selects everything.
+type Filter struct {
+	group   string                     // Group of a sub-route, empty for all groups
+	fields  map[string]map[string]bool // Requested fields by group, a nil set requesting the whole group; nil for everything
+	devices map[string]bool            // Requested disk devices, nil for all
+}
+
+// ParseFilter parses the requested fields, such as "cpu.usage_percent" or a
+// whole group like "memory", and disk devices, such as "/dev/sda1". Every value
+// may hold a comma separated list. Within a group, e.g. on /metrics/cpu, fields
+// may also be given without the group, such as "usage_percent".
+func ParseFilter(group string, fields []string, devices []string) (Filter, error) {
+	f := Filter{group: group}
+	if group != "" && groupTypes[group] == nil {
+		return f, fmt.Errorf("unknown metric group %q", group)
+	}
+
+	for _, item := range splitValues(fields) {
+		if group != "" && item != group && !strings.Contains(item, ".") {
+			item = group + "." + item
+		}
+		g, field, hasField := strings.Cut(item, ".")
+		if groupTypes[g] == nil || (hasField && !groupFields[g][field]) {
+			return f, fmt.Errorf("unknown field %q", item)
+		}
+		if group != "" && g != group {
+			return f, fmt.Errorf("field %q is not a %s metric", item, group)
+		}
+
+		if f.fields == nil {
+			f.fields = make(map[string]map[string]bool)
+		}
+		set, requested := f.fields[g]
+		switch {
+		case !hasField:
+			f.fields[g] = nil
+		case requested && set == nil:
+			// The whole group is requested already
+		case requested:
+			set[field] = true
+		default:
+			f.fields[g] = map[string]bool{field: true}
+		}
+	}
+
+	if values := splitValues(devices); len(values) > 0 {
+		if group != "" && group != "disk" {
+			return f, fmt.Errorf("'device' only applies to disk metrics")
+		}
+		f.devices = make(map[string]bool, len(values))
+		for _, device := range values {
+			f.devices[device] = true
+		}
+	}
+	return f, nil
+}
+
+// splitValues splits comma separated values and drops empty ones.
+func splitValues(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// Within returns the part of f concerning the sub-route of group, e.g. the
+// default fields of /metrics/cpu. The whole group is selected when f requests
+// none of its fields.
+func (f Filter) Within(group string) Filter {
+	if group == "" {
+		return f
+	}
+	within := Filter{group: group}
+	if set := f.fields[group]; set != nil {
+		within.fields = map[string]map[string]bool{group: set}
+	}
+	if group == "disk" {
+		within.devices = f.devices
+	}
+	return within
+}
+
+// HasDevices reports whether specific disk devices are requested.
+func (f Filter) HasDevices() bool {
+	return f.devices != nil
+}
+
+// Includes reports whether any field of group is requested.
+func (f Filter) Includes(group string) bool {
+	if f.fields == nil {
+		return f.group == "" || f.group == group
+	}
+	_, requested := f.fields[group]
+	return requested
+}
+
+// wantsField reports whether the field of group is requested.
+func (f Filter) wantsField(group string, field string) bool {
+	if f.fields == nil {
+		return f.Includes(group)
+	}
+	set, requested := f.fields[group]
+	return requested && (set == nil || set[field])
+}
+
+// IncludesDevice reports whether the disk device is requested.
+func (f Filter) IncludesDevice(device string) bool {
+	return f.devices == nil || f.devices[device]
+}
+
+// CollectFiltered runs only the collectors needed for the requested fields:
+// other groups are left empty, the CPU usage is only sampled when a usage
+// percentage is requested, and only the requested disks are inspected.
+func CollectFiltered(f Filter) (AllMetrics, []CustomErr) {
+	var m AllMetrics
+	var errs []CustomErr
+
+	if f.Includes("cpu") {
+		cpu, cpuErr := collectCPUMetrics(f.wantsField("cpu", "usage_percent") || f.wantsField("cpu", "free_percent"))
+		m.CPU = *cpu
+		errs = append(errs, cpuErr...)
+	}
+	if f.Includes("memory") {
+		memory, memErr := CollectMemoryMetrics()
+		m.Memory = *memory
+		errs = append(errs, memErr...)
+	}
+	if f.Includes("disk") {
+		var match func(string) bool
+		if f.devices != nil {
+			match = f.IncludesDevice
+		}
+		disk, diskErr := collectDiskMetrics(match)
+		m.Disk = disk
+		errs = append(errs, diskErr...)
+	}
+	if f.Includes("host") {
+		host, hostErr := GetHostInformation()
+		m.Host = *host
+		errs = append(errs, hostErr...)
+	}
+	return m, errs
+}
+
+// Select returns the requested part of m: the metrics of the filter's group,
+// or all of them, with only the requested fields and disks. Without requested
+// fields the collected types are returned unchanged.
+func (f Filter) Select(m AllMetrics) Metric {
+	disks := make(MetricsSlice, 0, len(m.Disk))
+	for _, d := range m.Disk {
+		if disk, ok := d.(*DiskData); !ok || f.IncludesDevice(disk.Device) || disk.Device == "unknown" {
+			disks = append(disks, d)
+		}
+	}
+
+	if f.fields == nil {
+		switch f.group {
+		case "cpu":
+			return m.CPU
+		case "memory":
+			return m.Memory
+		case "disk":
+			return disks
+		case "host":
+			return m.Host
+		}
+		m.Disk = disks
+		return m
+	}
+
+	selection := make(Selection, len(f.fields))
+	for group := range f.fields {
+		switch group {
+		case "cpu":
+			selection[group] = f.project(group, m.CPU)
+		case "memory":
+			selection[group] = f.project(group, m.Memory)
+		case "disk":
+			projected := make(MetricsSlice, 0, len(disks))
+			for _, d := range disks {
+				projected = append(projected, f.project(group, d))
+			}
+			selection[group] = projected
+		case "host":
+			selection[group] = f.project(group, m.Host)
+		}
+	}
+	if f.group != "" {
+		return selection[f.group].(Metric)
+	}
+	return selection
+}
+
+// project returns the requested fields of a metric struct. Disks keep their device.
+func (f Filter) project(group string, v any) Selection {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	selection := make(Selection)
+	for i := 0; i < value.NumField(); i++ {
+		name := jsonName(value.Type().Field(i))
+		if name != "" && (f.wantsField(group, name) || (group == "disk" && name == "device")) {
+			selection[name] = value.Field(i).Interface()
+		}
+	}
+	return selection
+}
+
+// Errors returns the errors concerning the requested fields.
+func (f Filter) Errors(errs []CustomErr) []CustomErr {
+	var kept []CustomErr
+	for _, err := range errs {
+		for _, name := range err.Metric {
+			group, field, _ := strings.Cut(name, ".")
+			// Errors of a whole collector, such as "disk.partitions", concern every field of the group
+			if f.wantsField(group, field) || (!groupFields[group][field] && f.Includes(group)) {
+				kept = append(kept, err)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// Samples returns the samples of the requested fields and disks. Derived
+// samples outside the metric groups, such as anomaly scores, are kept.
+func (f Filter) Samples(m AllMetrics) []Sample {
+	var samples []Sample
+	for _, s := range m.Samples() {
+		group, field, _ := strings.Cut(s.Name, ".")
+		if device, ok := s.Labels["device"]; ok && !f.IncludesDevice(device) {
+			continue
+		}
+		if s.Name == "disk.days_until_full" {
+			field = "forecast"
+		}
+		if groupTypes[group] == nil || f.wantsField(group, field) {
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+// HostInfo returns the requested host fields by their JSON name, leaving out empty ones.
+func (f Filter) HostInfo(h HostData) map[string]string {
+	info := make(map[string]string)
+	if !f.Includes("host") {
+		return info
+	}
+	for name, value := range f.project("host", h) {
+		if s, ok := value.(string); ok && s != "" {
+			info[name] = s
+		}
+	}
+	return info
+}
@@ -0,0 +1,101 @@
+package metric
+
+import "time"
+
+// Node statuses.
+const (
+	NodeOnline  = "online"
+	NodeOffline = "offline"
+)
+
+// Node represents an agent known to a hub with its latest metrics.
+type Node struct {
+	Name         string       `json:"name"`                    // Unique name of the node
+	Status       string       `json:"status"`                  // NodeOnline while reachable, NodeOffline otherwise
+	URL          string       `json:"url,omitempty"`           // Base URL the hub polls, empty for nodes pushing their metrics
+	Reachable    bool         `json:"reachable"`               // Whether the last poll succeeded or the node reported recently
+	Stale        bool         `json:"stale"`                   // Whether the latest report is older than the node's stale threshold
+	LastSeen     *time.Time   `json:"last_seen"`               // Time of the latest report (nil if never)
+	LastError    string       `json:"last_error,omitempty"`    // Error of the last failed poll
+	Version      string       `json:"version,omitempty"`       // Version of a registered agent
+	Host         *HostData    `json:"host,omitempty"`          // Host of a registered agent
+	Address      string       `json:"address,omitempty"`       // URL a registered agent advertises
+	Listen       []string     `json:"listen,omitempty"`        // Addresses a registered agent listens on
+	Capabilities []string     `json:"capabilities,omitempty"`  // Features a registered agent serves
+	RegisteredAt *time.Time   `json:"registered_at,omitempty"` // Time of the latest registration
+	Summary      *NodeSummary `json:"summary,omitempty"`       // Usage reported with the latest metrics or heartbeat
+	Metrics      *AllMetrics  `json:"metrics,omitempty"`       // Latest metrics, left out of node lists
+	Errors       []CustomErr  `json:"errors,omitempty"`        // Collection errors reported with the latest metrics
+}
+
+func (n Node) isMetric() {}
+
+// NodeSlice represents a slice of nodes.
+type NodeSlice []Node
+
+func (n NodeSlice) isMetric() {}
+
+// NodeSummary represents the usage of a node, sent in agent heartbeats.
+type NodeSummary struct {
+	CPUUsagePercent    float64  `json:"cpu_usage_percent"`     // CPU usage
+	MemoryUsagePercent *float64 `json:"memory_usage_percent"`  // Memory usage (nil if unknown)
+	MemoryTotalBytes   uint64   `json:"memory_total_bytes"`    // Total memory
+	MemoryUsedBytes    uint64   `json:"memory_used_bytes"`     // Used memory
+	DiskUsagePercent   *float64 `json:"disk_usage_percent"`    // Usage of the fullest disk (nil without disks)
+	DiskDevice         string   `json:"disk_device,omitempty"` // Device of the fullest disk
+	DiskTotalBytes     uint64   `json:"disk_total_bytes"`      // Total disk space
+	DiskFreeBytes      uint64   `json:"disk_free_bytes"`       // Free disk space
+	Errors             int      `json:"errors"`                // Collection errors
+}
+
+// Summarize returns the usage of a node from its metrics and collection errors.
+func Summarize(m AllMetrics, errs []CustomErr) NodeSummary {
+	summary := NodeSummary{
+		CPUUsagePercent:    m.CPU.UsagePercent,
+		MemoryUsagePercent: m.Memory.UsagePercent,
+		MemoryTotalBytes:   m.Memory.TotalBytes,
+		MemoryUsedBytes:    m.Memory.UsedBytes,
+		Errors:             len(errs),
+	}
+	for _, d := range m.Disk {
+		disk, ok := d.(*DiskData)
+		if !ok {
+			continue
+		}
+		if disk.TotalBytes != nil && disk.FreeBytes != nil {
+			summary.DiskTotalBytes += *disk.TotalBytes
+			summary.DiskFreeBytes += *disk.FreeBytes
+		}
+		if disk.UsagePercent != nil && (summary.DiskUsagePercent == nil || *disk.UsagePercent > *summary.DiskUsagePercent) {
+			summary.DiskUsagePercent = disk.UsagePercent
+			summary.DiskDevice = disk.Device
+		}
+	}
+	return summary
+}
+
+// NodeRank represents the value a node is ranked by in a fleet aggregate.
+type NodeRank struct {
+	Name   string  `json:"name"`             // Node name
+	Value  float64 `json:"value"`            // Ranked value, a usage percentage
+	Device string  `json:"device,omitempty"` // Disk device holding the value, for disk rankings
+}
+
+// NodeRanking represents nodes ordered by a value, highest first.
+type NodeRanking []NodeRank
+
+func (n NodeRanking) isMetric() {}
+
+// FleetSummary represents the totals of the nodes known to a hub.
+type FleetSummary struct {
+	Nodes            int      `json:"nodes"`              // Known nodes
+	Reachable        int      `json:"reachable"`          // Reachable nodes
+	Stale            int      `json:"stale"`              // Nodes with stale metrics, left out of the totals below
+	CPUUsagePercent  *float64 `json:"cpu_usage_percent"`  // Average CPU usage (nil without fresh nodes)
+	MemoryTotalBytes uint64   `json:"memory_total_bytes"` // Total memory
+	MemoryUsedBytes  uint64   `json:"memory_used_bytes"`  // Used memory
+	DiskTotalBytes   uint64   `json:"disk_total_bytes"`   // Total disk space
+	DiskFreeBytes    uint64   `json:"disk_free_bytes"`    // Free disk space
+}
+
+func (f FleetSummary) isMetric() {}
@@ -1,5 +1,10 @@
 package metric
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // MetricsSlice represents a slice of Metric interfaces.
 type MetricsSlice []Metric
 
@@ -18,14 +23,36 @@ type APIResponse struct {
 
 // AllMetrics represents all collected system metrics.
 type AllMetrics struct {
-	CPU    CPUData      `json:"cpu"`
-	Memory MemoryData   `json:"memory"`
-	Disk   MetricsSlice `json:"disk"`
-	Host   HostData     `json:"host"`
+	CPU     CPUData      `json:"cpu"`
+	Memory  MemoryData   `json:"memory"`
+	Disk    MetricsSlice `json:"disk"`
+	Host    HostData     `json:"host"`
+	Derived []Sample     `json:"-"` // Samples derived from the collection, e.g. anomaly scores
 }
 
 func (a AllMetrics) isMetric() {}
 
+// UnmarshalJSON decodes all metrics as encoded by the API, the disks being DiskData.
+func (a *AllMetrics) UnmarshalJSON(data []byte) error {
+	var decoded struct {
+		CPU    CPUData     `json:"cpu"`
+		Memory MemoryData  `json:"memory"`
+		Disk   []*DiskData `json:"disk"`
+		Host   HostData    `json:"host"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*a = AllMetrics{CPU: decoded.CPU, Memory: decoded.Memory, Host: decoded.Host}
+	for _, d := range decoded.Disk {
+		if d != nil {
+			a.Disk = append(a.Disk, d)
+		}
+	}
+	return nil
+}
+
 // CustomErr represents a custom error structure.
 type CustomErr struct {
 	Metric []string `json:"metric"`
@@ -57,10 +84,20 @@ func (m MemoryData) isMetric() {}
 
 // DiskData represents the collected disk metrics.
 type DiskData struct {
-	Device       string   `json:"device"`        // Device
-	TotalBytes   *uint64  `json:"total_bytes"`   // Total space of device in bytes
-	FreeBytes    *uint64  `json:"free_bytes"`    // Free space of device in bytes
-	UsagePercent *float64 `json:"usage_percent"` // Usage Percent of device
+	Device       string        `json:"device"`             // Device
+	TotalBytes   *uint64       `json:"total_bytes"`        // Total space of device in bytes
+	FreeBytes    *uint64       `json:"free_bytes"`         // Free space of device in bytes
+	UsagePercent *float64      `json:"usage_percent"`      // Usage Percent of device
+	Forecast     *DiskForecast `json:"forecast,omitempty"` // Disk-full forecast (nil without enough history)
+}
+
+// DiskForecast represents the predicted time until a filesystem runs out of free space.
+type DiskForecast struct {
+	PredictedFullAt   *time.Time `json:"predicted_full_at"`    // Predicted time the disk is full (nil if it is not filling up)
+	DaysUntilFull     *float64   `json:"days_until_full"`      // Days until the disk is full (nil if it is not filling up)
+	GrowthBytesPerDay float64    `json:"growth_bytes_per_day"` // Fitted growth of used space in bytes per day
+	Confidence        string     `json:"confidence"`           // Confidence of the forecast: low, medium or high
+	Samples           int        `json:"samples"`              // Number of history points the trend was fitted on
 }
 
 func (d DiskData) isMetric() {}
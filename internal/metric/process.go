@@ -0,0 +1,143 @@
+package metric
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// Process orderings accepted by CollectProcesses.
+const (
+	ProcessesByCPU    = "cpu"
+	ProcessesByMemory = "memory"
+	ProcessesByPID    = "pid"
+)
+
+// ProcessData represents a running process.
+type ProcessData struct {
+	PID           int32     `json:"pid"`            // Process ID
+	PPID          int32     `json:"ppid"`           // Parent process ID
+	Name          string    `json:"name"`           // Executable name
+	Username      string    `json:"username"`       // Owner of the process
+	Status        string    `json:"status"`         // Status such as running or sleep
+	Cmdline       string    `json:"cmdline"`        // Command line with arguments
+	CreatedAt     time.Time `json:"created_at"`     // Start time of the process
+	CPUPercent    float64   `json:"cpu_percent"`    // CPU usage since the process started, 1 being a full core
+	MemoryBytes   uint64    `json:"memory_bytes"`   // Resident set size in bytes
+	MemoryPercent float64   `json:"memory_percent"` // Resident set size as a share of the total memory
+}
+
+// ProcessSlice represents a slice of processes.
+type ProcessSlice []ProcessData
+
+func (p ProcessSlice) isMetric() {}
+
+// CollectProcesses collects the running processes ordered by sortBy, one of
+// the Processes* orderings, and returns at most limit of them, all of them
+// when limit is 0. Processes exiting while they are inspected are left out.
+func CollectProcesses(sortBy string, limit int) (ProcessSlice, []CustomErr) {
+	var processErrors []CustomErr
+
+	pids, pidsErr := process.Processes()
+	if pidsErr != nil {
+		processErrors = append(processErrors, CustomErr{
+			Metric: []string{"process.list"},
+			Error:  pidsErr.Error(),
+		})
+		return nil, processErrors
+	}
+
+	processes := make(ProcessSlice, 0, len(pids))
+	for _, p := range pids {
+		data, err := collectProcess(p)
+		if errors.Is(err, process.ErrorProcessNotRunning) {
+			continue
+		}
+		if err != nil {
+			// The remaining details are usually restricted to the owner of the process
+			processErrors = appendProcessErr(processErrors, err)
+		}
+		processes = append(processes, data)
+	}
+
+	switch sortBy {
+	case ProcessesByMemory:
+		slices.SortStableFunc(processes, func(a, b ProcessData) int {
+			return cmp.Compare(b.MemoryBytes, a.MemoryBytes)
+		})
+	case ProcessesByPID:
+		slices.SortStableFunc(processes, func(a, b ProcessData) int {
+			return cmp.Compare(a.PID, b.PID)
+		})
+	default:
+		slices.SortStableFunc(processes, func(a, b ProcessData) int {
+			return cmp.Compare(b.CPUPercent, a.CPUPercent)
+		})
+	}
+
+	if limit > 0 && len(processes) > limit {
+		processes = processes[:limit]
+	}
+	return processes, processErrors
+}
+
+// collectProcess collects the details of a process. The first error other
+// than the process having exited is returned along with what could be collected.
+func collectProcess(p *process.Process) (ProcessData, error) {
+	data := ProcessData{PID: p.Pid}
+	var firstErr error
+	check := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	name, err := p.Name()
+	if err != nil {
+		// A process without a name has exited or cannot be inspected at all
+		return data, process.ErrorProcessNotRunning
+	}
+	data.Name = name
+
+	data.PPID, err = p.Ppid()
+	check(err)
+	data.Username, err = p.Username()
+	check(err)
+	if status, err := p.Status(); err == nil && len(status) > 0 {
+		data.Status = status[0]
+	}
+	data.Cmdline, err = p.Cmdline()
+	check(err)
+	if created, err := p.CreateTime(); err == nil {
+		data.CreatedAt = time.UnixMilli(created)
+	}
+	if percent, err := p.CPUPercent(); err == nil {
+		data.CPUPercent = RoundFloat(percent/100, 4)
+	}
+	if memory, err := p.MemoryInfo(); err == nil {
+		data.MemoryBytes = memory.RSS
+	} else {
+		check(err)
+	}
+	if percent, err := p.MemoryPercent(); err == nil {
+		data.MemoryPercent = RoundFloat(float64(percent)/100, 4)
+	}
+	return data, firstErr
+}
+
+// appendProcessErr records err once, however many processes it concerns.
+func appendProcessErr(errs []CustomErr, err error) []CustomErr {
+	for _, e := range errs {
+		if strings.EqualFold(e.Error, err.Error()) {
+			return errs
+		}
+	}
+	return append(errs, CustomErr{
+		Metric: []string{"process.details"},
+		Error:  err.Error(),
+	})
+}
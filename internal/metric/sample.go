@@ -0,0 +1,144 @@
+package metric
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sample represents a single numeric value flattened out of the collected metrics.
+type Sample struct {
+	Name   string            // Dotted metric name, e.g. "cpu.usage_percent"
+	Labels map[string]string // Optional labels, e.g. {"device": "/dev/sda1"}
+	Value  float64           // Sample value
+}
+
+// Key returns the series identifier of the sample in the form name{label="value",...}.
+// Labels are sorted so the key is stable across collections.
+func (s Sample) Key() string {
+	return SeriesKey(s.Name, s.Labels)
+}
+
+// PrometheusName returns the Prometheus name of a dotted metric name,
+// e.g. "syscapture_cpu_usage_percent" for "cpu.usage_percent".
+func PrometheusName(name string) string {
+	return "syscapture_" + strings.ReplaceAll(name, ".", "_")
+}
+
+// SeriesKey builds a series identifier from a metric name and its labels.
+func SeriesKey(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// ParseSeriesKey splits a series identifier built by SeriesKey into its name and labels.
+func ParseSeriesKey(key string) (string, map[string]string) {
+	open := strings.IndexByte(key, '{')
+	if open < 0 || !strings.HasSuffix(key, "}") {
+		return key, nil
+	}
+
+	name := key[:open]
+	rest := key[open+1 : len(key)-1]
+	labels := make(map[string]string)
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			break
+		}
+		label := rest[:eq]
+		value, err := strconv.QuotedPrefix(rest[eq+1:])
+		if err != nil {
+			break
+		}
+		labels[label], _ = strconv.Unquote(value)
+		rest = strings.TrimPrefix(rest[eq+1+len(value):], ",")
+	}
+	return name, labels
+}
+
+// Samples flattens the CPU metrics into samples.
+func (c CPUData) Samples() []Sample {
+	samples := []Sample{
+		{Name: "cpu.physical_core", Value: float64(c.PhysicalCore)},
+		{Name: "cpu.logical_core", Value: float64(c.LogicalCore)},
+		{Name: "cpu.frequency", Value: c.Frequency},
+		{Name: "cpu.current_frequency", Value: float64(c.CurrentFrequency)},
+		{Name: "cpu.free_percent", Value: c.FreePercent},
+		{Name: "cpu.usage_percent", Value: c.UsagePercent},
+	}
+	for i, temp := range c.Temperature {
+		samples = append(samples, Sample{
+			Name:   "cpu.temperature",
+			Labels: map[string]string{"sensor": strconv.Itoa(i)},
+			Value:  float64(temp),
+		})
+	}
+	return samples
+}
+
+// Samples flattens the memory metrics into samples.
+func (m MemoryData) Samples() []Sample {
+	samples := []Sample{
+		{Name: "memory.total_bytes", Value: float64(m.TotalBytes)},
+		{Name: "memory.available_bytes", Value: float64(m.AvailableBytes)},
+		{Name: "memory.used_bytes", Value: float64(m.UsedBytes)},
+	}
+	if m.UsagePercent != nil {
+		samples = append(samples, Sample{Name: "memory.usage_percent", Value: *m.UsagePercent})
+	}
+	return samples
+}
+
+// Samples flattens the disk metrics into samples labelled with the device.
+func (d DiskData) Samples() []Sample {
+	var samples []Sample
+	labels := map[string]string{"device": d.Device}
+	if d.TotalBytes != nil {
+		samples = append(samples, Sample{Name: "disk.total_bytes", Labels: labels, Value: float64(*d.TotalBytes)})
+	}
+	if d.FreeBytes != nil {
+		samples = append(samples, Sample{Name: "disk.free_bytes", Labels: labels, Value: float64(*d.FreeBytes)})
+	}
+	if d.UsagePercent != nil {
+		samples = append(samples, Sample{Name: "disk.usage_percent", Labels: labels, Value: *d.UsagePercent})
+	}
+	if d.Forecast != nil && d.Forecast.DaysUntilFull != nil {
+		samples = append(samples, Sample{Name: "disk.days_until_full", Labels: labels, Value: *d.Forecast.DaysUntilFull})
+	}
+	return samples
+}
+
+// Samples flattens all collected metrics and the derived samples into samples.
+// Host information carries no numeric values and is therefore not included.
+func (a AllMetrics) Samples() []Sample {
+	samples := a.CPU.Samples()
+	samples = append(samples, a.Memory.Samples()...)
+	for _, d := range a.Disk {
+		if disk, ok := d.(*DiskData); ok {
+			samples = append(samples, disk.Samples()...)
+		}
+	}
+	return append(samples, a.Derived...)
+}
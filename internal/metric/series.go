@@ -0,0 +1,27 @@
+package metric
+
+import "time"
+
+// Point represents the aggregated value of a series over one bucket of time.
+type Point struct {
+	Timestamp time.Time `json:"timestamp"` // Start of the bucket
+	Value     float64   `json:"value"`     // Average value over the bucket
+	Min       float64   `json:"min"`       // Minimum value over the bucket
+	Max       float64   `json:"max"`       // Maximum value over the bucket
+	Count     uint64    `json:"count"`     // Number of raw samples in the bucket
+}
+
+// Series represents the stored history of a single metric series.
+type Series struct {
+	Name       string            `json:"name"`             // Metric name
+	Labels     map[string]string `json:"labels,omitempty"` // Series labels
+	Resolution string            `json:"resolution"`       // Resolution of the points (raw, 1m or 1h)
+	Points     []Point           `json:"points"`           // Points ordered by time
+}
+
+func (s Series) isMetric() {}
+
+// SeriesSlice represents a slice of series.
+type SeriesSlice []Series
+
+func (s SeriesSlice) isMetric() {}
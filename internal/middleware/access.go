@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessList holds the networks allowed and denied to use a route group.
+// The lists can be replaced while requests are served.
+type AccessList struct {
+	mu    sync.RWMutex
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// NewAccessList creates an access list. Every network is allowed when allow is empty.
+func NewAccessList(allow []netip.Prefix, deny []netip.Prefix) *AccessList {
+	return &AccessList{allow: allow, deny: deny}
+}
+
+// Set replaces the allowed and denied networks.
+func (l *AccessList) Set(allow []netip.Prefix, deny []netip.Prefix) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.allow, l.deny = allow, deny
+}
+
+// Permitted reports whether ip passes the allow and deny lists. The deny list wins.
+func (l *AccessList) Permitted(ip netip.Addr) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return permitted(ip, l.allow, l.deny)
+}
+
+// Middleware is a middleware function that rejects clients whose IP is not
+// permitted. The client IP is resolved by gin, which only reads
+// X-Forwarded-For from the configured trusted proxies.
+func (l *AccessList) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip, err := netip.ParseAddr(c.ClientIP())
+		if err != nil || !l.Permitted(ip.Unmap()) {
+			reject(c, 403, "Access denied for this address")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// permitted reports whether ip passes the allow and deny lists. The deny list wins.
+func permitted(ip netip.Addr, allow []netip.Prefix, deny []netip.Prefix) bool {
+	for _, prefix := range deny {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	if len(allow) == 0 {
+		return true
+	}
+	for _, prefix := range allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// failures counts the failed authentications of a client within the current window.
+type failures struct {
+	count       int
+	since       time.Time
+	bannedUntil time.Time
+}
+
+// AuthBan temporarily bans client IPs after repeated failed authentications.
+// A threshold of 0 disables new bans.
+type AuthBan struct {
+	threshold int           // Failures within window that lead to a ban
+	window    time.Duration // Window in which failures are counted
+	duration  time.Duration // Length of a ban
+
+	mu      sync.Mutex
+	clients map[string]*failures
+	pruned  time.Time
+}
+
+// NewAuthBan creates a ban list banning a client for duration once it failed
+// to authenticate threshold times within window.
+func NewAuthBan(threshold int, window time.Duration, duration time.Duration) *AuthBan {
+	return &AuthBan{
+		threshold: threshold,
+		window:    window,
+		duration:  duration,
+		clients:   make(map[string]*failures),
+	}
+}
+
+// SetLimits replaces the threshold, window and ban duration. Running bans are kept.
+func (b *AuthBan) SetLimits(threshold int, window time.Duration, duration time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.threshold, b.window, b.duration = threshold, window, duration
+}
+
+// Banned returns how long ip is still banned, or zero.
+func (b *AuthBan) Banned(ip string, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, ok := b.clients[ip]
+	if !ok || !now.Before(f.bannedUntil) {
+		return 0
+	}
+	return f.bannedUntil.Sub(now)
+}
+
+// Fail records a failed authentication of ip and reports whether it is now banned.
+func (b *AuthBan) Fail(ip string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 {
+		return false
+	}
+	b.prune(now)
+
+	f, ok := b.clients[ip]
+	if !ok || now.Sub(f.since) > b.window {
+		f = &failures{since: now}
+		b.clients[ip] = f
+	}
+
+	f.count++
+	if f.count < b.threshold {
+		return false
+	}
+	f.count = 0
+	f.since = now
+	f.bannedUntil = now.Add(b.duration)
+	return true
+}
+
+// prune forgets the clients whose window and ban are over, at most once per pruneInterval.
+func (b *AuthBan) prune(now time.Time) {
+	if now.Sub(b.pruned) < pruneInterval {
+		return
+	}
+	b.pruned = now
+
+	for ip, f := range b.clients {
+		if now.Sub(f.since) > b.window && !now.Before(f.bannedUntil) {
+			delete(b.clients, ip)
+		}
+	}
+}
+
+// Middleware is a middleware function that rejects banned clients and records the
+// 401 and 403 responses of requests that AuthRequired did not authenticate,
+// including WebSockets failing to authenticate after the upgrade, see StatusKey.
+// It must run before AuthRequired. Responses denied by RequireScope do not count.
+func (b *AuthBan) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		if wait := b.Banned(ip, time.Now()); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+			reject(c, 403, "Too many failed authentication attempts")
+			return
+		}
+
+		c.Next()
+
+		status := Status(c)
+		if (status == 401 || status == 403) && CurrentToken(c) == nil {
+			b.Fail(ip, time.Now())
+		}
+	}
+}
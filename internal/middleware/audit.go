@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/audit"
+)
+
+// Audit is a middleware function that records every request in the audit log,
+// including the reason of rejected ones. It must run first, before the access
+// lists, bans, rate limits and AuthRequired, so their rejections are recorded.
+// Errors writing the log are passed to onError.
+func Audit(log *audit.Log, onError func(error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		entry := audit.Entry{
+			Time:      start.UTC(),
+			ClientIP:  c.ClientIP(),
+			Method:    c.Request.Method,
+			Route:     c.FullPath(),
+			Path:      c.Request.URL.Path,
+			Status:    Status(c),
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			Error:     c.GetString(AuthErrorKey),
+		}
+		if t := CurrentToken(c); t != nil {
+			entry.Token = t.Name
+		}
+
+		if err := log.Write(entry); err != nil {
+			onError(err)
+		}
+	}
+}
@@ -1,42 +1,198 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/tlsutil"
+	"github.com/nodebytehosting/syscapture/internal/token"
 )
 
+// TokenKey is the context key under which AuthRequired stores the authenticated *token.Token.
+const TokenKey = "token"
+
+// AuthErrorKey is the context key under which the access, ban, rate limit and authentication
+// middleware store the reason of a rejection.
+const AuthErrorKey = "auth_error"
+
+// StatusKey is the context key under which handlers of upgraded connections, such as
+// WebSockets, store the HTTP status of their outcome, e.g. 403 when the token sent
+// after the upgrade is invalid. The audit log and the bans use it in place of the
+// 101 Switching Protocols response.
+const StatusKey = "status"
+
+var (
+	// ErrTokenRequired is returned when no token is provided.
+	ErrTokenRequired = errors.New("authorization token required")
+
+	// ErrInvalidToken is returned when the token matches no configured token.
+	ErrInvalidToken = errors.New("invalid token provided")
+
+	// ErrMissingScope is returned when the token lacks the scope required by a route.
+	ErrMissingScope = errors.New("token is missing the required scope")
+
+	// ErrMalformedHeader is returned when the Authorization header is not "Bearer" followed by a token.
+	ErrMalformedHeader = errors.New("unable to parse the authorization header")
+)
+
+// ValidateToken looks up a bearer token in the store and checks it grants scope.
+// An empty scope only requires a valid token. It is shared by AuthRequired and
+// the endpoints that cannot use the Authorization header.
+func ValidateToken(store *token.Store, secret string, scope string) (*token.Token, error) {
+	// Check if the token is provided
+	if secret == "" {
+		return nil, ErrTokenRequired
+	}
+
+	// Check if the token matches a known token
+	t, err := store.Authenticate(secret)
+	if errors.Is(err, token.ErrExpired) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if scope != "" && !t.HasScope(scope) {
+		return nil, ErrMissingScope
+	}
+	return t, nil
+}
+
+// BearerToken authenticates the value of an Authorization header: a JWT
+// verified by jwtAuth when it is not nil and handles it, a token of the store
+// otherwise. It is shared by AuthRequired and the gRPC service, see AuthFailure
+// for the responses to its errors.
+func BearerToken(store *token.Store, jwtAuth *JWTAuthenticator, header string) (*token.Token, error) {
+	if header == "" {
+		return nil, ErrTokenRequired
+	}
+
+	// Check if the Authorization header is properly formatted
+	splittedHeader := strings.Split(header, " ")
+	if len(splittedHeader) != 2 || splittedHeader[0] != "Bearer" {
+		return nil, ErrMalformedHeader
+	}
+
+	if jwtAuth != nil && jwtAuth.Handles(splittedHeader[1]) {
+		return jwtAuth.Token(splittedHeader[1])
+	}
+	return ValidateToken(store, splittedHeader[1], "")
+}
+
+// AuthFailure returns the HTTP status and the message shown to clients of a
+// failed authentication: 401 for missing or malformed credentials, 403 for
+// rejected ones.
+func AuthFailure(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrTokenRequired):
+		return 401, "Authorization token required"
+	case errors.Is(err, ErrMalformedHeader):
+		return 401, "Unable to parse 'Authorization' header"
+	case errors.Is(err, ErrInvalidSignature):
+		return 403, "Invalid signature provided"
+	case errors.Is(err, ErrClockSkew):
+		return 403, "Request timestamp is outside the allowed window"
+	case errors.Is(err, ErrNonceReused):
+		return 403, "Nonce has already been used"
+	case errors.Is(err, token.ErrExpired):
+		return 403, "Token has expired"
+	default:
+		return 403, "Invalid token provided"
+	}
+}
+
 // AuthRequired is a middleware function that checks for a valid Bearer token in the Authorization header.
-func AuthRequired(secret string) gin.HandlerFunc {
+// Clients presenting a verified TLS certificate mapped to scopes do not need a Bearer token, and
+// requests carrying the credentials of one of the authenticators are authenticated by it instead.
+// The authenticated token is stored in the context under TokenKey.
+func AuthRequired(store *token.Store, authenticators ...Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		splittedHeader := strings.Split(authHeader, " ")
-
-		// Check if the Authorization header is properly formatted
-		if len(splittedHeader) != 2 || splittedHeader[0] != "Bearer" {
-			c.JSON(401, gin.H{
-				"error": "Unable to parse 'Authorization' header",
-			})
-			c.Abort()
+		if t := CertificateToken(c, store); t != nil {
+			c.Set(TokenKey, t)
+			c.Next()
 			return
 		}
 
-		token := splittedHeader[1]
+		for _, a := range authenticators {
+			t, err := a.Authenticate(c.Request)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			if err != nil {
+				status, message := AuthFailure(err)
+				reject(c, status, message)
+				return
+			}
 
-		// Check if the token is provided
-		if token == "" {
-			c.JSON(401, gin.H{"error": "Authorization token required"})
-			c.Abort()
+			c.Set(TokenKey, t)
+			c.Next()
 			return
 		}
 
-		// Check if the token matches the secret
-		if token != secret {
-			c.JSON(403, gin.H{"error": "Invalid token provided"})
-			c.Abort()
+		// JWTs are verified by the authenticators above
+		t, err := BearerToken(store, nil, c.GetHeader("Authorization"))
+		if err != nil {
+			status, message := AuthFailure(err)
+			reject(c, status, message)
 			return
 		}
 
+		c.Set(TokenKey, t)
 		c.Next()
 	}
 }
+
+// reject aborts the request with status and records the reason for the audit log.
+func reject(c *gin.Context, status int, reason string) {
+	c.Set(AuthErrorKey, reason)
+	c.JSON(status, gin.H{"error": reason})
+	c.Abort()
+}
+
+// CertificateToken returns the token mapped to the verified client certificate of the request, or nil.
+func CertificateToken(c *gin.Context, store *token.Store) *token.Token {
+	state := c.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	t, err := store.AuthenticateIdentity(tlsutil.Identities(state.VerifiedChains[0][0]))
+	if err != nil {
+		return nil
+	}
+	return t
+}
+
+// RequireScope is a middleware function that checks the token authenticated by AuthRequired grants scope.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t := CurrentToken(c)
+		if t == nil || !t.HasScope(scope) {
+			reject(c, 403, "Token is missing the '"+scope+"' scope")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Status returns the status of the outcome of the request: the one stored under
+// StatusKey if any, the status of the response otherwise.
+func Status(c *gin.Context) int {
+	if status := c.GetInt(StatusKey); status != 0 {
+		return status
+	}
+	return c.Writer.Status()
+}
+
+// CurrentToken returns the token authenticated for the request, or nil.
+func CurrentToken(c *gin.Context) *token.Token {
+	value, ok := c.Get(TokenKey)
+	if !ok {
+		return nil
+	}
+	t, _ := value.(*token.Token)
+	return t
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings supported by Compress, in order of preference.
+const (
+	EncodingZstd = "zstd"
+	EncodingGzip = "gzip"
+)
+
+var (
+	gzipWriters = sync.Pool{New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}}
+	zstdWriters = sync.Pool{New: func() any {
+		// A single goroutine per encoder, responses are small and many are compressed at once
+		w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+)
+
+// Compress is a middleware function compressing response bodies with zstd or
+// gzip, whichever the Accept-Encoding header of the client prefers. Flushes
+// are passed through, so event streams stay live. Responses without a body and
+// those setting their own Content-Encoding are left alone. Every response but
+// upgrades varies on Accept-Encoding, compressed or not, so caches do not serve
+// an uncompressed response to clients asking for a compressed one or the reverse.
+func Compress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := PreferredEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding}
+		c.Writer = w
+		defer w.close()
+
+		c.Next()
+	}
+}
+
+// PreferredEncoding returns the supported content coding an Accept-Encoding
+// header ranks highest, or "" if it accepts none. Equally ranked codings are
+// chosen in the order of preference, zstd first.
+func PreferredEncoding(header string) string {
+	qualities := make(map[string]float64)
+	for _, entry := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(entry, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		if key, value, found := strings.Cut(strings.TrimSpace(params), "="); found && strings.EqualFold(strings.TrimSpace(key), "q") {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+		qualities[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{EncodingZstd, EncodingGzip} {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressWriter compresses everything written to it once the first byte of a
+// body is written, unless the response turns out not to be compressible.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	decided  bool
+	encoder  io.Writer
+}
+
+// start decides whether to compress the body and writes the headers.
+func (w *compressWriter) start() {
+	w.decided = true
+
+	status := w.ResponseWriter.Status()
+	header := w.ResponseWriter.Header()
+	if header.Get("Content-Encoding") != "" || status == http.StatusNoContent || status == http.StatusNotModified || status < 200 {
+		return
+	}
+
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	switch w.encoding {
+	case EncodingZstd:
+		encoder := zstdWriters.Get().(*zstd.Encoder)
+		encoder.Reset(w.ResponseWriter)
+		w.encoder = encoder
+	case EncodingGzip:
+		encoder := gzipWriters.Get().(*gzip.Writer)
+		encoder.Reset(w.ResponseWriter)
+		w.encoder = encoder
+	}
+}
+
+// Write compresses b into the response.
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if len(b) == 0 {
+			return 0, nil
+		}
+		w.start()
+	}
+	if w.encoder == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.encoder.Write(b)
+}
+
+// WriteString compresses s into the response.
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends everything compressed so far to the client.
+func (w *compressWriter) Flush() {
+	switch encoder := w.encoder.(type) {
+	case *zstd.Encoder:
+		encoder.Flush()
+	case *gzip.Writer:
+		encoder.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close finishes the compressed stream and returns the encoder to its pool.
+func (w *compressWriter) close() {
+	switch encoder := w.encoder.(type) {
+	case *zstd.Encoder:
+		encoder.Close()
+		encoder.Reset(io.Discard)
+		zstdWriters.Put(encoder)
+	case *gzip.Writer:
+		encoder.Close()
+		encoder.Reset(io.Discard)
+		gzipWriters.Put(encoder)
+	}
+	w.encoder = nil
+}
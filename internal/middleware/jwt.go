@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/jwt"
+	"github.com/nodebytehosting/syscapture/internal/token"
+)
+
+// JWTAuthenticator authenticates bearer tokens that are JWTs signed by one of
+// the keys of a JWKS file. Opaque bearer tokens are left to the token store.
+// Without a verifier, JWTs are left to the token store as well.
+type JWTAuthenticator struct {
+	mu       sync.RWMutex
+	verifier *jwt.Verifier
+}
+
+// NewJWTAuthenticator creates an authenticator verifying JWTs with verifier, which may be nil.
+func NewJWTAuthenticator(verifier *jwt.Verifier) *JWTAuthenticator {
+	return &JWTAuthenticator{verifier: verifier}
+}
+
+// SetVerifier replaces the verifier. A nil verifier disables JWT authentication.
+func (a *JWTAuthenticator) SetVerifier(verifier *jwt.Verifier) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.verifier = verifier
+}
+
+// Handles reports whether raw is a JWT that this authenticator verifies.
+func (a *JWTAuthenticator) Handles(raw string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.verifier != nil && jwt.LooksLikeJWT(raw)
+}
+
+// Authenticate verifies the JWT in the Authorization header.
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*token.Token, error) {
+	scheme, credential, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || scheme != "Bearer" || !a.Handles(credential) {
+		return nil, ErrNoCredentials
+	}
+	return a.Token(credential)
+}
+
+// Token verifies a raw JWT and returns a token granting the known scopes mapped from its claims.
+func (a *JWTAuthenticator) Token(raw string) (*token.Token, error) {
+	a.mu.RLock()
+	verifier := a.verifier
+	a.mu.RUnlock()
+	if verifier == nil {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := verifier.Verify(raw, time.Now())
+	if errors.Is(err, jwt.ErrExpired) {
+		return nil, token.ErrExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make([]string, 0, len(claims.Scopes))
+	for _, scope := range claims.Scopes {
+		if token.ValidScope(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	expiresAt := claims.ExpiresAt
+	return &token.Token{
+		Name:      "jwt:" + claims.Subject,
+		Scopes:    scopes,
+		ExpiresAt: &expiresAt,
+		Static:    true,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pruneInterval is how often idle buckets and expired bans are forgotten.
+const pruneInterval = time.Minute
+
+// bucket is a token bucket holding up to burst tokens, refilled at rate tokens per second.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiter keeps a token bucket per key, such as a client IP or a token name.
+// A rate of 0 disables the limit.
+type RateLimiter struct {
+	rate  float64 // Tokens added per second
+	burst float64 // Bucket capacity
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+// NewRateLimiter creates a limiter allowing rate requests per second per key
+// with bursts of up to burst requests.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(max(burst, 1)),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// SetRate replaces the rate and burst. Existing buckets keep their tokens up to the new burst.
+func (l *RateLimiter) SetRate(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = rate
+	l.burst = float64(max(burst, 1))
+}
+
+// Allow takes a token from the bucket of key. If the bucket is empty it
+// returns false and the time until the next token is available.
+func (l *RateLimiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return true, 0
+	}
+	l.prune(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune forgets the buckets that have been refilled completely, at most once per pruneInterval.
+func (l *RateLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < pruneInterval {
+		return
+	}
+	l.pruned = now
+
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimitByIP is a middleware function that limits the requests of each client IP.
+func RateLimitByIP(limiter *RateLimiter) gin.HandlerFunc {
+	return rateLimit(limiter, func(c *gin.Context) string {
+		return c.ClientIP()
+	})
+}
+
+// RateLimitByToken is a middleware function that limits the requests of each
+// token authenticated by AuthRequired.
+func RateLimitByToken(limiter *RateLimiter) gin.HandlerFunc {
+	return rateLimit(limiter, func(c *gin.Context) string {
+		if t := CurrentToken(c); t != nil {
+			return t.Name
+		}
+		return ""
+	})
+}
+
+// rateLimit responds with 429 Too Many Requests and a Retry-After header once
+// the bucket of the key returned by keyOf is empty. Requests without a key are not limited.
+func rateLimit(limiter *RateLimiter, keyOf func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := keyOf(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if ok, wait := limiter.Allow(key, time.Now()); !ok {
+			c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+			reject(c, 429, "Rate limit exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// retryAfterSeconds rounds a wait time up to whole seconds, as used by the Retry-After header.
+func retryAfterSeconds(wait time.Duration) int {
+	return max(1, int(math.Ceil(wait.Seconds())))
+}
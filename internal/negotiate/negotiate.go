@@ -0,0 +1,131 @@
+// Package negotiate picks the response encoding a client accepts and encodes
+// responses as JSON, CBOR or MessagePack.
+package negotiate
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Media types of the supported encodings.
+const (
+	JSON        = "application/json"
+	CBOR        = "application/cbor"
+	MessagePack = "application/msgpack"
+	NDJSON      = "application/x-ndjson"
+)
+
+// aliases maps other names clients use for a media type to the supported one.
+var aliases = map[string]string{
+	"application/x-msgpack":   MessagePack,
+	"application/vnd.msgpack": MessagePack,
+	"application/jsonl":       NDJSON,
+	"application/x-jsonlines": NDJSON,
+}
+
+var (
+	cborHandle    = &codec.CborHandle{}
+	msgpackHandle = &codec.MsgpackHandle{}
+)
+
+func init() {
+	// Maps are encoded with sorted keys so equal responses encode equally.
+	// CBOR encodes times as RFC 3339 strings like JSON, MessagePack uses its
+	// timestamp extension.
+	cborHandle.Canonical = true
+	cborHandle.TimeRFC3339 = true
+	cborHandle.MapType = reflect.TypeOf(map[string]any(nil))
+	msgpackHandle.Canonical = true
+	msgpackHandle.WriteExt = true
+	msgpackHandle.MapType = reflect.TypeOf(map[string]any(nil))
+}
+
+// Negotiate returns the offered media type the Accept header prefers, or the
+// first offer when the header is empty or accepts none of them. Offers are
+// preferred in their order when the header ranks several equally.
+func Negotiate(accept string, offers ...string) string {
+	best, bestQ := "", 0.0
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, q := parseEntry(entry)
+		if q <= 0 {
+			continue
+		}
+		for _, offer := range offers {
+			if matches(mediaType, offer) && (q > bestQ || (q == bestQ && rank(offer, offers) < rank(best, offers))) {
+				best, bestQ = offer, q
+			}
+		}
+	}
+	if best == "" && len(offers) > 0 {
+		return offers[0]
+	}
+	return best
+}
+
+// parseEntry returns the media type and quality of an Accept header entry.
+func parseEntry(entry string) (string, float64) {
+	parts := strings.Split(entry, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(parts[0]))
+	if alias, ok := aliases[mediaType]; ok {
+		mediaType = alias
+	}
+
+	q := 1.0
+	for _, param := range parts[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(name, "q") {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+	}
+	return mediaType, q
+}
+
+// matches reports whether an accepted media type, possibly a wildcard, covers offer.
+func matches(accepted string, offer string) bool {
+	if accepted == "*/*" || accepted == offer {
+		return true
+	}
+	prefix, found := strings.CutSuffix(accepted, "/*")
+	return found && strings.HasPrefix(offer, prefix+"/")
+}
+
+// rank returns the position of offer in offers, or len(offers) if it is not one.
+func rank(offer string, offers []string) int {
+	for i, o := range offers {
+		if o == offer {
+			return i
+		}
+	}
+	return len(offers)
+}
+
+// Encode writes v to w in the given media type. NDJSON writes v as one line of JSON.
+func Encode(w io.Writer, mediaType string, v any) error {
+	switch mediaType {
+	case CBOR:
+		return codec.NewEncoder(w, cborHandle).Encode(v)
+	case MessagePack:
+		return codec.NewEncoder(w, msgpackHandle).Encode(v)
+	default:
+		return json.NewEncoder(w).Encode(v)
+	}
+}
+
+// Decode reads v from r in the given media type, for clients of the API.
+func Decode(r io.Reader, mediaType string, v any) error {
+	switch mediaType {
+	case CBOR:
+		return codec.NewDecoder(r, cborHandle).Decode(v)
+	case MessagePack:
+		return codec.NewDecoder(r, msgpackHandle).Decode(v)
+	default:
+		return json.NewDecoder(r).Decode(v)
+	}
+}
@@ -0,0 +1,180 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/collector"
+)
+
+// Protocols of the OTLP/HTTP exporter, named as in OTEL_EXPORTER_OTLP_PROTOCOL.
+const (
+	ProtocolProtobuf = "http/protobuf"
+	ProtocolJSON     = "http/json"
+)
+
+// Defaults of the Options.
+const (
+	DefaultInterval = 30 * time.Second
+	DefaultTimeout  = 10 * time.Second
+)
+
+// MetricsPath is the path of the metrics export appended to the endpoint.
+const MetricsPath = "/v1/metrics"
+
+// Options configures an Exporter.
+type Options struct {
+	Endpoint           string            // Base URL of the OTLP/HTTP receiver, e.g. "http://localhost:4318"
+	Protocol           string            // ProtocolProtobuf or ProtocolJSON, protobuf when empty
+	Headers            map[string]string // Headers sent with every export, e.g. for authentication
+	ResourceAttributes map[string]string // Resource attributes overriding the detected ones
+	Version            string            // Version of SysCapture, reported as service.version
+	Interval           time.Duration     // Interval between exports
+	Timeout            time.Duration     // Timeout of an export
+	Client             *http.Client      // HTTP client, http.DefaultClient when nil
+}
+
+// Exporter is a collector sink exporting the latest snapshot every interval.
+// Collected values are exported as gauges; the collection and error counts as
+// cumulative sums, so a failed export loses no counts.
+type Exporter struct {
+	opts    Options
+	url     string
+	onError func(error)
+
+	mu       sync.Mutex
+	latest   collector.Snapshot
+	pending  bool // Whether latest has not been exported yet
+	counters Counters
+}
+
+// ErrRejected is returned when the receiver rejects an export as invalid.
+var ErrRejected = errors.New("OTLP export rejected")
+
+// New creates an Exporter. onError is called with failed exports.
+func New(opts Options, onError func(error)) *Exporter {
+	if opts.Protocol == "" {
+		opts.Protocol = ProtocolProtobuf
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	return &Exporter{
+		opts:     opts,
+		url:      strings.TrimSuffix(opts.Endpoint, "/") + MetricsPath,
+		onError:  onError,
+		counters: Counters{Start: time.Now()},
+	}
+}
+
+// Consume counts the snapshot and keeps it for the next export.
+func (e *Exporter) Consume(s collector.Snapshot) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.latest = s
+	e.pending = true
+	e.counters.Collections++
+	e.counters.Errors += uint64(len(s.Errors))
+}
+
+// Run exports every interval until ctx is cancelled.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Export(ctx); err != nil {
+				e.onError(err)
+			}
+		}
+	}
+}
+
+// Export sends the latest snapshot unless it has been exported already.
+func (e *Exporter) Export(ctx context.Context) error {
+	e.mu.Lock()
+	s, pending, counters := e.latest, e.pending, e.counters
+	e.mu.Unlock()
+	if !pending {
+		return nil
+	}
+
+	resource := ResourceAttributes(s.Metrics.Host, e.opts.Version, e.opts.ResourceAttributes)
+	request := BuildRequest(s.Samples(), s.Timestamp, counters, resource, e.opts.Version)
+
+	var body []byte
+	contentType := "application/x-protobuf"
+	if e.opts.Protocol == ProtocolJSON {
+		var err error
+		if body, err = json.Marshal(request); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	} else {
+		body = request.MarshalProto()
+	}
+
+	err := e.send(ctx, body, contentType)
+	if err == nil || errors.Is(err, ErrRejected) {
+		// Sending a rejected request again would fail the same way
+		e.mu.Lock()
+		if e.latest.ID == s.ID {
+			e.pending = false
+		}
+		e.mu.Unlock()
+	}
+	return err
+}
+
+// send posts one encoded export request.
+func (e *Exporter) send(ctx context.Context, body []byte, contentType string) error {
+	ctx, cancel := context.WithTimeout(ctx, e.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range e.opts.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "SysCapture/"+e.opts.Version)
+
+	resp, err := e.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("OTLP export failed: %w", err)
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusBadGateway ||
+		resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout:
+		return fmt.Errorf("OTLP export failed with status %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("%w with status %d: %s", ErrRejected, resp.StatusCode, bytes.TrimSpace(message))
+	}
+}
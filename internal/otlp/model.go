@@ -0,0 +1,231 @@
+// Package otlp exports the collected metrics as OpenTelemetry metrics over
+// OTLP/HTTP, encoded as protobuf or JSON.
+package otlp
+
+import (
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+// ScopeName is the instrumentation scope of the exported metrics.
+const ScopeName = "github.com/nodebytehosting/syscapture"
+
+// Temporality is the aggregation temporality of a sum.
+type Temporality int
+
+// Aggregation temporalities of the OTLP AggregationTemporality enum.
+const (
+	TemporalityUnspecified Temporality = 0
+	TemporalityDelta       Temporality = 1
+	TemporalityCumulative  Temporality = 2
+)
+
+// ExportRequest mirrors opentelemetry.proto.collector.metrics.v1.ExportMetricsServiceRequest.
+// The JSON tags follow the OTLP/JSON mapping.
+type ExportRequest struct {
+	ResourceMetrics []ResourceMetrics `json:"resourceMetrics"`
+}
+
+// ResourceMetrics holds the metrics of one resource.
+type ResourceMetrics struct {
+	Resource     Resource       `json:"resource"`
+	ScopeMetrics []ScopeMetrics `json:"scopeMetrics"`
+}
+
+// Resource describes the entity producing the metrics.
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+// KeyValue is a string attribute.
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue is the value of an attribute. Only strings are used.
+type AnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// ScopeMetrics holds the metrics of one instrumentation scope.
+type ScopeMetrics struct {
+	Scope   Scope    `json:"scope"`
+	Metrics []Metric `json:"metrics"`
+}
+
+// Scope identifies the instrumentation scope.
+type Scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Metric is a named gauge or sum.
+type Metric struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+	Gauge       *Gauge `json:"gauge,omitempty"`
+	Sum         *Sum   `json:"sum,omitempty"`
+}
+
+// Gauge holds the last observed values of a metric.
+type Gauge struct {
+	DataPoints []NumberDataPoint `json:"dataPoints"`
+}
+
+// Sum holds the aggregated values of a counter.
+type Sum struct {
+	DataPoints             []NumberDataPoint `json:"dataPoints"`
+	AggregationTemporality Temporality       `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+// NumberDataPoint is a single value. Timestamps are unix nanoseconds.
+type NumberDataPoint struct {
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64     `json:"startTimeUnixNano,string,omitempty"`
+	TimeUnixNano      uint64     `json:"timeUnixNano,string"`
+	AsDouble          float64    `json:"asDouble"`
+}
+
+// Counters are the cumulative counts exported as monotonic sums, counted from Start.
+type Counters struct {
+	Start       time.Time // Start of the counts
+	Collections uint64    // Collections since Start
+	Errors      uint64    // Collector errors since Start
+}
+
+// ResourceAttributes returns the resource attributes of a host following the
+// OpenTelemetry service, host and os semantic conventions. Extra attributes
+// override the detected ones.
+func ResourceAttributes(host metric.HostData, version string, extra map[string]string) []KeyValue {
+	attrs := map[string]string{
+		"service.name": "syscapture",
+		"host.arch":    hostArch(runtime.GOARCH),
+	}
+	if version != "" {
+		attrs["service.version"] = version
+	}
+	if name, err := os.Hostname(); err == nil {
+		attrs["host.name"] = name
+	}
+	if host.Os != "" && host.Os != "unknown" {
+		attrs["os.type"] = strings.ToLower(host.Os)
+	}
+	if host.Platform != "" && host.Platform != "unknown" {
+		attrs["os.name"] = host.Platform
+	}
+	if host.KernelVersion != "" && host.KernelVersion != "unknown" {
+		attrs["os.version"] = host.KernelVersion
+	}
+	for k, v := range extra {
+		attrs[k] = v
+	}
+	return keyValues(attrs)
+}
+
+// hostArch maps a GOARCH to the host.arch values of the semantic conventions.
+func hostArch(goarch string) string {
+	switch goarch {
+	case "386":
+		return "x86"
+	case "arm":
+		return "arm32"
+	case "ppc64le", "ppc64":
+		return "ppc64"
+	default:
+		return goarch
+	}
+}
+
+// BuildRequest builds the export request of the samples of a collection at ts:
+// every sample as a gauge and the counters as cumulative monotonic sums.
+func BuildRequest(samples []metric.Sample, ts time.Time, counters Counters, resource []KeyValue, version string) ExportRequest {
+	now := uint64(ts.UnixNano())
+
+	var metrics []Metric
+	index := make(map[string]int)
+	for _, sample := range samples {
+		i, ok := index[sample.Name]
+		if !ok {
+			i = len(metrics)
+			index[sample.Name] = i
+			metrics = append(metrics, Metric{Name: sample.Name, Unit: unitOf(sample.Name), Gauge: &Gauge{}})
+		}
+		metrics[i].Gauge.DataPoints = append(metrics[i].Gauge.DataPoints, NumberDataPoint{
+			Attributes:   keyValues(sample.Labels),
+			TimeUnixNano: now,
+			AsDouble:     sample.Value,
+		})
+	}
+
+	start := uint64(counters.Start.UnixNano())
+	metrics = append(metrics,
+		cumulative("syscapture.collections", "Collections since the exporter started", "{collection}", start, now, counters.Collections),
+		cumulative("syscapture.collection_errors", "Collector errors since the exporter started", "{error}", start, now, counters.Errors),
+	)
+
+	return ExportRequest{ResourceMetrics: []ResourceMetrics{{
+		Resource: Resource{Attributes: resource},
+		ScopeMetrics: []ScopeMetrics{{
+			Scope:   Scope{Name: ScopeName, Version: version},
+			Metrics: metrics,
+		}},
+	}}}
+}
+
+// cumulative returns a monotonic sum with a single cumulative data point.
+func cumulative(name, description, unit string, start, now uint64, value uint64) Metric {
+	return Metric{
+		Name:        name,
+		Description: description,
+		Unit:        unit,
+		Sum: &Sum{
+			DataPoints:             []NumberDataPoint{{StartTimeUnixNano: start, TimeUnixNano: now, AsDouble: float64(value)}},
+			AggregationTemporality: TemporalityCumulative,
+			IsMonotonic:            true,
+		},
+	}
+}
+
+// unitOf returns the UCUM unit of a sample name.
+func unitOf(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_bytes"):
+		return "By"
+	case strings.HasSuffix(name, "_percent"):
+		return "%"
+	case strings.HasSuffix(name, "frequency"):
+		return "MHz"
+	case strings.HasSuffix(name, "temperature"):
+		return "Cel"
+	case strings.HasSuffix(name, "_core"):
+		return "{core}"
+	case strings.Contains(name, ".days_"):
+		return "d"
+	default:
+		return "1"
+	}
+}
+
+// keyValues converts a map to attributes sorted by key.
+func keyValues(m map[string]string) []KeyValue {
+	if len(m) == 0 {
+		return nil
+	}
+
+	attrs := make([]KeyValue, 0, len(m))
+	for k, v := range m {
+		attrs = append(attrs, KeyValue{Key: k, Value: AnyValue{StringValue: v}})
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Key < attrs[j].Key
+	})
+	return attrs
+}
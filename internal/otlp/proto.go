@@ -0,0 +1,340 @@
+package otlp
+
+import (
+	"errors"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ErrMalformed is returned when an export request cannot be decoded.
+var ErrMalformed = errors.New("malformed export request")
+
+// Field numbers of the OTLP metrics protobuf messages.
+const (
+	requestResourceMetrics = 1
+
+	resourceMetricsResource     = 1
+	resourceMetricsScopeMetrics = 2
+
+	resourceAttributes = 1
+
+	keyValueKey   = 1
+	keyValueValue = 2
+
+	anyValueString = 1
+
+	scopeMetricsScope   = 1
+	scopeMetricsMetrics = 2
+
+	scopeName    = 1
+	scopeVersion = 2
+
+	metricName        = 1
+	metricDescription = 2
+	metricUnit        = 3
+	metricGauge       = 5
+	metricSum         = 7
+
+	gaugeDataPoints = 1
+
+	sumDataPoints  = 1
+	sumTemporality = 2
+	sumMonotonic   = 3
+
+	pointStartTime  = 2
+	pointTime       = 3
+	pointAsDouble   = 4
+	pointAttributes = 7
+)
+
+// MarshalProto encodes the request as protobuf.
+func (r ExportRequest) MarshalProto() []byte {
+	var b []byte
+	for _, rm := range r.ResourceMetrics {
+		var msg []byte
+		msg = appendMessage(msg, resourceMetricsResource, appendAttributes(nil, resourceAttributes, rm.Resource.Attributes))
+		for _, sm := range rm.ScopeMetrics {
+			var scope []byte
+			scope = appendString(scope, scopeName, sm.Scope.Name)
+			scope = appendString(scope, scopeVersion, sm.Scope.Version)
+
+			var scopeMetrics []byte
+			scopeMetrics = appendMessage(scopeMetrics, scopeMetricsScope, scope)
+			for _, m := range sm.Metrics {
+				scopeMetrics = appendMessage(scopeMetrics, scopeMetricsMetrics, m.appendProto(nil))
+			}
+			msg = appendMessage(msg, resourceMetricsScopeMetrics, scopeMetrics)
+		}
+		b = appendMessage(b, requestResourceMetrics, msg)
+	}
+	return b
+}
+
+// appendProto appends the encoded metric to b.
+func (m Metric) appendProto(b []byte) []byte {
+	b = appendString(b, metricName, m.Name)
+	b = appendString(b, metricDescription, m.Description)
+	b = appendString(b, metricUnit, m.Unit)
+	if m.Gauge != nil {
+		var gauge []byte
+		for _, p := range m.Gauge.DataPoints {
+			gauge = appendMessage(gauge, gaugeDataPoints, p.appendProto(nil))
+		}
+		b = appendMessage(b, metricGauge, gauge)
+	}
+	if m.Sum != nil {
+		var sum []byte
+		for _, p := range m.Sum.DataPoints {
+			sum = appendMessage(sum, sumDataPoints, p.appendProto(nil))
+		}
+		sum = protowire.AppendTag(sum, sumTemporality, protowire.VarintType)
+		sum = protowire.AppendVarint(sum, uint64(m.Sum.AggregationTemporality))
+		if m.Sum.IsMonotonic {
+			sum = protowire.AppendTag(sum, sumMonotonic, protowire.VarintType)
+			sum = protowire.AppendVarint(sum, 1)
+		}
+		b = appendMessage(b, metricSum, sum)
+	}
+	return b
+}
+
+// appendProto appends the encoded data point to b.
+func (p NumberDataPoint) appendProto(b []byte) []byte {
+	if p.StartTimeUnixNano != 0 {
+		b = protowire.AppendTag(b, pointStartTime, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, p.StartTimeUnixNano)
+	}
+	b = protowire.AppendTag(b, pointTime, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, p.TimeUnixNano)
+	b = protowire.AppendTag(b, pointAsDouble, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(p.AsDouble))
+	return appendAttributes(b, pointAttributes, p.Attributes)
+}
+
+// appendAttributes appends the attributes as repeated KeyValue field num.
+func appendAttributes(b []byte, num protowire.Number, attrs []KeyValue) []byte {
+	for _, kv := range attrs {
+		var value []byte
+		value = protowire.AppendTag(value, anyValueString, protowire.BytesType)
+		value = protowire.AppendString(value, kv.Value.StringValue)
+
+		var msg []byte
+		msg = appendString(msg, keyValueKey, kv.Key)
+		msg = appendMessage(msg, keyValueValue, value)
+		b = appendMessage(b, num, msg)
+	}
+	return b
+}
+
+// appendString appends a non-empty string field.
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendMessage appends an embedded message field.
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// UnmarshalProto decodes a protobuf export request, for receivers of the
+// exported metrics. Unknown fields and attribute values other than strings are skipped.
+func UnmarshalProto(b []byte) (ExportRequest, error) {
+	var r ExportRequest
+	err := decodeMessages(b, requestResourceMetrics, func(b []byte) error {
+		var rm ResourceMetrics
+		err := decodeFields(b, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+			if typ != protowire.BytesType {
+				return nil
+			}
+			switch num {
+			case resourceMetricsResource:
+				attrs, err := decodeAttributes(value, resourceAttributes)
+				rm.Resource.Attributes = attrs
+				return err
+			case resourceMetricsScopeMetrics:
+				sm, err := decodeScopeMetrics(value)
+				rm.ScopeMetrics = append(rm.ScopeMetrics, sm)
+				return err
+			}
+			return nil
+		})
+		r.ResourceMetrics = append(r.ResourceMetrics, rm)
+		return err
+	})
+	return r, err
+}
+
+// decodeScopeMetrics decodes a ScopeMetrics message.
+func decodeScopeMetrics(b []byte) (ScopeMetrics, error) {
+	var sm ScopeMetrics
+	err := decodeFields(b, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case scopeMetricsScope:
+			return decodeFields(value, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+				switch {
+				case num == scopeName && typ == protowire.BytesType:
+					sm.Scope.Name = string(value)
+				case num == scopeVersion && typ == protowire.BytesType:
+					sm.Scope.Version = string(value)
+				}
+				return nil
+			})
+		case scopeMetricsMetrics:
+			m, err := decodeMetric(value)
+			sm.Metrics = append(sm.Metrics, m)
+			return err
+		}
+		return nil
+	})
+	return sm, err
+}
+
+// decodeMetric decodes a Metric message holding a gauge or a sum.
+func decodeMetric(b []byte) (Metric, error) {
+	var m Metric
+	err := decodeFields(b, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case metricName:
+			m.Name = string(value)
+		case metricDescription:
+			m.Description = string(value)
+		case metricUnit:
+			m.Unit = string(value)
+		case metricGauge:
+			m.Gauge = &Gauge{}
+			return decodeMessages(value, gaugeDataPoints, func(b []byte) error {
+				p, err := decodeDataPoint(b)
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, p)
+				return err
+			})
+		case metricSum:
+			m.Sum = &Sum{}
+			return decodeFields(value, func(num protowire.Number, typ protowire.Type, value []byte, n uint64) error {
+				switch {
+				case num == sumDataPoints && typ == protowire.BytesType:
+					p, err := decodeDataPoint(value)
+					m.Sum.DataPoints = append(m.Sum.DataPoints, p)
+					return err
+				case num == sumTemporality && typ == protowire.VarintType:
+					m.Sum.AggregationTemporality = Temporality(n)
+				case num == sumMonotonic && typ == protowire.VarintType:
+					m.Sum.IsMonotonic = n != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return m, err
+}
+
+// decodeDataPoint decodes a NumberDataPoint message.
+func decodeDataPoint(b []byte) (NumberDataPoint, error) {
+	var p NumberDataPoint
+	err := decodeFields(b, func(num protowire.Number, typ protowire.Type, value []byte, n uint64) error {
+		switch {
+		case num == pointStartTime && typ == protowire.Fixed64Type:
+			p.StartTimeUnixNano = n
+		case num == pointTime && typ == protowire.Fixed64Type:
+			p.TimeUnixNano = n
+		case num == pointAsDouble && typ == protowire.Fixed64Type:
+			p.AsDouble = math.Float64frombits(n)
+		case num == pointAttributes && typ == protowire.BytesType:
+			kv, err := decodeKeyValue(value)
+			p.Attributes = append(p.Attributes, kv)
+			return err
+		}
+		return nil
+	})
+	return p, err
+}
+
+// decodeAttributes decodes the repeated KeyValue field num of a message.
+func decodeAttributes(b []byte, num protowire.Number) ([]KeyValue, error) {
+	var attrs []KeyValue
+	err := decodeMessages(b, num, func(b []byte) error {
+		kv, err := decodeKeyValue(b)
+		attrs = append(attrs, kv)
+		return err
+	})
+	return attrs, err
+}
+
+// decodeKeyValue decodes a KeyValue message.
+func decodeKeyValue(b []byte) (KeyValue, error) {
+	var kv KeyValue
+	err := decodeFields(b, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		switch {
+		case num == keyValueKey && typ == protowire.BytesType:
+			kv.Key = string(value)
+		case num == keyValueValue && typ == protowire.BytesType:
+			return decodeMessages(value, anyValueString, func(b []byte) error {
+				kv.Value.StringValue = string(b)
+				return nil
+			})
+		}
+		return nil
+	})
+	return kv, err
+}
+
+// decodeMessages calls fn with the contents of every length-delimited field num of a message.
+func decodeMessages(b []byte, num protowire.Number, fn func([]byte) error) error {
+	return decodeFields(b, func(n protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		if n != num || typ != protowire.BytesType {
+			return nil
+		}
+		return fn(value)
+	})
+}
+
+// decodeFields calls fn with every field of a message: the contents of
+// length-delimited fields and the number of varint and fixed fields.
+func decodeFields(b []byte, fn func(num protowire.Number, typ protowire.Type, value []byte, n uint64) error) error {
+	for len(b) > 0 {
+		num, typ, tagLen := protowire.ConsumeTag(b)
+		if tagLen < 0 {
+			return ErrMalformed
+		}
+		b = b[tagLen:]
+
+		var value []byte
+		var n uint64
+		var fieldLen int
+		switch typ {
+		case protowire.BytesType:
+			value, fieldLen = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			n, fieldLen = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			n, fieldLen = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, fieldLen = protowire.ConsumeFixed32(b)
+			n = uint64(v)
+		default:
+			fieldLen = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if fieldLen < 0 {
+			return ErrMalformed
+		}
+		b = b[fieldLen:]
+
+		if err := fn(num, typ, value, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Package promtext renders the collected metrics in the Prometheus text
+// exposition format.
+package promtext
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// labelEscaper escapes label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// AppendSamples appends the samples to b as gauges named by
+// metric.PrometheusName, each metric preceded by its TYPE line. Samples of a
+// metric are kept together in the order they first appear.
+func AppendSamples(b []byte, samples []metric.Sample) []byte {
+	var names []string
+	byName := make(map[string][]metric.Sample)
+	for _, s := range samples {
+		if _, ok := byName[s.Name]; !ok {
+			names = append(names, s.Name)
+		}
+		byName[s.Name] = append(byName[s.Name], s)
+	}
+
+	for _, name := range names {
+		promName := metric.PrometheusName(name)
+		b = append(b, "# TYPE "...)
+		b = append(b, promName...)
+		b = append(b, " gauge\n"...)
+		for _, s := range byName[name] {
+			b = appendSeries(b, promName, s.Labels)
+			b = append(b, ' ')
+			b = strconv.AppendFloat(b, s.Value, 'f', -1, 64)
+			b = append(b, '\n')
+		}
+	}
+	return b
+}
+
+// AppendInfo appends an info metric, a gauge of value 1 carrying labels, such
+// as syscapture_host_info for the host information. Nothing is appended
+// without labels.
+func AppendInfo(b []byte, name string, labels map[string]string) []byte {
+	if len(labels) == 0 {
+		return b
+	}
+	promName := metric.PrometheusName(name)
+	b = append(b, "# TYPE "...)
+	b = append(b, promName...)
+	b = append(b, " gauge\n"...)
+	b = appendSeries(b, promName, labels)
+	return append(b, " 1\n"...)
+}
+
+// appendSeries appends the series name{label="value",...} with sorted labels.
+func appendSeries(b []byte, name string, labels map[string]string) []byte {
+	b = append(b, name...)
+	if len(labels) == 0 {
+		return b
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b = append(b, '{')
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, k...)
+		b = append(b, `="`...)
+		b = append(b, labelEscaper.Replace(labels[k])...)
+		b = append(b, '"')
+	}
+	return append(b, '}')
+}
@@ -0,0 +1,166 @@
+package remotewrite
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Label is a label of a time series.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a value of a time series at a unix timestamp in milliseconds.
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// TimeSeries is a series identified by its labels, including __name__.
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// ErrMalformed is returned when a write request cannot be decoded.
+var ErrMalformed = errors.New("malformed write request")
+
+// Field numbers of the prometheus.WriteRequest protobuf messages.
+const (
+	writeRequestTimeseries = 1
+
+	timeSeriesLabels  = 1
+	timeSeriesSamples = 2
+
+	labelName  = 1
+	labelValue = 2
+
+	sampleValue     = 1
+	sampleTimestamp = 2
+)
+
+// appendWriteRequest appends the series to b as an encoded prometheus.WriteRequest.
+// Concatenated write requests decode as one holding all their series.
+func appendWriteRequest(b []byte, series []TimeSeries) []byte {
+	for _, ts := range series {
+		sort.Slice(ts.Labels, func(i, j int) bool {
+			return ts.Labels[i].Name < ts.Labels[j].Name
+		})
+
+		var msg []byte
+		for _, l := range ts.Labels {
+			var label []byte
+			label = protowire.AppendTag(label, labelName, protowire.BytesType)
+			label = protowire.AppendString(label, l.Name)
+			label = protowire.AppendTag(label, labelValue, protowire.BytesType)
+			label = protowire.AppendString(label, l.Value)
+
+			msg = protowire.AppendTag(msg, timeSeriesLabels, protowire.BytesType)
+			msg = protowire.AppendBytes(msg, label)
+		}
+		for _, s := range ts.Samples {
+			var sample []byte
+			sample = protowire.AppendTag(sample, sampleValue, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(s.Value))
+			sample = protowire.AppendTag(sample, sampleTimestamp, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(s.Timestamp))
+
+			msg = protowire.AppendTag(msg, timeSeriesSamples, protowire.BytesType)
+			msg = protowire.AppendBytes(msg, sample)
+		}
+
+		b = protowire.AppendTag(b, writeRequestTimeseries, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+	return b
+}
+
+// DecodeWriteRequest decodes an uncompressed prometheus.WriteRequest, for
+// receivers of the pushed series. Unknown fields are skipped.
+func DecodeWriteRequest(b []byte) ([]TimeSeries, error) {
+	var series []TimeSeries
+	err := decodeFields(b, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		if num != writeRequestTimeseries || typ != protowire.BytesType {
+			return nil
+		}
+
+		var ts TimeSeries
+		err := decodeFields(value, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+			switch {
+			case num == timeSeriesLabels && typ == protowire.BytesType:
+				var l Label
+				err := decodeFields(value, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+					switch {
+					case num == labelName && typ == protowire.BytesType:
+						l.Name = string(value)
+					case num == labelValue && typ == protowire.BytesType:
+						l.Value = string(value)
+					}
+					return nil
+				})
+				ts.Labels = append(ts.Labels, l)
+				return err
+			case num == timeSeriesSamples && typ == protowire.BytesType:
+				var s Sample
+				err := decodeFields(value, func(num protowire.Number, typ protowire.Type, _ []byte, n uint64) error {
+					switch {
+					case num == sampleValue && typ == protowire.Fixed64Type:
+						s.Value = math.Float64frombits(n)
+					case num == sampleTimestamp && typ == protowire.VarintType:
+						s.Timestamp = int64(n)
+					}
+					return nil
+				})
+				ts.Samples = append(ts.Samples, s)
+				return err
+			}
+			return nil
+		})
+		series = append(series, ts)
+		return err
+	})
+	return series, err
+}
+
+// decodeFields calls fn with every field of a message: the contents of
+// length-delimited fields and the number of varint and fixed fields.
+func decodeFields(b []byte, fn func(num protowire.Number, typ protowire.Type, value []byte, n uint64) error) error {
+	for len(b) > 0 {
+		num, typ, tagLen := protowire.ConsumeTag(b)
+		if tagLen < 0 {
+			return ErrMalformed
+		}
+		b = b[tagLen:]
+
+		var value []byte
+		var n uint64
+		var fieldLen int
+		switch typ {
+		case protowire.BytesType:
+			value, fieldLen = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			n, fieldLen = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			n, fieldLen = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, fieldLen = protowire.ConsumeFixed32(b)
+			n = uint64(v)
+		default:
+			fieldLen = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if fieldLen < 0 {
+			return ErrMalformed
+		}
+		b = b[fieldLen:]
+
+		if err := fn(num, typ, value, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package remotewrite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nodebytehosting/syscapture/internal/sendbuf"
+)
+
+// queueFileSuffix is the extension of the queued write requests on disk.
+const queueFileSuffix = ".pb"
+
+// queue holds encoded write requests until they are sent, oldest first. With
+// a directory, every entry is a file so that it survives restarts; without
+// one, entries are kept in memory. The oldest entries are dropped when the
+// queue holds more than max entries.
+type queue struct {
+	dir     string
+	entries *sendbuf.Buffer[queueEntry]
+
+	mu      sync.Mutex
+	next    uint64 // Sequence number of the next entry file
+	dropped int    // Entries dropped since the last call to takeDropped
+}
+
+type queueEntry struct {
+	seq  uint64
+	data []byte // Encoded write request, read from the file when nil
+}
+
+// openQueue opens the queue in dir, picking up the entries left by a previous run.
+func openQueue(dir string, max int) (*queue, error) {
+	q := &queue{dir: dir}
+	q.entries = sendbuf.New(max, q.discard)
+	if dir == "" {
+		return q, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []queueEntry
+	for _, f := range files {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), queueFileSuffix), 10, 64)
+		if err != nil || !strings.HasSuffix(f.Name(), queueFileSuffix) {
+			// Leftovers of an interrupted write
+			os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		entries = append(entries, queueEntry{seq: seq})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+	if n := len(entries); n > 0 {
+		q.next = entries[n-1].seq + 1
+	}
+	q.dropped = q.entries.Push(entries...)
+	return q, nil
+}
+
+// push appends an encoded write request.
+func (q *queue) push(data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry := queueEntry{seq: q.next, data: data}
+	if q.dir != "" {
+		// Write to a temporary file first, so a crash never leaves a partial entry
+		path := q.path(entry.seq)
+		if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+			return err
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return err
+		}
+		entry.data = nil
+	}
+
+	q.next++
+	q.dropped += q.entries.Push(entry)
+	return nil
+}
+
+// peek returns up to n of the oldest entries, concatenated into one write
+// request, their number and the position to pass to remove once it is sent.
+// The batch stops before an entry that cannot be read; when it is the oldest,
+// an error is returned with the position removing it.
+func (q *queue) peek(n int) ([]byte, uint64, int, error) {
+	entries, end := q.entries.Peek(n)
+	start := end - uint64(len(entries))
+	var batch []byte
+	for i, entry := range entries {
+		data := entry.data
+		if data == nil {
+			var err error
+			if data, err = os.ReadFile(q.path(entry.seq)); err != nil && i == 0 {
+				return nil, start + 1, 0, fmt.Errorf("unable to read queued write request: %w", err)
+			} else if err != nil {
+				return batch, start + uint64(i), i, nil
+			}
+		}
+		batch = append(batch, data...)
+	}
+	return batch, end, len(entries), nil
+}
+
+// remove removes the entries before end, as returned by peek. Entries
+// dropped in the meantime are skipped, so newer ones are never removed.
+func (q *queue) remove(end uint64) {
+	q.entries.Remove(end)
+}
+
+// len returns the number of queued entries.
+func (q *queue) len() int {
+	return q.entries.Len()
+}
+
+// takeDropped returns the number of entries dropped because the queue was full and resets it.
+func (q *queue) takeDropped() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dropped := q.dropped
+	q.dropped = 0
+	return dropped
+}
+
+// discard removes the file of an entry leaving the queue.
+func (q *queue) discard(entry queueEntry) {
+	if q.dir != "" {
+		os.Remove(q.path(entry.seq))
+	}
+}
+
+func (q *queue) path(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, queueFileSuffix))
+}
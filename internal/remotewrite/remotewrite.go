@@ -0,0 +1,223 @@
+// Package remotewrite pushes the collected metrics to a Prometheus
+// remote_write endpoint, for nodes that cannot be scraped.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+// Defaults of the Options.
+const (
+	DefaultInterval   = 30 * time.Second
+	DefaultQueueSize  = 8640 // One day of collections every 10 seconds
+	DefaultBatchSize  = 100
+	DefaultTimeout    = 30 * time.Second
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = 5 * time.Minute
+)
+
+// Options configures a Writer.
+type Options struct {
+	URL            string
+	Username       string            // Basic auth user, with Password
+	Password       string            // Basic auth password
+	BearerToken    string            // Bearer token, instead of basic auth
+	ExternalLabels map[string]string // Labels added to every series, unless a series has the label itself
+	Interval       time.Duration     // Interval between pushes
+	QueueDir       string            // Directory of the queue, kept in memory when empty
+	QueueSize      int               // Collections kept while the endpoint is unreachable
+	BatchSize      int               // Collections sent in one request
+	Timeout        time.Duration     // Timeout of a request
+	MinBackoff     time.Duration     // First delay before retrying a failed request
+	MaxBackoff     time.Duration     // Maximum delay between retries
+	Client         *http.Client      // HTTP client, http.DefaultClient when nil
+}
+
+// Writer is a collector sink queueing every snapshot and pushing the queue to
+// the endpoint every interval. Failed pushes are retried with exponential
+// backoff; requests the endpoint rejects as invalid are dropped.
+type Writer struct {
+	opts    Options
+	queue   *queue
+	onError func(error)
+}
+
+// ErrRejected is returned when the endpoint rejects a write request as invalid.
+var ErrRejected = errors.New("remote write rejected")
+
+// New creates a Writer and opens its queue. onError is called with push
+// failures and dropped collections.
+func New(opts Options, onError func(error)) (*Writer, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(DefaultMaxBackoff, opts.MinBackoff)
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	q, err := openQueue(opts.QueueDir, opts.QueueSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open remote write queue: %w", err)
+	}
+	return &Writer{opts: opts, queue: q, onError: onError}, nil
+}
+
+// Consume queues the samples of a snapshot.
+func (w *Writer) Consume(s collector.Snapshot) {
+	timestamp := s.Timestamp.UnixMilli()
+	samples := s.Samples()
+
+	series := make([]TimeSeries, 0, len(samples))
+	for _, sample := range samples {
+		labels := make([]Label, 0, 1+len(sample.Labels)+len(w.opts.ExternalLabels))
+		labels = append(labels, Label{Name: "__name__", Value: metric.PrometheusName(sample.Name)})
+		for name, value := range sample.Labels {
+			labels = append(labels, Label{Name: name, Value: value})
+		}
+		for name, value := range w.opts.ExternalLabels {
+			if _, ok := sample.Labels[name]; !ok {
+				labels = append(labels, Label{Name: name, Value: value})
+			}
+		}
+		series = append(series, TimeSeries{
+			Labels:  labels,
+			Samples: []Sample{{Value: sample.Value, Timestamp: timestamp}},
+		})
+	}
+
+	if err := w.queue.push(appendWriteRequest(nil, series)); err != nil {
+		w.onError(err)
+	}
+	if dropped := w.queue.takeDropped(); dropped > 0 {
+		w.onError(fmt.Errorf("remote write queue is full, dropped %d collections", dropped))
+	}
+}
+
+// Queued returns the number of collections waiting to be pushed.
+func (w *Writer) Queued() int {
+	return w.queue.len()
+}
+
+// Run pushes the queue every interval until ctx is cancelled.
+func (w *Writer) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Flush(ctx)
+		}
+	}
+}
+
+// Flush pushes the whole queue in batches, retrying failed pushes with
+// exponential backoff until they succeed or ctx is cancelled.
+func (w *Writer) Flush(ctx context.Context) {
+	backoff := w.opts.MinBackoff
+	for ctx.Err() == nil {
+		batch, end, n, err := w.queue.peek(w.opts.BatchSize)
+		if err != nil {
+			// An unreadable entry would block the queue forever
+			w.onError(err)
+			w.queue.remove(end)
+			continue
+		}
+		if n == 0 {
+			return
+		}
+
+		wait, err := w.push(ctx, batch)
+		switch {
+		case err == nil:
+			w.queue.remove(end)
+			backoff = w.opts.MinBackoff
+			continue
+		case errors.Is(err, ErrRejected):
+			w.onError(err)
+			w.queue.remove(end)
+			continue
+		}
+
+		w.onError(err)
+		if wait <= 0 {
+			wait = backoff
+			backoff = min(2*backoff, w.opts.MaxBackoff)
+		}
+		wait = min(wait, w.opts.MaxBackoff)
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+	}
+}
+
+// push sends one snappy-compressed write request. The returned duration is
+// the delay requested by the endpoint with Retry-After, if any.
+func (w *Writer) push(ctx context.Context, body []byte) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.opts.URL, bytes.NewReader(snappy.Encode(nil, body)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "SysCapture")
+	switch {
+	case w.opts.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+w.opts.BearerToken)
+	case w.opts.Username != "":
+		req.SetBasicAuth(w.opts.Username, w.opts.Password)
+	}
+
+	resp, err := w.opts.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("remote write failed: %w", err)
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5:
+		var wait time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+		return wait, fmt.Errorf("remote write failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	default:
+		return 0, fmt.Errorf("%w with status %d, dropping %d bytes: %s", ErrRejected, resp.StatusCode, len(body), bytes.TrimSpace(message))
+	}
+}
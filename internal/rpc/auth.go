@@ -0,0 +1,222 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/audit"
+	"github.com/nodebytehosting/syscapture/internal/middleware"
+	"github.com/nodebytehosting/syscapture/internal/tlsutil"
+	"github.com/nodebytehosting/syscapture/internal/token"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// tokenKey is the context key under which the interceptors store the authenticated *token.Token.
+type tokenKey struct{}
+
+// UnaryAuth is an interceptor protecting unary calls like the /api/v1 routes,
+// see guard. The token must grant the scope scopeOf returns for the full
+// method name of the call.
+func UnaryAuth(opts Options, scopeOf func(method string) string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var resp any
+		err := guard(ctx, opts, info.FullMethod, scopeOf(info.FullMethod), func(ctx context.Context) error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+// StreamAuth is the interceptor protecting streaming calls like UnaryAuth.
+func StreamAuth(opts Options, scopeOf func(method string) string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return guard(ss.Context(), opts, info.FullMethod, scopeOf(info.FullMethod), func(ctx context.Context) error {
+			return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+		})
+	}
+}
+
+// CurrentToken returns the token authenticated for the call, or nil.
+func CurrentToken(ctx context.Context) *token.Token {
+	t, _ := ctx.Value(tokenKey{}).(*token.Token)
+	return t
+}
+
+// authenticatedStream carries the context holding the authenticated token.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// guard runs the checks of the /api/v1 middleware on a call in the same order:
+// the access list, bans and per-IP rate limit, then authentication and the
+// per-token rate limit. If they pass, call runs with the authenticated token.
+// Every call is written to the audit log, with the reason of rejected ones.
+func guard(ctx context.Context, opts Options, method string, scope string, call func(context.Context) error) error {
+	start := time.Now()
+	ip := clientIP(ctx)
+
+	t, err := check(ctx, opts, ip, scope)
+	reason := ""
+	if err != nil {
+		reason = status.Convert(err).Message()
+	} else {
+		err = call(context.WithValue(ctx, tokenKey{}, t))
+	}
+
+	if opts.Audit != nil {
+		entry := audit.Entry{
+			Time:      start.UTC(),
+			ClientIP:  ip,
+			Method:    "GRPC",
+			Route:     method,
+			Path:      method,
+			Status:    httpStatus(status.Code(err)),
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			Error:     reason,
+		}
+		if t != nil {
+			entry.Token = t.Name
+		}
+		if err := opts.Audit.Write(entry); err != nil && opts.OnAuditError != nil {
+			opts.OnAuditError(err)
+		}
+	}
+	return err
+}
+
+// check returns the token of a call from ip, or a status error with the same
+// message the HTTP API responds with. The token is also returned when it lacks scope.
+func check(ctx context.Context, opts Options, ip string, scope string) (*token.Token, error) {
+	now := time.Now()
+	if opts.Access != nil {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil || !opts.Access.Permitted(addr.Unmap()) {
+			return nil, status.Error(codes.PermissionDenied, "Access denied for this address")
+		}
+	}
+	if opts.Bans != nil && opts.Bans.Banned(ip, now) > 0 {
+		return nil, status.Error(codes.PermissionDenied, "Too many failed authentication attempts")
+	}
+	if opts.IPLimiter != nil {
+		if ok, _ := opts.IPLimiter.Allow(ip, now); !ok {
+			return nil, status.Error(codes.ResourceExhausted, "Rate limit exceeded")
+		}
+	}
+
+	t, err := authenticate(ctx, opts.Tokens, opts.JWT, scope)
+	if t == nil {
+		// Like on the HTTP API, only failed authentications count towards a ban
+		if opts.Bans != nil {
+			opts.Bans.Fail(ip, now)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return t, err
+	}
+
+	if opts.TokenLimiter != nil {
+		if ok, _ := opts.TokenLimiter.Allow(t.Name, now); !ok {
+			return t, status.Error(codes.ResourceExhausted, "Rate limit exceeded")
+		}
+	}
+	return t, nil
+}
+
+// authenticate returns the token of the call, or a status error with the
+// same message the HTTP API responds with, see middleware.BearerToken. HMAC
+// signatures are not accepted: they cover the method, path and body of an HTTP
+// request. The token is returned along with the error when it lacks scope.
+func authenticate(ctx context.Context, store *token.Store, jwtAuth *middleware.JWTAuthenticator, scope string) (*token.Token, error) {
+	t := certificateToken(ctx, store)
+	if t == nil {
+		var header string
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get("authorization"); len(values) > 0 {
+			header = values[0]
+		}
+
+		var err error
+		if t, err = middleware.BearerToken(store, jwtAuth, header); err != nil {
+			_, message := middleware.AuthFailure(err)
+			return nil, status.Error(codes.Unauthenticated, message)
+		}
+	}
+
+	if !t.HasScope(scope) {
+		return t, status.Error(codes.PermissionDenied, "Token is missing the '"+scope+"' scope")
+	}
+	return t, nil
+}
+
+// clientIP returns the address of the client of the call. Clients of unix
+// domain sockets have none and are reported as 127.0.0.1, like on the HTTP API.
+func clientIP(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+	}
+	return "127.0.0.1"
+}
+
+// httpStatus returns the HTTP status matching a gRPC status code, as recorded in the audit log.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return 499 // Client closed request
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// certificateToken returns the token mapped to the verified client certificate of the call, or nil.
+func certificateToken(ctx context.Context, store *token.Store) *token.Token {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	t, err := store.AuthenticateIdentity(tlsutil.Identities(info.State.VerifiedChains[0][0]))
+	if err != nil {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,114 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	pb "github.com/nodebytehosting/syscapture/internal/rpc/syscapturev1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// metricsResponse converts the metrics and errors selected by f to a response.
+func metricsResponse(id uint64, ts time.Time, f metric.Filter, m metric.AllMetrics, errs []metric.CustomErr) *pb.MetricsResponse {
+	return &pb.MetricsResponse{
+		Id:        id,
+		Timestamp: timestamppb.New(ts),
+		Metrics:   allMetrics(f, m),
+		Errors:    metricErrors(f.Errors(errs)),
+	}
+}
+
+// allMetrics converts the groups and disks selected by f, leaving the others unset.
+func allMetrics(f metric.Filter, m metric.AllMetrics) *pb.AllMetrics {
+	var all pb.AllMetrics
+	if f.Includes("cpu") {
+		all.Cpu = cpuData(m.CPU)
+	}
+	if f.Includes("memory") {
+		all.Memory = memoryData(m.Memory)
+	}
+	if f.Includes("disk") {
+		for _, d := range m.Disk {
+			if disk, ok := d.(*metric.DiskData); ok && (f.IncludesDevice(disk.Device) || disk.Device == "unknown") {
+				all.Disk = append(all.Disk, diskData(disk))
+			}
+		}
+	}
+	if f.Includes("host") {
+		all.Host = hostData(m.Host)
+	}
+	return &all
+}
+
+func cpuData(c metric.CPUData) *pb.CPUData {
+	return &pb.CPUData{
+		PhysicalCore:     int32(c.PhysicalCore),
+		LogicalCore:      int32(c.LogicalCore),
+		Frequency:        c.Frequency,
+		CurrentFrequency: int64(c.CurrentFrequency),
+		Temperature:      c.Temperature,
+		FreePercent:      c.FreePercent,
+		UsagePercent:     c.UsagePercent,
+	}
+}
+
+func memoryData(m metric.MemoryData) *pb.MemoryData {
+	return &pb.MemoryData{
+		TotalBytes:     m.TotalBytes,
+		AvailableBytes: m.AvailableBytes,
+		UsedBytes:      m.UsedBytes,
+		UsagePercent:   m.UsagePercent,
+	}
+}
+
+func diskData(d *metric.DiskData) *pb.DiskData {
+	disk := &pb.DiskData{
+		Device:       d.Device,
+		TotalBytes:   d.TotalBytes,
+		FreeBytes:    d.FreeBytes,
+		UsagePercent: d.UsagePercent,
+	}
+	if f := d.Forecast; f != nil {
+		disk.Forecast = &pb.DiskForecast{
+			DaysUntilFull:     f.DaysUntilFull,
+			GrowthBytesPerDay: f.GrowthBytesPerDay,
+			Confidence:        f.Confidence,
+			Samples:           int32(f.Samples),
+		}
+		if f.PredictedFullAt != nil {
+			disk.Forecast.PredictedFullAt = timestamppb.New(*f.PredictedFullAt)
+		}
+	}
+	return disk
+}
+
+func hostData(h metric.HostData) *pb.HostData {
+	return &pb.HostData{
+		Os:            h.Os,
+		Platform:      h.Platform,
+		KernelVersion: h.KernelVersion,
+	}
+}
+
+func processData(p metric.ProcessData) *pb.ProcessData {
+	return &pb.ProcessData{
+		Pid:           p.PID,
+		Ppid:          p.PPID,
+		Name:          p.Name,
+		Username:      p.Username,
+		Status:        p.Status,
+		Cmdline:       p.Cmdline,
+		CreatedAt:     timestamppb.New(p.CreatedAt),
+		CpuPercent:    p.CPUPercent,
+		MemoryBytes:   p.MemoryBytes,
+		MemoryPercent: p.MemoryPercent,
+	}
+}
+
+func metricErrors(errs []metric.CustomErr) []*pb.MetricError {
+	converted := make([]*pb.MetricError, 0, len(errs))
+	for _, err := range errs {
+		converted = append(converted, &pb.MetricError{Metric: err.Metric, Error: err.Error})
+	}
+	return converted
+}
@@ -0,0 +1,208 @@
+// Package rpc serves the metrics over gRPC, alongside the HTTP API.
+//
+// The service is defined in proto/syscapture/v1/syscapture.proto, from which
+// the syscapturev1 package is generated with protoc-gen-go and protoc-gen-go-grpc.
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/audit"
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/forecast"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/middleware"
+	pb "github.com/nodebytehosting/syscapture/internal/rpc/syscapturev1"
+	"github.com/nodebytehosting/syscapture/internal/token"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// maxWatchInterval is the longest interval a client can ask for between two responses.
+const maxWatchInterval = 5 * time.Minute
+
+// methodScopes are the scopes required by the methods not reading metrics.
+var methodScopes = map[string]string{
+	pb.SysCapture_ListProcesses_FullMethodName: token.ScopeProcessesRead,
+}
+
+// MethodScope returns the scope a token needs to call method, given by its full name.
+func MethodScope(method string) string {
+	if scope, ok := methodScopes[method]; ok {
+		return scope
+	}
+	return token.ScopeMetricsRead
+}
+
+// Options configure the gRPC server. The access list, bans, rate limiters,
+// JWT authenticator and audit log are shared with the HTTP API and optional.
+type Options struct {
+	Tokens       *token.Store                 // Tokens authenticating the calls
+	JWT          *middleware.JWTAuthenticator // Verifies JWT bearer tokens, nil to use the token store only
+	Access       *middleware.AccessList       // Networks allowed to call, all when nil
+	Bans         *middleware.AuthBan          // Bans clients failing to authenticate, none when nil
+	IPLimiter    *middleware.RateLimiter      // Limits the calls of each client IP, unlimited when nil
+	TokenLimiter *middleware.RateLimiter      // Limits the calls of each token, unlimited when nil
+	Audit        *audit.Log                   // Records every call, nil to disable
+	OnAuditError func(error)                  // Called with errors writing the audit log
+	Collector    *collector.Collector         // Background collector streamed by WatchMetrics
+	Forecaster   *forecast.DiskForecaster     // Disk-full forecasts, nil without history
+	TLS          *tls.Config                  // Serves TLS when set, plaintext otherwise
+}
+
+// NewServer creates a gRPC server with the SysCapture service registered.
+// Calls need a token granting the scope of their method, see MethodScope.
+func NewServer(opts Options) *grpc.Server {
+	serverOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(UnaryAuth(opts, MethodScope)),
+		grpc.ChainStreamInterceptor(StreamAuth(opts, MethodScope)),
+	}
+	if opts.TLS != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(opts.TLS)))
+	}
+
+	server := grpc.NewServer(serverOpts...)
+	pb.RegisterSysCaptureServer(server, &service{opts: opts})
+	return server
+}
+
+// service implements the SysCapture service.
+type service struct {
+	pb.UnimplementedSysCaptureServer
+	opts Options
+}
+
+// GetMetrics collects all system metrics.
+func (s *service) GetMetrics(ctx context.Context, req *pb.GetMetricsRequest) (*pb.MetricsResponse, error) {
+	return s.collect("", req.GetDevices())
+}
+
+// GetCollector runs the requested collector.
+func (s *service) GetCollector(ctx context.Context, req *pb.GetCollectorRequest) (*pb.MetricsResponse, error) {
+	if req.GetCollector() == "" {
+		return nil, status.Error(codes.InvalidArgument, "'collector' is required")
+	}
+	return s.collect(req.GetCollector(), req.GetDevices())
+}
+
+// collect runs the collectors of group, all of them when empty, like the HTTP handlers.
+func (s *service) collect(group string, devices []string) (*pb.MetricsResponse, error) {
+	filter, err := metric.ParseFilter(group, nil, devices)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	metrics, metricsErrs := metric.CollectFiltered(filter)
+	if filter.HasDevices() && filter.Includes("disk") && len(metrics.Disk) == 0 {
+		return nil, status.Error(codes.NotFound, "no disk matches the requested devices")
+	}
+	s.opts.Forecaster.Annotate(metrics.Disk)
+	return metricsResponse(0, time.Now(), filter, metrics, metricsErrs), nil
+}
+
+// WatchMetrics streams the snapshots of the background collector, thinned
+// out to the requested interval. Resuming clients first receive the
+// snapshots after last_id that are still buffered.
+func (s *service) WatchMetrics(req *pb.WatchMetricsRequest, stream pb.SysCapture_WatchMetricsServer) error {
+	col := s.opts.Collector
+	interval := col.Interval()
+	if seconds := req.GetIntervalSeconds(); seconds != 0 {
+		interval = time.Duration(seconds) * time.Second
+		if interval < col.Interval() || interval > maxWatchInterval {
+			return status.Errorf(codes.InvalidArgument, "'interval_seconds' must be between %d and %d seconds",
+				int(col.Interval().Seconds()), int(maxWatchInterval.Seconds()))
+		}
+	}
+	// Like on the HTTP stream, the filter of the collector applies by default
+	filter := col.Filter()
+	if devices := req.GetDevices(); len(devices) > 0 {
+		var err error
+		if filter, err = metric.ParseFilter("", nil, devices); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	// Subscribe before replaying so nothing collected in between is lost
+	snapshots, unsubscribe := col.Subscribe()
+	defer unsubscribe()
+
+	w := &watcher{stream: stream, filter: filter, interval: interval, tolerance: col.Interval() / 2, lastID: req.GetLastId()}
+	if w.lastID > 0 {
+		for _, snapshot := range col.Since(w.lastID) {
+			if err := w.send(snapshot); err != nil {
+				return err
+			}
+		}
+	} else if latest, ok := col.Latest(); ok {
+		if err := w.send(latest); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case snapshot := <-snapshots:
+			if err := w.send(snapshot); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ListProcesses lists the running processes.
+func (s *service) ListProcesses(ctx context.Context, req *pb.ListProcessesRequest) (*pb.ListProcessesResponse, error) {
+	var order string
+	switch req.GetOrderBy() {
+	case pb.ListProcessesRequest_ORDER_UNSPECIFIED, pb.ListProcessesRequest_ORDER_CPU:
+		order = metric.ProcessesByCPU
+	case pb.ListProcessesRequest_ORDER_MEMORY:
+		order = metric.ProcessesByMemory
+	case pb.ListProcessesRequest_ORDER_PID:
+		order = metric.ProcessesByPID
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown order %d", req.GetOrderBy())
+	}
+
+	processes, processErrs := metric.CollectProcesses(order, int(req.GetLimit()))
+	response := &pb.ListProcessesResponse{
+		Processes: make([]*pb.ProcessData, 0, len(processes)),
+		Errors:    metricErrors(processErrs),
+	}
+	for _, p := range processes {
+		response.Processes = append(response.Processes, processData(p))
+	}
+	return response, nil
+}
+
+// watcher sends snapshots to a WatchMetrics stream, thinning them out to the requested interval.
+type watcher struct {
+	stream    pb.SysCapture_WatchMetricsServer
+	filter    metric.Filter
+	interval  time.Duration
+	tolerance time.Duration // Allowed jitter of the collection timestamps
+	lastID    uint64
+	lastSent  time.Time
+}
+
+// send sends the snapshot unless it was already sent or is too close to the previous one.
+func (w *watcher) send(snapshot collector.Snapshot) error {
+	if snapshot.ID <= w.lastID {
+		return nil
+	}
+	if !w.lastSent.IsZero() && snapshot.Timestamp.Sub(w.lastSent) < w.interval-w.tolerance {
+		return nil
+	}
+
+	if err := w.stream.Send(metricsResponse(snapshot.ID, snapshot.Timestamp, w.filter, snapshot.Metrics, snapshot.Errors)); err != nil {
+		return err
+	}
+	w.lastID = snapshot.ID
+	w.lastSent = snapshot.Timestamp
+	return nil
+}
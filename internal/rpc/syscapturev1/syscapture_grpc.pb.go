@@ -0,0 +1,256 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: syscapture/v1/syscapture.proto
+
+// The SysCapture gRPC service, serving the metrics of the HTTP API to typed
+// and streaming clients. Authenticate with an "authorization: Bearer <token>"
+// metadata entry or a client certificate, like on the HTTP API. ListProcesses
+// requires the processes:read scope, the other methods metrics:read.
+
+package syscapturev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SysCapture_GetMetrics_FullMethodName    = "/syscapture.v1.SysCapture/GetMetrics"
+	SysCapture_GetCollector_FullMethodName  = "/syscapture.v1.SysCapture/GetCollector"
+	SysCapture_WatchMetrics_FullMethodName  = "/syscapture.v1.SysCapture/WatchMetrics"
+	SysCapture_ListProcesses_FullMethodName = "/syscapture.v1.SysCapture/ListProcesses"
+)
+
+// SysCaptureClient is the client API for SysCapture service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SysCaptureClient interface {
+	// GetMetrics collects all system metrics, like GET /api/v1/metrics.
+	GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*MetricsResponse, error)
+	// GetCollector runs a single collector, like GET /api/v1/metrics/{collector}.
+	// Only the group of the collector is set in the response.
+	GetCollector(ctx context.Context, in *GetCollectorRequest, opts ...grpc.CallOption) (*MetricsResponse, error)
+	// WatchMetrics streams the metrics of the background collector, like
+	// GET /api/v1/metrics/stream.
+	WatchMetrics(ctx context.Context, in *WatchMetricsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MetricsResponse], error)
+	// ListProcesses lists the running processes, requiring the processes:read scope.
+	ListProcesses(ctx context.Context, in *ListProcessesRequest, opts ...grpc.CallOption) (*ListProcessesResponse, error)
+}
+
+type sysCaptureClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSysCaptureClient(cc grpc.ClientConnInterface) SysCaptureClient {
+	return &sysCaptureClient{cc}
+}
+
+func (c *sysCaptureClient) GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*MetricsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MetricsResponse)
+	err := c.cc.Invoke(ctx, SysCapture_GetMetrics_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sysCaptureClient) GetCollector(ctx context.Context, in *GetCollectorRequest, opts ...grpc.CallOption) (*MetricsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MetricsResponse)
+	err := c.cc.Invoke(ctx, SysCapture_GetCollector_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sysCaptureClient) WatchMetrics(ctx context.Context, in *WatchMetricsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MetricsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SysCapture_ServiceDesc.Streams[0], SysCapture_WatchMetrics_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchMetricsRequest, MetricsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SysCapture_WatchMetricsClient = grpc.ServerStreamingClient[MetricsResponse]
+
+func (c *sysCaptureClient) ListProcesses(ctx context.Context, in *ListProcessesRequest, opts ...grpc.CallOption) (*ListProcessesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListProcessesResponse)
+	err := c.cc.Invoke(ctx, SysCapture_ListProcesses_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SysCaptureServer is the server API for SysCapture service.
+// All implementations must embed UnimplementedSysCaptureServer
+// for forward compatibility.
+type SysCaptureServer interface {
+	// GetMetrics collects all system metrics, like GET /api/v1/metrics.
+	GetMetrics(context.Context, *GetMetricsRequest) (*MetricsResponse, error)
+	// GetCollector runs a single collector, like GET /api/v1/metrics/{collector}.
+	// Only the group of the collector is set in the response.
+	GetCollector(context.Context, *GetCollectorRequest) (*MetricsResponse, error)
+	// WatchMetrics streams the metrics of the background collector, like
+	// GET /api/v1/metrics/stream.
+	WatchMetrics(*WatchMetricsRequest, grpc.ServerStreamingServer[MetricsResponse]) error
+	// ListProcesses lists the running processes, requiring the processes:read scope.
+	ListProcesses(context.Context, *ListProcessesRequest) (*ListProcessesResponse, error)
+	mustEmbedUnimplementedSysCaptureServer()
+}
+
+// UnimplementedSysCaptureServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSysCaptureServer struct{}
+
+func (UnimplementedSysCaptureServer) GetMetrics(context.Context, *GetMetricsRequest) (*MetricsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMetrics not implemented")
+}
+func (UnimplementedSysCaptureServer) GetCollector(context.Context, *GetCollectorRequest) (*MetricsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCollector not implemented")
+}
+func (UnimplementedSysCaptureServer) WatchMetrics(*WatchMetricsRequest, grpc.ServerStreamingServer[MetricsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchMetrics not implemented")
+}
+func (UnimplementedSysCaptureServer) ListProcesses(context.Context, *ListProcessesRequest) (*ListProcessesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProcesses not implemented")
+}
+func (UnimplementedSysCaptureServer) mustEmbedUnimplementedSysCaptureServer() {}
+func (UnimplementedSysCaptureServer) testEmbeddedByValue()                    {}
+
+// UnsafeSysCaptureServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SysCaptureServer will
+// result in compilation errors.
+type UnsafeSysCaptureServer interface {
+	mustEmbedUnimplementedSysCaptureServer()
+}
+
+func RegisterSysCaptureServer(s grpc.ServiceRegistrar, srv SysCaptureServer) {
+	// If the following call pancis, it indicates UnimplementedSysCaptureServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SysCapture_ServiceDesc, srv)
+}
+
+func _SysCapture_GetMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMetricsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SysCaptureServer).GetMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SysCapture_GetMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SysCaptureServer).GetMetrics(ctx, req.(*GetMetricsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SysCapture_GetCollector_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCollectorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SysCaptureServer).GetCollector(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SysCapture_GetCollector_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SysCaptureServer).GetCollector(ctx, req.(*GetCollectorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SysCapture_WatchMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchMetricsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SysCaptureServer).WatchMetrics(m, &grpc.GenericServerStream[WatchMetricsRequest, MetricsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SysCapture_WatchMetricsServer = grpc.ServerStreamingServer[MetricsResponse]
+
+func _SysCapture_ListProcesses_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProcessesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SysCaptureServer).ListProcesses(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SysCapture_ListProcesses_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SysCaptureServer).ListProcesses(ctx, req.(*ListProcessesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SysCapture_ServiceDesc is the grpc.ServiceDesc for SysCapture service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SysCapture_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "syscapture.v1.SysCapture",
+	HandlerType: (*SysCaptureServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetMetrics",
+			Handler:    _SysCapture_GetMetrics_Handler,
+		},
+		{
+			MethodName: "GetCollector",
+			Handler:    _SysCapture_GetCollector_Handler,
+		},
+		{
+			MethodName: "ListProcesses",
+			Handler:    _SysCapture_ListProcesses_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchMetrics",
+			Handler:       _SysCapture_WatchMetrics_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "syscapture/v1/syscapture.proto",
+}
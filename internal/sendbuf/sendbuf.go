@@ -0,0 +1,75 @@
+package sendbuf
+
+import "sync"
+
+// Buffer holds items waiting to be sent, oldest first, and drops the oldest
+// items beyond its maximum. Every item has an increasing sequence number, so
+// that a sender removes exactly the items it sent even when new ones were
+// pushed and old ones dropped while it was sending.
+type Buffer[T any] struct {
+	mu      sync.Mutex
+	max     int
+	items   []T
+	first   uint64  // Sequence number of items[0]
+	discard func(T) // Called for every removed or dropped item, may be nil
+}
+
+// New creates a Buffer keeping at most max items, or any number when max is
+// not positive. discard, if not nil, is called with every item leaving the
+// buffer, e.g. to remove a file backing it.
+func New[T any](max int, discard func(T)) *Buffer[T] {
+	return &Buffer[T]{max: max, discard: discard}
+}
+
+// Push appends items and returns the number of old items dropped to stay within the maximum.
+func (b *Buffer[T]) Push(items ...T) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.items = append(b.items, items...)
+	if b.max > 0 && len(b.items) > b.max {
+		excess := len(b.items) - b.max
+		b.removeLocked(excess)
+		return excess
+	}
+	return 0
+}
+
+// Peek returns up to n of the oldest items and the sequence number following
+// the last of them, to be passed to Remove once they are sent.
+func (b *Buffer[T]) Peek(n int) ([]T, uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n = min(n, len(b.items))
+	return append([]T(nil), b.items[:n]...), b.first + uint64(n)
+}
+
+// Remove removes the items with a sequence number lower than end. Items
+// already dropped are skipped.
+func (b *Buffer[T]) Remove(end uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if end > b.first {
+		b.removeLocked(int(min(end-b.first, uint64(len(b.items)))))
+	}
+}
+
+// Len returns the number of buffered items.
+func (b *Buffer[T]) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.items)
+}
+
+func (b *Buffer[T]) removeLocked(n int) {
+	if b.discard != nil {
+		for _, item := range b.items[:n] {
+			b.discard(item)
+		}
+	}
+	b.items = append(b.items[:0], b.items[n:]...)
+	b.first += uint64(n)
+}
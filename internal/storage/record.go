@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+)
+
+// headerSize is the size of the frame header: payload length followed by its CRC32-C checksum.
+const headerSize = 8
+
+// maxRecordSize guards against allocating huge buffers when reading a corrupted length.
+const maxRecordSize = 16 << 20
+
+var (
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	errCorrupt = errors.New("corrupt record")
+)
+
+// point holds the aggregate of a single series within a record.
+// Raw records hold exactly one sample per series, so Count is 1 and Sum, Min and Max are equal.
+type point struct {
+	key   string
+	count uint64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+// merge folds another aggregate of the same series into p.
+func (p *point) merge(o point) {
+	if p.count == 0 {
+		*p = o
+		return
+	}
+	p.count += o.count
+	p.sum += o.sum
+	p.min = math.Min(p.min, o.min)
+	p.max = math.Max(p.max, o.max)
+}
+
+// record is the unit appended to a segment file.
+type record struct {
+	timestamp time.Time
+	points    []point
+}
+
+// encode serializes the record payload.
+func (r record) encode() []byte {
+	buf := make([]byte, 0, 16+len(r.points)*48)
+	buf = binary.AppendVarint(buf, r.timestamp.UnixMilli())
+	buf = binary.AppendUvarint(buf, uint64(len(r.points)))
+	for _, p := range r.points {
+		buf = binary.AppendUvarint(buf, uint64(len(p.key)))
+		buf = append(buf, p.key...)
+		buf = binary.AppendUvarint(buf, p.count)
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(p.sum))
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(p.min))
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(p.max))
+	}
+	return buf
+}
+
+// decodeRecord deserializes a record payload produced by encode.
+func decodeRecord(buf []byte) (record, error) {
+	ts, n := binary.Varint(buf)
+	if n <= 0 {
+		return record{}, errCorrupt
+	}
+	buf = buf[n:]
+
+	count, n := binary.Uvarint(buf)
+	if n <= 0 || count > uint64(len(buf)) {
+		return record{}, errCorrupt
+	}
+	buf = buf[n:]
+
+	rec := record{
+		timestamp: time.UnixMilli(ts),
+		points:    make([]point, 0, count),
+	}
+	for i := uint64(0); i < count; i++ {
+		keyLen, n := binary.Uvarint(buf)
+		if n <= 0 || keyLen > uint64(len(buf)-n) {
+			return record{}, errCorrupt
+		}
+		buf = buf[n:]
+		key := string(buf[:keyLen])
+		buf = buf[keyLen:]
+
+		samples, n := binary.Uvarint(buf)
+		if n <= 0 || len(buf)-n < 24 {
+			return record{}, errCorrupt
+		}
+		buf = buf[n:]
+
+		rec.points = append(rec.points, point{
+			key:   key,
+			count: samples,
+			sum:   math.Float64frombits(binary.LittleEndian.Uint64(buf[0:8])),
+			min:   math.Float64frombits(binary.LittleEndian.Uint64(buf[8:16])),
+			max:   math.Float64frombits(binary.LittleEndian.Uint64(buf[16:24])),
+		})
+		buf = buf[24:]
+	}
+
+	if len(buf) != 0 {
+		return record{}, errCorrupt
+	}
+	return rec, nil
+}
+
+// writeRecord frames the record and writes it with a single write call.
+func writeRecord(w io.Writer, rec record) error {
+	payload := rec.encode()
+	frame := make([]byte, headerSize, headerSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	frame = append(frame, payload...)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// readRecords reads framed records until EOF or the first incomplete or corrupted frame.
+// It returns the offset just past the last valid record, which is where a partially
+// written tail has to be truncated to.
+func readRecords(r io.Reader, fn func(record)) (int64, error) {
+	var offset int64
+	header := make([]byte, headerSize)
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return offset, nil
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return offset, errCorrupt
+			}
+			return offset, err
+		}
+
+		size := binary.LittleEndian.Uint32(header[0:4])
+		if size > maxRecordSize {
+			return offset, errCorrupt
+		}
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return offset, errCorrupt
+			}
+			return offset, err
+		}
+
+		if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+			return offset, errCorrupt
+		}
+
+		rec, err := decodeRecord(payload)
+		if err != nil {
+			return offset, err
+		}
+
+		fn(rec)
+		offset += headerSize + int64(size)
+	}
+}
@@ -0,0 +1,332 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+)
+
+// ErrUnknownResolution is returned when a query asks for a resolution the store does not keep.
+var ErrUnknownResolution = errors.New("unknown resolution")
+
+// Store is an embedded append-only time series store.
+// Samples are written to the first (raw) tier and rolled up into the coarser tiers
+// as their buckets complete. Every tier drops segments older than its retention.
+type Store struct {
+	mu      sync.Mutex // Guards the active segments and the rollups, not needed to read the segments
+	tiers   []*tier
+	rollups []*rollup // rollups[i] aggregates tier i into tier i+1
+}
+
+// Query describes a history lookup.
+type Query struct {
+	Name       string            // Metric name, e.g. "disk.free_bytes"
+	Labels     map[string]string // Only series carrying all of these labels are returned
+	From       time.Time         // Start of the time range (inclusive)
+	To         time.Time         // End of the time range (inclusive)
+	Resolution string            // Tier to read from; picked from the time range when empty
+}
+
+// Open opens the store in dir using the DefaultTiers, creating it if needed.
+func Open(dir string) (*Store, error) {
+	return OpenWithTiers(dir, DefaultTiers)
+}
+
+// OpenWithTiers opens the store in dir with custom tiers.
+// Tiers must be ordered from the finest to the coarsest resolution.
+func OpenWithTiers(dir string, tiers []TierConfig) (*Store, error) {
+	if len(tiers) == 0 {
+		return nil, errors.New("at least one tier is required")
+	}
+
+	s := &Store{}
+	for i, cfg := range tiers {
+		if i > 0 && cfg.Resolution <= tiers[i-1].Resolution {
+			return nil, fmt.Errorf("tier %q must have a coarser resolution than %q", cfg.Name, tiers[i-1].Name)
+		}
+
+		t, err := openTier(dir, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("open tier %q: %w", cfg.Name, err)
+		}
+		s.tiers = append(s.tiers, t)
+		if i > 0 {
+			s.rollups = append(s.rollups, &rollup{resolution: cfg.Resolution})
+		}
+	}
+
+	if err := s.replay(); err != nil {
+		s.closeTiers()
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay rebuilds the in-progress rollup buckets lost on shutdown or crash.
+// Records of a tier newer than the last bucket written to the next tier are
+// fed into the rollup again, starting from the coarsest tier.
+func (s *Store) replay() error {
+	for i := len(s.rollups) - 1; i >= 0; i-- {
+		from := time.Time{}
+		if next := s.tiers[i+1]; !next.last.IsZero() {
+			from = next.last.Add(next.Resolution)
+		}
+
+		var records []record
+		if err := s.tiers[i].scan(from, time.Now().Add(time.Hour), func(rec record) {
+			records = append(records, rec)
+		}); err != nil {
+			return fmt.Errorf("replay tier %q: %w", s.tiers[i].Name, err)
+		}
+
+		sort.SliceStable(records, func(a, b int) bool {
+			return records[a].timestamp.Before(records[b].timestamp)
+		})
+		for _, rec := range records {
+			if err := s.cascade(i, rec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Append stores the samples collected at ts.
+func (s *Store) Append(ts time.Time, samples []metric.Sample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	rec := record{timestamp: ts, points: make([]point, 0, len(samples))}
+	for _, sample := range samples {
+		rec.points = append(rec.points, point{
+			key:   sample.Key(),
+			count: 1,
+			sum:   sample.Value,
+			min:   sample.Value,
+			max:   sample.Value,
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rotated, err := s.tiers[0].append(rec)
+	if err != nil {
+		return err
+	}
+	if err := s.cascade(0, rec); err != nil {
+		return err
+	}
+
+	if rotated {
+		return s.compact(ts)
+	}
+	return nil
+}
+
+// cascade feeds a record of tier i into the rollup of the next tier and writes
+// every completed bucket further down the chain.
+func (s *Store) cascade(i int, rec record) error {
+	for ; i < len(s.rollups); i++ {
+		done, ok := s.rollups[i].add(rec)
+		if !ok {
+			return nil
+		}
+		if _, err := s.tiers[i+1].append(done); err != nil {
+			return err
+		}
+		rec = done
+	}
+	return nil
+}
+
+// Query returns the stored series matching q.
+// It does not hold the lock of the store, so long scans do not hold up Append:
+// segments are only read, those expiring meanwhile are skipped, and a record
+// being appended meanwhile is left out like a corrupted tail.
+func (s *Store) Query(q Query) (metric.SeriesSlice, error) {
+	if q.To.IsZero() {
+		q.To = time.Now()
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-time.Hour)
+	}
+
+	t, err := s.pick(q)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make(map[string]*metric.Series)
+	err = t.scan(q.From, q.To, func(rec record) {
+		for _, p := range rec.points {
+			name, labels := metric.ParseSeriesKey(p.key)
+			if name != q.Name || !matchLabels(labels, q.Labels) {
+				continue
+			}
+
+			ser, ok := series[p.key]
+			if !ok {
+				ser = &metric.Series{Name: name, Labels: labels, Resolution: t.Name}
+				series[p.key] = ser
+			}
+			ser.Points = append(ser.Points, metric.Point{
+				Timestamp: rec.timestamp,
+				Value:     p.sum / float64(p.count),
+				Min:       p.min,
+				Max:       p.max,
+				Count:     p.count,
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make(metric.SeriesSlice, 0, len(keys))
+	for _, k := range keys {
+		ser := series[k]
+		sort.SliceStable(ser.Points, func(a, b int) bool {
+			return ser.Points[a].Timestamp.Before(ser.Points[b].Timestamp)
+		})
+		result = append(result, *ser)
+	}
+	return result, nil
+}
+
+// pick selects the tier a query is served from. Without an explicit resolution
+// the finest tier still retaining the start of the range is used.
+func (s *Store) pick(q Query) (*tier, error) {
+	if q.Resolution != "" {
+		for _, t := range s.tiers {
+			if t.Name == q.Resolution {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %q", ErrUnknownResolution, q.Resolution)
+	}
+
+	now := time.Now()
+	for _, t := range s.tiers {
+		if !q.From.Before(now.Add(-t.Retention)) {
+			return t, nil
+		}
+	}
+	return s.tiers[len(s.tiers)-1], nil
+}
+
+// ResolutionFor returns the name of the finest tier retaining span of history,
+// the coarsest tier when none does.
+func (s *Store) ResolutionFor(span time.Duration) string {
+	for _, t := range s.tiers {
+		if t.Retention >= span {
+			return t.Name
+		}
+	}
+	return s.tiers[len(s.tiers)-1].Name
+}
+
+// Compact syncs the active segments and removes segments past their tier's retention.
+func (s *Store) Compact(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compact(now)
+}
+
+func (s *Store) compact(now time.Time) error {
+	for _, t := range s.tiers {
+		if err := t.sync(); err != nil {
+			return err
+		}
+		if err := t.expire(now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes and closes the store. In-progress rollup buckets are rebuilt
+// from the finer tiers when the store is opened again.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closeTiers()
+}
+
+func (s *Store) closeTiers() error {
+	var errs []error
+	for _, t := range s.tiers {
+		errs = append(errs, t.closeActive())
+	}
+	return errors.Join(errs...)
+}
+
+// matchLabels reports whether labels contains every label of want.
+func matchLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// rollup accumulates records into fixed-size buckets.
+type rollup struct {
+	resolution time.Duration
+	bucket     time.Time
+	points     map[string]*point
+	order      []string
+}
+
+// add merges rec into the current bucket. When rec belongs to a later bucket the
+// completed bucket is returned as a record and a new bucket is started.
+func (r *rollup) add(rec record) (record, bool) {
+	bucket := rec.timestamp.Truncate(r.resolution)
+
+	var done record
+	flushed := false
+	if r.points != nil && bucket.After(r.bucket) {
+		done = r.flush()
+		flushed = true
+	}
+	if r.points == nil {
+		r.bucket = bucket
+		r.points = make(map[string]*point)
+	}
+
+	for _, p := range rec.points {
+		agg, ok := r.points[p.key]
+		if !ok {
+			agg = &point{key: p.key}
+			r.points[p.key] = agg
+			r.order = append(r.order, p.key)
+		}
+		agg.merge(p)
+	}
+	return done, flushed
+}
+
+// flush returns the current bucket as a record and resets the rollup.
+func (r *rollup) flush() record {
+	rec := record{timestamp: r.bucket, points: make([]point, 0, len(r.order))}
+	for _, k := range r.order {
+		rec.points = append(rec.points, *r.points[k])
+	}
+	r.points = nil
+	r.order = nil
+	return rec
+}
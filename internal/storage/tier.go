@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const segmentExt = ".seg"
+
+// TierConfig describes one resolution level of the store.
+type TierConfig struct {
+	Name        string        // Directory and resolution name, e.g. "raw" or "1m"
+	Resolution  time.Duration // Bucket size of the rollup (0 for raw samples)
+	Retention   time.Duration // How long records are kept
+	SegmentSpan time.Duration // Time range covered by a single segment file
+}
+
+// DefaultTiers keeps raw samples for 24 hours, 1-minute rollups for 7 days
+// and 1-hour rollups for a year.
+var DefaultTiers = []TierConfig{
+	{Name: "raw", Resolution: 0, Retention: 24 * time.Hour, SegmentSpan: time.Hour},
+	{Name: "1m", Resolution: time.Minute, Retention: 7 * 24 * time.Hour, SegmentSpan: 24 * time.Hour},
+	{Name: "1h", Resolution: time.Hour, Retention: 365 * 24 * time.Hour, SegmentSpan: 7 * 24 * time.Hour},
+}
+
+// tier is an append-only directory of segment files, each covering SegmentSpan of time.
+type tier struct {
+	TierConfig
+	dir string
+
+	active      *os.File  // Segment currently appended to
+	activeStart time.Time // Start of the active segment
+	last        time.Time // Timestamp of the most recent record
+}
+
+// segment identifies a segment file by the start of the time range it covers.
+type segment struct {
+	start time.Time
+	path  string
+}
+
+// openTier creates the tier directory if needed and recovers its most recent segment.
+func openTier(root string, cfg TierConfig) (*tier, error) {
+	t := &tier{TierConfig: cfg, dir: filepath.Join(root, cfg.Name)}
+	if err := os.MkdirAll(t.dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	segments, err := t.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return t, nil
+	}
+
+	if err := t.recover(segments[len(segments)-1]); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// recover scans the segment that was being written last and truncates any
+// partially written record left behind by a crash.
+func (t *tier) recover(seg segment) error {
+	f, err := os.OpenFile(seg.path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	end, readErr := readRecords(bufio.NewReader(f), func(rec record) {
+		if rec.timestamp.After(t.last) {
+			t.last = rec.timestamp
+		}
+	})
+	if readErr != nil && !errors.Is(readErr, errCorrupt) {
+		return readErr
+	}
+
+	if errors.Is(readErr, errCorrupt) {
+		if err := f.Truncate(end); err != nil {
+			return err
+		}
+		return f.Sync()
+	}
+	return nil
+}
+
+// segments lists the segment files of the tier ordered by start time.
+func (t *tier) segments() ([]segment, error) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []segment
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		start, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{
+			start: time.Unix(start, 0),
+			path:  filepath.Join(t.dir, name),
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].start.Before(segments[j].start)
+	})
+	return segments, nil
+}
+
+// append writes the record to the segment covering its timestamp.
+// It reports whether a new segment had to be opened.
+func (t *tier) append(rec record) (bool, error) {
+	start := rec.timestamp.Truncate(t.SegmentSpan)
+	rotated := false
+
+	if t.active == nil || !start.Equal(t.activeStart) {
+		if err := t.closeActive(); err != nil {
+			return false, err
+		}
+
+		path := filepath.Join(t.dir, strconv.FormatInt(start.Unix(), 10)+segmentExt)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+		if err != nil {
+			return false, err
+		}
+		t.active = f
+		t.activeStart = start
+		rotated = true
+	}
+
+	if err := writeRecord(t.active, rec); err != nil {
+		return rotated, err
+	}
+	if rec.timestamp.After(t.last) {
+		t.last = rec.timestamp
+	}
+	return rotated, nil
+}
+
+// scan calls fn for every record with a timestamp within [from, to].
+func (t *tier) scan(from, to time.Time, fn func(record)) error {
+	segments, err := t.segments()
+	if err != nil {
+		return err
+	}
+
+	for _, seg := range segments {
+		if !seg.start.Add(t.SegmentSpan).After(from) || seg.start.After(to) {
+			continue
+		}
+
+		if err := scanSegment(seg.path, func(rec record) {
+			if !rec.timestamp.Before(from) && !rec.timestamp.After(to) {
+				fn(rec)
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanSegment reads all valid records of a segment file.
+// A corrupted tail is skipped; it is truncated the next time the store is opened.
+func scanSegment(path string, fn func(record)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	if _, err := readRecords(bufio.NewReader(f), fn); err != nil && !errors.Is(err, errCorrupt) {
+		return err
+	}
+	return nil
+}
+
+// expire removes segments whose whole time range is older than the retention.
+func (t *tier) expire(now time.Time) error {
+	segments, err := t.segments()
+	if err != nil {
+		return err
+	}
+
+	cutoff := now.Add(-t.Retention)
+	for _, seg := range segments {
+		if seg.start.Add(t.SegmentSpan).After(cutoff) {
+			break
+		}
+		if t.active != nil && seg.start.Equal(t.activeStart) {
+			continue
+		}
+		if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// sync flushes the active segment to disk.
+func (t *tier) sync() error {
+	if t.active == nil {
+		return nil
+	}
+	return t.active.Sync()
+}
+
+// closeActive syncs and closes the active segment.
+func (t *tier) closeActive() error {
+	if t.active == nil {
+		return nil
+	}
+	err := t.active.Sync()
+	if closeErr := t.active.Close(); err == nil {
+		err = closeErr
+	}
+	t.active = nil
+	return err
+}
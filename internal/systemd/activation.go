@@ -0,0 +1,59 @@
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by socket activation.
+const listenFDsStart = 3
+
+// Listener is a socket passed by the service manager.
+type Listener struct {
+	net.Listener
+	Name string // FileDescriptorName= of the socket unit, "unknown" by default
+}
+
+// Listeners returns the sockets passed through $LISTEN_FDS when the service is
+// socket activated, or nothing otherwise. The variables are unset so that they
+// are not inherited by child processes.
+func Listeners() ([]Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]Listener, 0, count)
+	for i := 0; i < count; i++ {
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		// FileListener duplicates the descriptor, the original is closed with file
+		file := os.NewFile(uintptr(listenFDsStart+i), name)
+		l, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("socket %q (fd %d) is not a listening stream socket: %w", name, listenFDsStart+i, err)
+		}
+		listeners = append(listeners, Listener{Listener: l, Name: name})
+	}
+	return listeners, nil
+}
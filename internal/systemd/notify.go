@@ -0,0 +1,104 @@
+// Package systemd implements the systemd service protocols: readiness and
+// watchdog notifications and socket activation. Everything is a no-op when
+// SysCapture does not run under systemd.
+package systemd
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notification states understood by systemd.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notifier sends state notifications to the service manager.
+type Notifier struct {
+	addr *net.UnixAddr
+}
+
+// NewNotifier creates a notifier for the datagram socket path from
+// $NOTIFY_SOCKET. Notifications are dropped when socket is empty.
+func NewNotifier(socket string) *Notifier {
+	if socket == "" {
+		return &Notifier{}
+	}
+	// Abstract socket names start with '@' in the environment and a NUL byte on the wire
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	return &Notifier{addr: &net.UnixAddr{Name: socket, Net: "unixgram"}}
+}
+
+// Enabled reports whether notifications are sent.
+func (n *Notifier) Enabled() bool {
+	return n.addr != nil
+}
+
+// Notify sends the states, such as Ready or "STATUS=...", in one message.
+func (n *Notifier) Notify(states ...string) error {
+	if n.addr == nil {
+		return nil
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(strings.Join(states, "\n")))
+	return err
+}
+
+// Status returns the state setting the status line shown by systemctl status.
+func Status(status string) string {
+	return "STATUS=" + strings.ReplaceAll(status, "\n", " ")
+}
+
+// WatchdogInterval returns the watchdog timeout requested with WatchdogSec=
+// from $WATCHDOG_USEC, or 0 when the watchdog is disabled or meant for another
+// process than pid.
+func WatchdogInterval(getenv func(string) string, pid int) time.Duration {
+	usec, err := strconv.ParseInt(getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if watchdogPID := getenv("WATCHDOG_PID"); watchdogPID != "" && watchdogPID != strconv.Itoa(pid) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// RunWatchdog sends a keep-alive every interval while check reports progress,
+// until ctx is cancelled. The status returned by check is sent along, and the
+// keep-alive is withheld while check fails, so that systemd restarts a wedged
+// service once the watchdog timeout passes. onError is called when a
+// notification cannot be sent.
+func (n *Notifier) RunWatchdog(ctx context.Context, interval time.Duration, check func() (status string, ok bool), onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		status, ok := check()
+		states := []string{Status(status)}
+		if ok {
+			states = append(states, Watchdog)
+		}
+		if err := n.Notify(states...); err != nil {
+			onError(err)
+		}
+	}
+}
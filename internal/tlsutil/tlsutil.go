@@ -0,0 +1,260 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client certificate verification modes.
+const (
+	ClientAuthNone     = "none"     // Client certificates are not requested
+	ClientAuthOptional = "optional" // Client certificates are verified when presented
+	ClientAuthRequire  = "require"  // A verified client certificate is required
+)
+
+// Options configures the TLS listener.
+type Options struct {
+	CertFile     string   // PEM encoded certificate chain
+	KeyFile      string   // PEM encoded private key
+	MinVersion   string   // Minimum TLS version: "1.2" or "1.3"
+	CipherSuites []string // TLS 1.2 cipher suite names, Go defaults when empty
+	ClientCAFile string   // PEM encoded CA bundle verifying client certificates
+	ClientAuth   string   // Client certificate mode, see ClientAuth* constants
+}
+
+// Manager serves the certificate and client CA bundle to the TLS listener and
+// reloads them when their files change. Existing connections keep the
+// certificate they were established with.
+type Manager struct {
+	opts       Options
+	minVersion uint16
+	ciphers    []uint16
+	clientAuth tls.ClientAuthType
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+// NewManager validates the options and loads the certificate and CA bundle.
+func NewManager(opts Options) (*Manager, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("both a certificate and a key file are required")
+	}
+
+	m := &Manager{opts: opts, modTimes: make(map[string]time.Time)}
+
+	var err error
+	if m.minVersion, err = parseVersion(opts.MinVersion); err != nil {
+		return nil, err
+	}
+	if m.ciphers, err = parseCipherSuites(opts.CipherSuites); err != nil {
+		return nil, err
+	}
+	if m.clientAuth, err = parseClientAuth(opts.ClientAuth, opts.ClientCAFile); err != nil {
+		return nil, err
+	}
+
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Config returns the TLS configuration of the listener.
+func (m *Manager) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion:         m.minVersion,
+		GetCertificate:     m.certificate,
+		GetConfigForClient: m.configForClient,
+	}
+}
+
+// certificate returns the currently loaded certificate.
+func (m *Manager) certificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.cert, nil
+}
+
+// configForClient builds the configuration of a single handshake from the
+// currently loaded certificate and CA bundle.
+func (m *Manager) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return &tls.Config{
+		MinVersion:   m.minVersion,
+		CipherSuites: m.ciphers,
+		Certificates: []tls.Certificate{*m.cert},
+		ClientAuth:   m.clientAuth,
+		ClientCAs:    m.clientCAs,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}, nil
+}
+
+// Reload reads the certificate, key and CA bundle again. On error the
+// previously loaded files stay in use.
+func (m *Manager) Reload() error {
+	cert, err := tls.LoadX509KeyPair(m.opts.CertFile, m.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if m.opts.ClientCAFile != "" {
+		data, err := os.ReadFile(m.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("load client CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("load client CA bundle: no certificates found in %s", m.opts.ClientCAFile)
+		}
+	}
+
+	modTimes := make(map[string]time.Time)
+	for _, path := range m.files() {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+
+	m.mu.Lock()
+	m.cert = &cert
+	m.clientCAs = pool
+	m.modTimes = modTimes
+	m.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the files whenever their modification time changes, checking
+// every interval until ctx is cancelled. Reload errors are passed to onError.
+func (m *Manager) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !m.changed() {
+				continue
+			}
+			if err := m.Reload(); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// changed reports whether any watched file has a different modification time.
+func (m *Manager) changed() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, path := range m.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(m.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// files returns the paths of the watched files.
+func (m *Manager) files() []string {
+	files := []string{m.opts.CertFile, m.opts.KeyFile}
+	if m.opts.ClientCAFile != "" {
+		files = append(files, m.opts.ClientCAFile)
+	}
+	return files
+}
+
+// Identities returns the names a verified client certificate can be mapped by:
+// its common name followed by its DNS, email and URI subject alternative names.
+func Identities(cert *x509.Certificate) []string {
+	var ids []string
+	if cert.Subject.CommonName != "" {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+	ids = append(ids, cert.DNSNames...)
+	ids = append(ids, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		ids = append(ids, uri.String())
+	}
+	return ids
+}
+
+// parseVersion parses a minimum TLS version, defaulting to TLS 1.2.
+func parseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported minimum TLS version %q: use 1.2 or 1.3", version)
+	}
+}
+
+// parseCipherSuites maps cipher suite names to their IDs. Insecure suites are rejected.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseClientAuth maps a client certificate mode to its tls.ClientAuthType.
+// Without a mode, client certificates are verified when a CA bundle is configured.
+func parseClientAuth(mode string, caFile string) (tls.ClientAuthType, error) {
+	if mode == "" {
+		mode = ClientAuthNone
+		if caFile != "" {
+			mode = ClientAuthOptional
+		}
+	}
+
+	switch mode {
+	case ClientAuthNone:
+		return tls.NoClientCert, nil
+	case ClientAuthOptional, ClientAuthRequire:
+		if caFile == "" {
+			return 0, fmt.Errorf("client certificate mode %q requires a client CA bundle", mode)
+		}
+		if mode == ClientAuthRequire {
+			return tls.RequireAndVerifyClientCert, nil
+		}
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return 0, fmt.Errorf("unknown client certificate mode %q: use none, optional or require", mode)
+	}
+}
@@ -0,0 +1,374 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// secretPrefix prefixes generated secrets so they are easy to recognise in leaks.
+const secretPrefix = "sc_"
+
+// reloadInterval is how often the tokens file is checked for external changes.
+const reloadInterval = 5 * time.Second
+
+var (
+	// ErrInvalidToken is returned when a secret matches no token.
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrExpired is returned when a secret matches an expired token.
+	ErrExpired = errors.New("token expired")
+
+	// ErrNotFound is returned when a named token does not exist.
+	ErrNotFound = errors.New("token not found")
+
+	// ErrExists is returned when creating a token with a name that is already used.
+	ErrExists = errors.New("token already exists")
+
+	// ErrStatic is returned when deleting a token that is not managed by the store.
+	ErrStatic = errors.New("token is configured statically and cannot be deleted")
+
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+)
+
+// Store holds the API tokens. Tokens created through the store are persisted to
+// its file, which is also reloaded when changed by another process.
+type Store struct {
+	path string // Tokens file, tokens are only kept in memory when empty
+
+	mu         sync.RWMutex
+	tokens     map[string]*Token
+	identities map[string][]string // Client certificate identity to scopes
+	modTime    time.Time
+	lastCheck  time.Time
+}
+
+// Open loads the tokens file at path. A missing file is created on the first change.
+// With an empty path the store only keeps tokens in memory.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:       path,
+		tokens:     make(map[string]*Token),
+		identities: make(map[string][]string),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// AddStatic registers a token that is not persisted, such as the legacy API_SECRET.
+func (s *Store) AddStatic(name string, secret string, scopes []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[name] = &Token{
+		Name:      name,
+		Hash:      hash(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+		Static:    true,
+	}
+}
+
+// RemoveStatic removes a token registered with AddStatic, if any.
+func (s *Store) RemoveStatic(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.tokens[name]; ok && t.Static {
+		delete(s.tokens, name)
+	}
+}
+
+// SetIdentities replaces all client certificate identities and their scopes.
+func (s *Store) SetIdentities(identities map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.identities = make(map[string][]string, len(identities))
+	for id, scopes := range identities {
+		s.identities[id] = scopes
+	}
+}
+
+// MapIdentity grants scopes to clients presenting a verified certificate with the
+// given identity (common name or subject alternative name).
+func (s *Store) MapIdentity(identity string, scopes []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.identities[identity] = scopes
+}
+
+// AuthenticateIdentity returns a token for the first of the certificate
+// identities that is mapped to scopes.
+func (s *Store) AuthenticateIdentity(identities []string) (*Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, id := range identities {
+		if scopes, ok := s.identities[id]; ok {
+			return &Token{Name: "cert:" + id, Scopes: scopes, Static: true}, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+// Authenticate returns the token matching secret. Every token hash is compared
+// in constant time so the response time does not reveal partial matches.
+func (s *Store) Authenticate(secret string) (*Token, error) {
+	s.reloadIfChanged()
+
+	given, err := hex.DecodeString(hash(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var match *Token
+	for _, t := range s.tokens {
+		stored, err := hex.DecodeString(t.Hash)
+		if err != nil {
+			continue
+		}
+		if subtle.ConstantTimeCompare(given, stored) == 1 {
+			match = t
+		}
+	}
+
+	if match == nil {
+		return nil, ErrInvalidToken
+	}
+	if match.Expired(time.Now()) {
+		return nil, ErrExpired
+	}
+
+	found := *match
+	return &found, nil
+}
+
+// Create generates a new token and returns it together with its secret.
+// The secret is not stored and cannot be retrieved later.
+func (s *Store) Create(name string, scopes []string, expiresAt *time.Time) (*Token, string, error) {
+	if !namePattern.MatchString(name) {
+		return nil, "", fmt.Errorf("invalid token name %q: use 1-64 letters, digits, '_', '-' or '.'", name)
+	}
+	if len(scopes) == 0 {
+		return nil, "", errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !ValidScope(scope) {
+			return nil, "", fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	secret := secretPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	s.reloadIfChanged()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[name]; ok {
+		return nil, "", ErrExists
+	}
+
+	t := &Token{
+		Name:      name,
+		Hash:      hash(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	s.tokens[name] = t
+	if err := s.save(); err != nil {
+		delete(s.tokens, name)
+		return nil, "", err
+	}
+
+	created := *t
+	return &created, secret, nil
+}
+
+// Delete removes the named token. Clients using it are rejected immediately.
+func (s *Store) Delete(name string) error {
+	s.reloadIfChanged()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[name]
+	if !ok {
+		return ErrNotFound
+	}
+	if t.Static {
+		return ErrStatic
+	}
+
+	delete(s.tokens, name)
+	if err := s.save(); err != nil {
+		s.tokens[name] = t
+		return err
+	}
+	return nil
+}
+
+// List returns all tokens ordered by name.
+func (s *Store) List() []Token {
+	s.reloadIfChanged()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]Token, 0, len(s.tokens))
+	for _, t := range s.tokens {
+		tokens = append(tokens, *t)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Name < tokens[j].Name
+	})
+	return tokens
+}
+
+// Len returns the number of tokens.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.tokens)
+}
+
+// reloadIfChanged reloads the tokens file if it was modified by another process,
+// checking at most every reloadInterval.
+func (s *Store) reloadIfChanged() {
+	if s.path == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.lastCheck) < reloadInterval {
+		return
+	}
+	s.lastCheck = time.Now()
+
+	info, err := os.Stat(s.path)
+	if err != nil || info.ModTime().Equal(s.modTime) {
+		return
+	}
+	// Keep serving the tokens loaded so far if the file is invalid
+	_ = s.loadLocked()
+}
+
+// load reads the tokens file.
+func (s *Store) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.loadLocked()
+}
+
+func (s *Store) loadLocked() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var stored []*Token
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("parse tokens file %s: %w", s.path, err)
+	}
+
+	// Static tokens are not part of the file and survive a reload
+	tokens := make(map[string]*Token, len(stored))
+	for name, t := range s.tokens {
+		if t.Static {
+			tokens[name] = t
+		}
+	}
+	for _, t := range stored {
+		if _, ok := tokens[t.Name]; ok {
+			return fmt.Errorf("parse tokens file %s: duplicate token %q", s.path, t.Name)
+		}
+		tokens[t.Name] = t
+	}
+	s.tokens = tokens
+
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// save atomically writes the non-static tokens to the tokens file.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	stored := make([]*Token, 0, len(s.tokens))
+	for _, t := range s.tokens {
+		if !t.Static {
+			stored = append(stored, t)
+		}
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].Name < stored[j].Name
+	})
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".tokens-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
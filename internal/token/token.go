@@ -0,0 +1,50 @@
+package token
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"time"
+)
+
+// Scopes that can be granted to a token.
+const (
+	ScopeMetricsRead   = "metrics:read"   // Read metrics, history, streams and anomalies
+	ScopeProcessesRead = "processes:read" // Read the process list
+	ScopeNodesWrite    = "nodes:write"    // Push node metrics to a hub
+	ScopeAdmin         = "admin"          // Everything, including token management
+)
+
+// Scopes lists every known scope.
+var Scopes = []string{ScopeMetricsRead, ScopeProcessesRead, ScopeNodesWrite, ScopeAdmin}
+
+// Token represents a named API token. Only the SHA-256 hash of its secret is kept.
+type Token struct {
+	Name      string     `json:"name"`                 // Unique name of the token
+	Hash      string     `json:"hash"`                 // Hex encoded SHA-256 hash of the secret
+	Scopes    []string   `json:"scopes"`               // Granted scopes
+	CreatedAt time.Time  `json:"created_at"`           // Creation time
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Expiry time (nil if the token never expires)
+	Static    bool       `json:"-"`                    // Configured outside the store (e.g. API_SECRET), cannot be deleted
+}
+
+// HasScope reports whether the token grants scope. The admin scope grants every scope.
+func (t *Token) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, ScopeAdmin) || slices.Contains(t.Scopes, scope)
+}
+
+// Expired reports whether the token is expired at now.
+func (t *Token) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// ValidScope reports whether scope is a known scope.
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// hash returns the hex encoded SHA-256 hash of a secret.
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/middleware"
+	"github.com/nodebytehosting/syscapture/internal/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAccessList tests allow and deny lists with X-Forwarded-For from trusted proxies
+func TestAccessList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	require.NoError(t, r.SetTrustedProxies([]string{"127.0.0.1"}))
+	r.Use(middleware.NewAccessList(
+		[]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("127.0.0.1/32")},
+		[]netip.Prefix{netip.MustParsePrefix("10.0.5.0/24")},
+	).Middleware())
+	r.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(remoteAddr string, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do("10.1.2.3:5000", ""))
+	assert.Equal(t, http.StatusForbidden, do("10.0.5.7:5000", ""))
+	assert.Equal(t, http.StatusForbidden, do("192.168.1.1:5000", ""))
+
+	// The forwarded address is used behind the trusted proxy only
+	assert.Equal(t, http.StatusOK, do("127.0.0.1:5000", "10.1.2.3"))
+	assert.Equal(t, http.StatusForbidden, do("127.0.0.1:5000", "192.168.1.1"))
+	assert.Equal(t, http.StatusForbidden, do("192.168.1.1:5000", "10.1.2.3"))
+}
+
+// TestRateLimiter tests the token bucket refill and the 429 response
+func TestRateLimiter(t *testing.T) {
+	limiter := middleware.NewRateLimiter(2, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		ok, _ := limiter.Allow("a", now)
+		assert.True(t, ok)
+	}
+	ok, wait := limiter.Allow("a", now)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// Other keys have their own bucket
+	ok, _ = limiter.Allow("b", now)
+	assert.True(t, ok)
+
+	ok, _ = limiter.Allow("a", now.Add(500*time.Millisecond))
+	assert.True(t, ok)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RateLimitByIP(middleware.NewRateLimiter(0.1, 1)))
+	r.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+}
+
+// TestAuthBan tests that repeated authentication failures ban the client
+func TestAuthBan(t *testing.T) {
+	store, err := token.Open("")
+	require.NoError(t, err)
+	store.AddStatic("reader", "reader-secret", []string{token.ScopeMetricsRead})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	bans := middleware.NewAuthBan(3, time.Minute, time.Hour)
+	r.Use(bans.Middleware(), middleware.AuthRequired(store))
+	r.GET("/metrics", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.GET("/tokens", middleware.RequireScope(token.ScopeAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(path string, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+secret)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Missing scopes are not authentication failures
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusForbidden, do("/tokens", "reader-secret").Code)
+	}
+	assert.Equal(t, http.StatusOK, do("/metrics", "reader-secret").Code)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusForbidden, do("/metrics", "guess").Code)
+	}
+
+	// Even the right secret is rejected while banned
+	w := do("/metrics", "reader-secret")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Too many failed authentication attempts")
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,72 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/anomaly"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAnomalyDetector tests that a spike against a steady seasonal baseline is flagged
+func TestAnomalyDetector(t *testing.T) {
+	detector := anomaly.New(anomaly.Config{
+		Metrics:   []string{"cpu.usage_percent"},
+		Threshold: 3,
+		Alpha:     0.1,
+		Warmup:    anomaly.DefaultWarmup,
+	})
+
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	for i := 0; i < 60; i++ {
+		value := 0.5 + 0.02*float64(i%3-1)
+		found := detector.Observe(start.Add(time.Duration(i)*10*time.Second), []metric.Sample{
+			{Name: "cpu.usage_percent", Value: value},
+			{Name: "memory.usage_percent", Value: 0.99}, // Not watched
+		})
+		assert.Empty(t, found)
+	}
+
+	spike := start.Add(11 * time.Minute)
+	found := detector.Observe(spike, []metric.Sample{{Name: "cpu.usage_percent", Value: 0.95}})
+	require.Len(t, found, 1)
+	assert.Equal(t, "cpu.usage_percent", found[0].Metric)
+	assert.Greater(t, found[0].ZScore, 3.0)
+	assert.InDelta(t, 0.5, found[0].Expected, 0.02)
+
+	// A different hour of the week has its own, still empty, baseline
+	assert.Empty(t, detector.Observe(spike.Add(time.Hour), []metric.Sample{{Name: "cpu.usage_percent", Value: 0.95}}))
+
+	assert.Len(t, detector.Anomalies(start), 1)
+	assert.Empty(t, detector.Anomalies(spike.Add(time.Second)))
+}
+
+// TestAnomalyAnnotate tests that the anomaly state is added to the samples of a collection
+func TestAnomalyAnnotate(t *testing.T) {
+	detector := anomaly.New(anomaly.Config{
+		Metrics:   []string{"cpu.usage_percent"},
+		Threshold: 3,
+		Alpha:     0.1,
+		Warmup:    anomaly.DefaultWarmup,
+	})
+
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	for i := 0; i < 60; i++ {
+		m := metric.AllMetrics{CPU: metric.CPUData{UsagePercent: 0.5 + 0.02*float64(i%3-1)}}
+		detector.Annotate(start.Add(time.Duration(i)*10*time.Second), &m)
+	}
+
+	m := metric.AllMetrics{CPU: metric.CPUData{UsagePercent: 0.95}}
+	detector.Annotate(start.Add(11*time.Minute), &m)
+
+	values := make(map[string]float64)
+	for _, s := range m.Samples() {
+		if s.Labels["metric"] == "cpu.usage_percent" {
+			values[s.Name] = s.Value
+		}
+	}
+	assert.Equal(t, 1.0, values["anomaly.active"])
+	assert.Greater(t, values["anomaly.z_score"], 3.0)
+}
@@ -0,0 +1,155 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/audit"
+	"github.com/nodebytehosting/syscapture/internal/middleware"
+	"github.com/nodebytehosting/syscapture/internal/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAuditLog tests rotation and queries across rotated files
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := audit.Open(audit.Options{Destination: path, MaxSize: 1024, MaxFiles: 2})
+	require.NoError(t, err)
+
+	start := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < 30; i++ {
+		status := http.StatusOK
+		if i%10 == 0 {
+			status = http.StatusForbidden
+		}
+		require.NoError(t, log.Write(audit.Entry{
+			Time:   start.Add(time.Duration(i) * time.Second),
+			Token:  "panel",
+			Method: http.MethodGet,
+			Route:  "/api/v1/metrics",
+			Status: status,
+		}))
+	}
+	require.NoError(t, log.Close())
+
+	// The oldest entries were dropped with the oldest rotated file
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(1024))
+	assert.FileExists(t, path+".2")
+	assert.NoFileExists(t, path+".3")
+
+	log, err = audit.Open(audit.Options{Destination: path, MaxSize: 1024, MaxFiles: 2})
+	require.NoError(t, err)
+	defer log.Close()
+
+	entries, err := log.Query(audit.Query{})
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+	assert.Less(t, len(entries), 30)
+	assert.Equal(t, start.Add(29*time.Second), entries[len(entries)-1].Time)
+
+	entries, err = log.Query(audit.Query{Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, start.Add(27*time.Second), entries[0].Time)
+
+	entries, err = log.Query(audit.Query{Failed: true, Since: start.Add(15 * time.Second)})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, start.Add(20*time.Second), entries[0].Time)
+}
+
+// TestAuditMiddleware tests that requests are recorded with their token or failure reason
+func TestAuditMiddleware(t *testing.T) {
+	log, err := audit.Open(audit.Options{Destination: filepath.Join(t.TempDir(), "audit.log")})
+	require.NoError(t, err)
+	defer log.Close()
+
+	store, err := token.Open("")
+	require.NoError(t, err)
+	store.AddStatic("reader", "reader-secret", []string{token.ScopeMetricsRead})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Audit(log, func(err error) { t.Error(err) }), middleware.AuthRequired(store))
+	r.GET("/metrics/:kind", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.GET("/tokens", middleware.RequireScope(token.ScopeAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, tc := range []struct{ path, secret string }{
+		{"/metrics/cpu", "reader-secret"},
+		{"/metrics/cpu", "wrong-secret"},
+		{"/tokens", "reader-secret"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.secret)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	entries, err := log.Query(audit.Query{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, "reader", entries[0].Token)
+	assert.Equal(t, "/metrics/:kind", entries[0].Route)
+	assert.Equal(t, "/metrics/cpu", entries[0].Path)
+	assert.Equal(t, http.StatusOK, entries[0].Status)
+	assert.Empty(t, entries[0].Error)
+
+	assert.Empty(t, entries[1].Token)
+	assert.Equal(t, http.StatusForbidden, entries[1].Status)
+	assert.Equal(t, "Invalid token provided", entries[1].Error)
+
+	assert.Equal(t, "reader", entries[2].Token)
+	assert.Equal(t, "Token is missing the 'admin' scope", entries[2].Error)
+}
+
+// TestAuditRejections tests that requests rejected before authentication are recorded
+func TestAuditRejections(t *testing.T) {
+	log, err := audit.Open(audit.Options{Destination: filepath.Join(t.TempDir(), "audit.log")})
+	require.NoError(t, err)
+	defer log.Close()
+
+	store, err := token.Open("")
+	require.NoError(t, err)
+	store.AddStatic("reader", "reader-secret", []string{token.ScopeMetricsRead})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(
+		middleware.Audit(log, func(err error) { t.Error(err) }),
+		middleware.NewAccessList(nil, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}).Middleware(),
+		middleware.RateLimitByIP(middleware.NewRateLimiter(1, 1)),
+		middleware.AuthRequired(store),
+	)
+	r.GET("/metrics", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, remote := range []string{"10.0.0.1:1234", "192.0.2.1:1234", "192.0.2.1:1234"} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("Authorization", "Bearer reader-secret")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	entries, err := log.Query(audit.Query{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, http.StatusForbidden, entries[0].Status)
+	assert.Equal(t, "Access denied for this address", entries[0].Error)
+	assert.Equal(t, http.StatusOK, entries[1].Status)
+	assert.Equal(t, http.StatusTooManyRequests, entries[2].Status)
+	assert.Equal(t, "Rate limit exceeded", entries[2].Error)
+}
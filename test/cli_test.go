@@ -0,0 +1,64 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nodebytehosting/syscapture/internal/cli"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCollectCommand tests the exit status and output of "syscapture collect"
+func TestCollectCommand(t *testing.T) {
+	free := uint64(1024)
+	collected := metric.AllMetrics{
+		CPU:  metric.CPUData{LogicalCore: 4, UsagePercent: 0.125},
+		Disk: metric.MetricsSlice{&metric.DiskData{Device: "/dev/sda1", FreeBytes: &free}},
+	}
+	var errs []metric.CustomErr
+	collect := func(filter metric.Filter) (metric.AllMetrics, []metric.CustomErr) {
+		m := collected
+		if !filter.IncludesDevice("/dev/sda1") {
+			m.Disk = nil
+		}
+		return m, errs
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		errs   []metric.CustomErr
+		status int
+		stdout string // Expected in stdout
+		stderr string // Expected in stderr
+	}{
+		{name: "all", args: nil, status: 0, stdout: `"usage_percent": 0.125`},
+		{name: "flags after targets", args: []string{"cpu", "-format", "prometheus"}, status: 0, stdout: "cpu_usage_percent 0.125"},
+		{name: "flags around targets", args: []string{"-format", "table", "disk", "-device", "/dev/sda1"}, status: 0, stdout: "/dev/sda1"},
+		{name: "help", args: []string{"-h"}, status: 0, stderr: "Usage: syscapture collect"},
+		{
+			name:   "collector error",
+			args:   []string{"cpu"},
+			errs:   []metric.CustomErr{{Metric: []string{"cpu.temperature"}, Error: "no sensors"}},
+			status: 1,
+			stdout: `"usage_percent": 0.125`,
+			stderr: "cpu.temperature: no sensors",
+		},
+		{name: "other collector error", args: []string{"disk"}, errs: []metric.CustomErr{{Metric: []string{"cpu.temperature"}, Error: "no sensors"}}, status: 0},
+		{name: "unknown device", args: []string{"disk", "-device", "/dev/sdz"}, status: 1, stderr: "No disk matches"},
+		{name: "unknown flag", args: []string{"-verbose"}, status: 2, stderr: "flag provided but not defined"},
+		{name: "unknown format", args: []string{"cpu", "-format", "xml"}, status: 2, stderr: `Unsupported format "xml"`},
+		{name: "unknown collector", args: []string{"gpu"}, status: 2, stderr: "use cpu, memory, disk, host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs = tt.errs
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, tt.status, cli.Collect(tt.args, &stdout, &stderr, collect), stderr.String())
+			assert.Contains(t, stdout.String(), tt.stdout)
+			assert.Contains(t, stderr.String(), tt.stderr)
+		})
+	}
+}
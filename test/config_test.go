@@ -0,0 +1,298 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/config"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a configuration file with the given name and content
+func writeConfig(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// envOf returns a getenv function reading from env
+func envOf(env map[string]string) func(string) string {
+	return func(name string) string {
+		return env[name]
+	}
+}
+
+// TestConfigFile tests loading YAML and TOML files with environment overrides
+func TestConfigFile(t *testing.T) {
+	yamlPath := writeConfig(t, "syscapture.yaml", `
+port: 43000
+auth:
+  api_secret: file-secret
+  hmac:
+    keys:
+      panel: panel-key-0123456789abcdef0123456789
+    key_scopes:
+      panel: [metrics:read]
+    max_skew: 1m
+access:
+  allow: [10.0.0.0/8]
+  rate_limit_ip: 5
+  ban_threshold: 0
+`)
+	tomlPath := writeConfig(t, "syscapture.toml", `
+port = 43000
+
+[auth]
+api_secret = "file-secret"
+
+[auth.hmac]
+max_skew = "1m"
+
+[auth.hmac.keys]
+panel = "panel-key-0123456789abcdef0123456789"
+
+[auth.hmac.key_scopes]
+panel = ["metrics:read"]
+
+[access]
+allow = ["10.0.0.0/8"]
+rate_limit_ip = 5.0
+ban_threshold = 0
+`)
+
+	for _, path := range []string{yamlPath, tomlPath} {
+		cfg, err := config.Load(path, envOf(nil))
+		require.NoError(t, err, path)
+		assert.Equal(t, "43000", cfg.Port)
+		assert.Equal(t, "file-secret", cfg.APISecret)
+		assert.Equal(t, map[string]string{"panel": "panel-key-0123456789abcdef0123456789"}, cfg.HMAC.Keys)
+		assert.Equal(t, []string{"metrics:read"}, cfg.HMAC.Scopes["panel"])
+		assert.Equal(t, time.Minute, cfg.HMAC.MaxSkew)
+		require.Len(t, cfg.Access.Allow, 1)
+		assert.Equal(t, "10.0.0.0/8", cfg.Access.Allow[0].String())
+		assert.Equal(t, 5.0, cfg.Access.IPRate)
+		assert.Zero(t, cfg.Access.BanThreshold)
+	}
+
+	// Environment variables override the file
+	cfg, err := config.Load(yamlPath, envOf(map[string]string{
+		"PORT":          "44000",
+		"RATE_LIMIT_IP": "0",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "44000", cfg.Port)
+	assert.Zero(t, cfg.Access.IPRate)
+	assert.Equal(t, "file-secret", cfg.APISecret)
+}
+
+// TestConfigFileInvalid tests that unknown keys and invalid values are rejected
+func TestConfigFileInvalid(t *testing.T) {
+	_, err := config.Load(writeConfig(t, "syscapture.yaml", "auth:\n  api_secet: typo\n"), envOf(nil))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "api_secet")
+
+	_, err = config.Load(writeConfig(t, "syscapture.toml", "[storage]\npaht = \"/tmp\"\n"), envOf(nil))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "paht")
+
+	_, err = config.Load(writeConfig(t, "syscapture.ini", "port=1\n"), envOf(nil))
+	assert.Error(t, err)
+
+	// Every invalid setting is reported at once
+	_, err = config.Load(writeConfig(t, "syscapture.yaml", `
+port: 70000
+listen: [localhost]
+auth:
+  api_secret: secret
+  hmac:
+    max_skew: soon
+access:
+  allow: [not-an-address]
+remote_write:
+  url: ftp://prom.example.com
+  external_labels:
+    0bad: value
+`), envOf(nil))
+	require.Error(t, err)
+	// Values read from the file are reported by their file key
+	assert.Contains(t, err.Error(), "port")
+	assert.Contains(t, err.Error(), "listen")
+	assert.Contains(t, err.Error(), "auth.hmac.max_skew")
+	assert.Contains(t, err.Error(), "access.allow")
+	assert.Contains(t, err.Error(), "remote_write.url")
+	assert.Contains(t, err.Error(), "remote_write.external_labels")
+	assert.NotContains(t, err.Error(), "IP_ALLOW")
+	assert.NotContains(t, err.Error(), "HMAC_MAX_SKEW")
+
+	// Values overridden by the environment keep the variable name
+	_, err = config.Load(writeConfig(t, "syscapture.yaml", "auth:\n  api_secret: secret\n"), envOf(map[string]string{"IP_ALLOW": "not-an-address"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "IP_ALLOW")
+}
+
+// TestConfigReloadable tests that settings needing a restart are kept and reported
+func TestConfigReloadable(t *testing.T) {
+	current, err := config.Load("", envOf(map[string]string{"API_SECRET": "old"}))
+	require.NoError(t, err)
+
+	next, err := config.Load("", envOf(map[string]string{
+		"API_SECRET":    "new",
+		"PORT":          "43000",
+		"RATE_LIMIT_IP": "1",
+		"COLLECTORS":    "cpu",
+	}))
+	require.NoError(t, err)
+
+	merged, restart := current.Reloadable(next)
+	assert.Equal(t, []string{"PORT", "LISTEN"}, restart)
+	assert.Equal(t, current.Port, merged.Port)
+	assert.Equal(t, "new", merged.APISecret)
+	assert.Equal(t, 1.0, merged.Access.IPRate)
+	assert.False(t, merged.Filter.Includes("memory"))
+}
+
+// TestConfigCollect tests the collectors and default filter, which are validated like the query parameters
+func TestConfigCollect(t *testing.T) {
+	path := writeConfig(t, "syscapture.yaml", `
+auth:
+  api_secret: secret
+collectors: [cpu, disk]
+filter:
+  fields: [cpu.usage_percent, disk]
+  device: [/dev/sda1]
+`)
+	cfg, err := config.Load(path, envOf(nil))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cpu", "disk"}, cfg.Collectors)
+	assert.True(t, cfg.Filter.Includes("cpu"))
+	assert.False(t, cfg.Filter.Includes("memory"))
+	assert.True(t, cfg.Filter.IncludesDevice("/dev/sda1"))
+	assert.False(t, cfg.Filter.IncludesDevice("/dev/sdb1"))
+
+	// The default fields of the file must be collected by the collectors of the environment
+	_, err = config.Load(path, envOf(map[string]string{"COLLECTORS": "memory"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "filter.fields")
+	assert.Contains(t, err.Error(), "COLLECTORS")
+
+	// Without default fields every field of the collectors is served
+	cfg, err = config.Load("", envOf(map[string]string{"API_SECRET": "secret", "COLLECTORS": "memory,host"}))
+	require.NoError(t, err)
+	assert.True(t, cfg.Filter.Includes("host"))
+	assert.False(t, cfg.Filter.Includes("cpu"))
+
+	cfg, err = config.Load("", envOf(map[string]string{"API_SECRET": "secret"}))
+	require.NoError(t, err)
+	assert.Equal(t, metric.Filter{}, cfg.Filter)
+
+	for env, message := range map[string]string{
+		"COLLECTORS=gpu":                                 "COLLECTORS",
+		"FILTER_FIELDS=cpu.unknown":                      "FILTER_FIELDS",
+		"COLLECTORS=cpu;FILTER_FIELDS=memory.used_bytes": "not collected",
+		"COLLECTORS=cpu;FILTER_DEVICE=/dev/sda1":         "FILTER_DEVICE",
+	} {
+		vars := map[string]string{"API_SECRET": "secret"}
+		for _, assignment := range strings.Split(env, ";") {
+			name, value, _ := strings.Cut(assignment, "=")
+			vars[name] = value
+		}
+		_, err := config.Load("", envOf(vars))
+		require.Error(t, err, env)
+		assert.Contains(t, err.Error(), message, env)
+	}
+}
+
+// TestConfigSecrets tests reading secrets from files and systemd credentials
+func TestConfigSecrets(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "api_secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("file-secret\n"), 0o600))
+
+	cfg, err := config.Load("", envOf(map[string]string{"API_SECRET_FILE": secretFile}))
+	require.NoError(t, err)
+	assert.Equal(t, "file-secret", cfg.APISecret)
+
+	// systemd credentials are named after the variable
+	credentials := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(credentials, "HMAC_KEYS"), []byte("panel=panel-key-0123456789abcdef0123456789"), 0o400))
+	cfg, err = config.Load("", envOf(map[string]string{"CREDENTIALS_DIRECTORY": credentials}))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"panel": "panel-key-0123456789abcdef0123456789"}, cfg.HMAC.Keys)
+
+	// The configuration file can point to a secret file too
+	cfg, err = config.Load(writeConfig(t, "syscapture.yaml", "auth:\n  api_secret_file: "+secretFile+"\n"), envOf(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "file-secret", cfg.APISecret)
+
+	_, err = config.Load("", envOf(map[string]string{"API_SECRET": "a", "API_SECRET_FILE": secretFile}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "set only one of API_SECRET and API_SECRET_FILE")
+
+	// Files other users can read are refused
+	require.NoError(t, os.Chmod(secretFile, 0o640))
+	_, err = config.Load("", envOf(map[string]string{"API_SECRET_FILE": secretFile}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "accessible by group or other users")
+
+	configFile := writeConfig(t, "syscapture.yaml", "auth:\n  api_secret: inline-secret\n")
+	require.NoError(t, os.Chmod(configFile, 0o644))
+	_, err = config.Load(configFile, envOf(nil))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "config file holds auth.api_secret")
+}
+
+// TestConfigGRPCListen tests the gRPC listen addresses, which must not clash with the others
+func TestConfigGRPCListen(t *testing.T) {
+	env := map[string]string{"API_SECRET": "secret"}
+	cfg, err := config.Load("", envOf(env))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Listen.GRPCAddresses)
+
+	cfg, err = config.Load(writeConfig(t, "syscapture.yaml", "grpc_listen: [\"127.0.0.1:59233\", \"unix:/run/syscapture/grpc.sock\"]\n"), envOf(env))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:59233", "unix:/run/syscapture/grpc.sock"}, cfg.Listen.GRPCAddresses)
+
+	_, err = config.Load("", envOf(map[string]string{"API_SECRET": "secret", "LISTEN": "127.0.0.1:59232", "GRPC_LISTEN": "127.0.0.1:59232"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "GRPC_LISTEN")
+}
+
+// TestConfigAccessDefaults tests that the per-IP rate limit and the bans are off until configured
+func TestConfigAccessDefaults(t *testing.T) {
+	cfg, err := config.Load("", envOf(map[string]string{"API_SECRET": "secret"}))
+	require.NoError(t, err)
+	assert.Zero(t, cfg.Access.IPRate)
+	assert.Zero(t, cfg.Access.BanThreshold)
+
+	cfg, err = config.Load("", envOf(map[string]string{
+		"API_SECRET":         "secret",
+		"TRUSTED_PROXIES":    "127.0.0.1",
+		"RATE_LIMIT_IP":      "20",
+		"AUTH_BAN_THRESHOLD": "10",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, cfg.Access.IPRate)
+	assert.Equal(t, 10, cfg.Access.BanThreshold)
+	assert.Equal(t, 10*time.Minute, cfg.Access.BanWindow)
+}
+
+// TestConfigTLSKeyMode tests that TLS keys readable by their group are accepted, as in the
+// Debian /etc/ssl/private layout, while keys readable by other users are refused
+func TestConfigTLSKeyMode(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(keyFile, []byte("key"), 0o640))
+	env := map[string]string{"API_SECRET": "secret", "TLS_CERT_FILE": "cert.pem", "TLS_KEY_FILE": keyFile}
+
+	_, err := config.Load("", envOf(env))
+	require.NoError(t, err)
+
+	require.NoError(t, os.Chmod(keyFile, 0o644))
+	_, err = config.Load("", envOf(env))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "accessible by other users")
+}
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/emit"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEmitTemplate tests the metric paths built from templates
+func TestEmitTemplate(t *testing.T) {
+	host := emit.Host{Name: "node1.example.com", Platform: "ubuntu", OS: "linux"}
+	disk := metric.Sample{Name: "disk.free_bytes", Labels: map[string]string{"device": "/dev/nvme0n1p1"}, Value: 1}
+	cpu := metric.Sample{Name: "cpu.usage_percent", Value: 1}
+
+	template, err := emit.ParseTemplate(emit.DefaultTemplate)
+	require.NoError(t, err)
+	assert.Equal(t, "syscapture.node1_example_com.cpu.usage_percent", template.Path(cpu, host))
+	assert.Equal(t, "syscapture.node1_example_com.disk.free_bytes.dev_nvme0n1p1", template.Path(disk, host))
+
+	template, err = emit.ParseTemplate("servers.{platform}.{group}.{device}.{field}")
+	require.NoError(t, err)
+	assert.Equal(t, "servers.ubuntu.cpu.usage_percent", template.Path(cpu, host))
+	assert.Equal(t, "servers.ubuntu.disk.dev_nvme0n1p1.free_bytes", template.Path(disk, host))
+
+	for _, invalid := range []string{"servers.{host}", "servers.{unknown}.{metric}", "servers.{metric", "servers {metric}", "servers:{metric}"} {
+		_, err := emit.ParseTemplate(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// TestStatsD tests that every sample is sent as a gauge
+func TestStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	statsd, err := emit.NewStatsD(emit.StatsDOptions{
+		Address:       conn.LocalAddr().String(),
+		Template:      "servers.{platform}.{metric}",
+		MaxPacketSize: 64,
+	}, func(err error) {
+		t.Error(err)
+	})
+	require.NoError(t, err)
+	defer statsd.Close()
+
+	snapshot := snapshotAt(time.Now(), 12.5)
+	snapshot.Metrics.Host.Platform = "ubuntu"
+	statsd.Consume(snapshot)
+
+	var lines []string
+	buf := make([]byte, 1500)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for len(lines) < len(snapshot.Metrics.Samples()) {
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, n, 64)
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+
+	assert.Contains(t, lines, "servers.ubuntu.cpu.usage_percent:12.5|g")
+	assert.Contains(t, lines, "servers.ubuntu.disk.free_bytes.dev_sda1:1024|g")
+}
+
+// TestGraphite tests that lines are buffered while Carbon is down and sent after reconnecting
+func TestGraphite(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+
+	graphite, err := emit.NewGraphite(emit.GraphiteOptions{Address: address}, func(error) {})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// readLines accepts a connection and reads n lines from it
+	readLines := func(l net.Listener, n int) (net.Conn, []string) {
+		conn, err := l.Accept()
+		require.NoError(t, err)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		reader := bufio.NewReader(conn)
+		var lines []string
+		for len(lines) < n {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+		sort.Strings(lines)
+		return conn, lines
+	}
+
+	ts := time.Unix(1700000000, 0)
+	graphite.Consume(snapshotAt(ts, 10))
+	samples := graphite.Buffered()
+	require.NoError(t, graphite.Flush(ctx))
+	conn, lines := readLines(listener, samples)
+	assert.Contains(t, lines[0], "syscapture.")
+	assert.True(t, strings.HasSuffix(lines[0], " 1700000000"))
+	assert.Equal(t, 0, graphite.Buffered())
+
+	// Carbon goes away: the lines stay buffered
+	conn.Close()
+	listener.Close()
+	time.Sleep(50 * time.Millisecond)
+	graphite.Consume(snapshotAt(ts.Add(10*time.Second), 20))
+	assert.Error(t, graphite.Flush(ctx))
+	assert.Equal(t, samples, graphite.Buffered())
+
+	// And comes back on the same address
+	listener, err = net.Listen("tcp", address)
+	require.NoError(t, err)
+	defer listener.Close()
+	require.NoError(t, graphite.Flush(ctx))
+	conn, lines = readLines(listener, samples)
+	defer conn.Close()
+	for _, line := range lines {
+		assert.True(t, strings.HasSuffix(line, " 1700000010"), line)
+	}
+	assert.Contains(t, strings.Join(lines, "\n"), ".cpu.usage_percent 20 ")
+}
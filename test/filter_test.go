@@ -0,0 +1,246 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/handler"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// filterMetrics returns metrics of two disks for the filter tests
+func filterMetrics() metric.AllMetrics {
+	free, other := uint64(1024), uint64(2048)
+	usage := 0.5
+	return metric.AllMetrics{
+		CPU:    metric.CPUData{LogicalCore: 4, UsagePercent: 0.125},
+		Memory: metric.MemoryData{TotalBytes: 4096, UsedBytes: 1024, UsagePercent: &usage},
+		Disk: metric.MetricsSlice{
+			&metric.DiskData{Device: "/dev/nvme0n1p1", FreeBytes: &free, UsagePercent: &usage},
+			&metric.DiskData{Device: "/dev/sda1", FreeBytes: &other},
+		},
+		Host: metric.HostData{Os: "linux", Platform: "ubuntu"},
+	}
+}
+
+// toJSON returns the JSON encoding of v decoded into a generic value
+func toJSON(t *testing.T, v any) any {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	var decoded any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	return decoded
+}
+
+// TestFilterParse tests the parsing of the fields and device parameters
+func TestFilterParse(t *testing.T) {
+	tests := []struct {
+		group   string
+		fields  []string
+		devices []string
+		valid   bool
+	}{
+		{"", nil, nil, true},
+		{"", []string{"cpu.usage_percent,memory.used_bytes"}, nil, true},
+		{"", []string{"memory", "disk.free_bytes"}, []string{"/dev/sda1"}, true},
+		{"cpu", []string{"usage_percent,cpu.logical_core"}, nil, true},
+		{"disk", nil, []string{"/dev/sda1,/dev/sdb1"}, true},
+		{"", []string{"cpu.unknown"}, nil, false},
+		{"", []string{"network"}, nil, false},
+		{"cpu", []string{"memory.used_bytes"}, nil, false},
+		{"cpu", nil, []string{"/dev/sda1"}, false},
+	}
+
+	for _, tt := range tests {
+		_, err := metric.ParseFilter(tt.group, tt.fields, tt.devices)
+		if tt.valid {
+			assert.NoError(t, err, "%s %v %v", tt.group, tt.fields, tt.devices)
+		} else {
+			assert.Error(t, err, "%s %v %v", tt.group, tt.fields, tt.devices)
+		}
+	}
+}
+
+// TestFilterSelect tests that only the requested fields and disks are returned
+func TestFilterSelect(t *testing.T) {
+	m := filterMetrics()
+
+	filter, err := metric.ParseFilter("", []string{"cpu.usage_percent,memory.used_bytes,disk.free_bytes"}, []string{"/dev/sda1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"cpu":    map[string]any{"usage_percent": 0.125},
+		"memory": map[string]any{"used_bytes": float64(1024)},
+		"disk":   []any{map[string]any{"device": "/dev/sda1", "free_bytes": float64(2048)}},
+	}, toJSON(t, filter.Select(m)))
+
+	filter, err = metric.ParseFilter("memory", []string{"used_bytes"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"used_bytes": float64(1024)}, toJSON(t, filter.Select(m)))
+
+	// Without fields, the collected types are returned unchanged
+	filter, err = metric.ParseFilter("", nil, []string{"/dev/nvme0n1p1"})
+	require.NoError(t, err)
+	selected, ok := filter.Select(m).(metric.AllMetrics)
+	require.True(t, ok)
+	require.Len(t, selected.Disk, 1)
+	assert.Equal(t, "/dev/nvme0n1p1", selected.Disk[0].(*metric.DiskData).Device)
+	assert.Equal(t, 4, selected.CPU.LogicalCore)
+
+	var names []string
+	for _, s := range filter.Samples(m) {
+		if device, ok := s.Labels["device"]; ok {
+			assert.Equal(t, "/dev/nvme0n1p1", device)
+		}
+		names = append(names, s.Name)
+	}
+	assert.Contains(t, names, "cpu.usage_percent")
+}
+
+// TestFilterErrors tests that only errors about the requested fields are kept
+func TestFilterErrors(t *testing.T) {
+	errs := []metric.CustomErr{
+		{Metric: []string{"cpu.temperature"}, Error: "no sensors"},
+		{Metric: []string{"disk.partitions"}, Error: "permission denied"},
+	}
+
+	filter, err := metric.ParseFilter("", []string{"cpu.usage_percent"}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, filter.Errors(errs))
+
+	filter, err = metric.ParseFilter("", []string{"cpu.temperature,disk.free_bytes"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, errs, filter.Errors(errs))
+}
+
+// TestFilterHandler tests the filtered routes, which must not sample the CPU usage for memory only
+func TestFilterHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/metrics", func(c *gin.Context) {
+		handler.Metrics(c, nil, metric.Filter{})
+	})
+	r.GET("/metrics/cpu", func(c *gin.Context) {
+		handler.MetricsCPU(c, metric.Filter{})
+	})
+	r.GET("/metrics/memory", func(c *gin.Context) {
+		handler.MetricsMemory(c, metric.Filter{})
+	})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics?fields=memory.used_bytes,memory.total_bytes", nil))
+	assert.Less(t, time.Since(start), 900*time.Millisecond)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data map[string]map[string]any `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Data, 1)
+	assert.Len(t, response.Data["memory"], 2)
+	assert.Contains(t, response.Data["memory"], "used_bytes")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/memory?fields=used_bytes", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `["used_bytes"]`, keysOf(t, w.Body.Bytes()))
+
+	for _, path := range []string{"/metrics?fields=cpu.unknown", "/metrics/cpu?device=/dev/sda1", "/metrics/memory?fields=cpu.usage_percent"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics?fields=disk&device=/dev/does-not-exist", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestSnapshotSamples tests that snapshots only export the samples of the collected groups
+func TestSnapshotSamples(t *testing.T) {
+	filter, err := metric.ParseFilter("", []string{"memory.used_bytes"}, nil)
+	require.NoError(t, err)
+
+	snapshot := collector.Snapshot{
+		Metrics: metric.AllMetrics{
+			Memory:  metric.MemoryData{TotalBytes: 2048, UsedBytes: 1024},
+			Derived: []metric.Sample{{Name: "anomaly.z_score", Value: 4}},
+		},
+		Filter: filter,
+	}
+	var names []string
+	for _, s := range snapshot.Samples() {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"memory.used_bytes", "anomaly.z_score"}, names)
+}
+
+// TestFilterDefaults tests that the default filter applies to requests selecting no fields
+func TestFilterDefaults(t *testing.T) {
+	defaults, err := metric.ParseFilter("", []string{"memory.used_bytes", "host"}, nil)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/metrics", func(c *gin.Context) {
+		handler.Metrics(c, nil, defaults)
+	})
+	r.GET("/metrics/memory", func(c *gin.Context) {
+		handler.MetricsMemory(c, defaults)
+	})
+	r.GET("/metrics/cpu", func(c *gin.Context) {
+		handler.MetricsCPU(c, defaults)
+	})
+
+	tests := []struct {
+		path string
+		keys string
+	}{
+		{"/metrics", `["memory","host"]`},
+		{"/metrics?fields=cpu.logical_core", `["cpu"]`},
+		{"/metrics/memory", `["used_bytes"]`},
+		{"/metrics/memory?fields=total_bytes", `["total_bytes"]`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		require.Contains(t, []int{http.StatusOK, http.StatusMultiStatus}, w.Code, tt.path)
+		assert.ElementsMatch(t, jsonArray(t, tt.keys), jsonArray(t, keysOf(t, w.Body.Bytes())), tt.path)
+	}
+
+	// Groups without default fields are served whole on their route
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/cpu", nil))
+	assert.Contains(t, keysOf(t, w.Body.Bytes()), "logical_core")
+}
+
+// jsonArray decodes a JSON array of strings
+func jsonArray(t *testing.T, s string) []string {
+	t.Helper()
+	var values []string
+	require.NoError(t, json.Unmarshal([]byte(s), &values))
+	return values
+}
+
+// keysOf returns the keys of the data object of a response as a JSON array
+func keysOf(t *testing.T, body []byte) string {
+	t.Helper()
+	var response struct {
+		Data map[string]any `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(body, &response))
+	var keys []string
+	for k := range response.Data {
+		keys = append(keys, k)
+	}
+	data, _ := json.Marshal(keys)
+	return string(data)
+}
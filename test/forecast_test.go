@@ -0,0 +1,84 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/forecast"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestForecastFreeBytes tests the disk-full prediction on a disk losing 1 GB per day
+func TestForecastFreeBytes(t *testing.T) {
+	now := time.Now()
+	start := now.Add(-48 * time.Hour)
+
+	// 100 GB free two days ago, losing 1 GB per day: 98 GB free now, full in 98 days
+	var points []metric.Point
+	for i := 0; i <= 48*6; i++ {
+		ts := start.Add(time.Duration(i) * 10 * time.Minute)
+		days := ts.Sub(start).Hours() / 24
+		points = append(points, metric.Point{Timestamp: ts, Value: 100e9 - days*1e9})
+	}
+
+	result := forecast.FreeBytes(points, now)
+	require.NotNil(t, result)
+	require.NotNil(t, result.DaysUntilFull)
+	require.NotNil(t, result.PredictedFullAt)
+	assert.InDelta(t, 98, *result.DaysUntilFull, 0.01)
+	assert.InDelta(t, 1e9, result.GrowthBytesPerDay, 1)
+	assert.Equal(t, forecast.ConfidenceHigh, result.Confidence)
+	assert.WithinDuration(t, now.Add(98*24*time.Hour), *result.PredictedFullAt, time.Minute)
+}
+
+// TestForecastNotFilling tests that a disk with growing free space has no predicted full time
+func TestForecastNotFilling(t *testing.T) {
+	now := time.Now()
+
+	var points []metric.Point
+	for i := 0; i < 20; i++ {
+		points = append(points, metric.Point{
+			Timestamp: now.Add(time.Duration(i-20) * time.Hour),
+			Value:     float64(1000 + i),
+		})
+	}
+
+	result := forecast.FreeBytes(points, now)
+	require.NotNil(t, result)
+	assert.Nil(t, result.DaysUntilFull)
+	assert.Nil(t, result.PredictedFullAt)
+
+	// Not enough history for a forecast
+	assert.Nil(t, forecast.FreeBytes(points[:5], now))
+}
+
+// TestForecastMinuteHistory tests that a forecast is made from the minute tier when
+// there is less history than the coarsest tier needs
+func TestForecastMinuteHistory(t *testing.T) {
+	store, err := storage.Open(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+
+	// 12 hours of one sample per minute, losing 1 GB per day
+	now := time.Now()
+	start := now.Add(-12 * time.Hour)
+	for i := 0; i <= 12*60; i++ {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		days := ts.Sub(start).Hours() / 24
+		require.NoError(t, store.Append(ts, []metric.Sample{
+			{Name: "disk.free_bytes", Labels: map[string]string{"device": "/dev/sda1"}, Value: 100e9 - days*1e9},
+		}))
+	}
+	assert.Equal(t, "1m", store.ResolutionFor(forecast.DefaultWindow))
+
+	result, err := forecast.NewDiskForecaster(store, forecast.DefaultWindow).Disk("/dev/sda1", now)
+	require.NoError(t, err)
+	require.NotNil(t, result, "minute history is enough for a forecast")
+	require.NotNil(t, result.DaysUntilFull)
+	assert.Greater(t, result.Samples, 600)
+	assert.InDelta(t, 99.5, *result.DaysUntilFull, 0.1)
+	assert.Equal(t, forecast.ConfidenceMedium, result.Confidence)
+}
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/middleware"
+	"github.com/nodebytehosting/syscapture/internal/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHMACSignedRequests tests signature verification, clock skew and nonce replay protection
+func TestHMACSignedRequests(t *testing.T) {
+	const key = "0123456789abcdef0123456789abcdef"
+
+	store, err := token.Open("")
+	require.NoError(t, err)
+	verifier := middleware.NewHMACVerifier(time.Minute)
+	verifier.AddKey("node1", key, []string{token.ScopeMetricsRead})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.AuthRequired(store, verifier))
+	r.GET("/metrics", middleware.RequireScope(token.ScopeMetricsRead), func(c *gin.Context) {
+		c.String(http.StatusOK, middleware.CurrentToken(c).Name)
+	})
+	r.GET("/tokens", middleware.RequireScope(token.ScopeAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(path string, secret string, timestamp time.Time, nonce string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		middleware.SignRequest(req, "node1", secret, timestamp, nonce)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	w := do("/metrics?interval=5", key, now, "nonce-0000000001")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hmac:node1", w.Body.String())
+
+	// The same nonce is rejected within the window
+	w = do("/metrics?interval=5", key, now, "nonce-0000000001")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Nonce has already been used")
+
+	// A wrong key or a tampered path does not match the signature
+	w = do("/metrics", "ffffffffffffffffffffffffffffffff", now, "nonce-0000000002")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid signature provided")
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	middleware.SignRequest(req, "node1", key, now, "nonce-0000000003")
+	req.URL.RawQuery = "interval=1"
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Timestamps outside the allowed skew are rejected
+	w = do("/metrics", key, now.Add(-2*time.Minute), "nonce-0000000004")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "outside the allowed window")
+	w = do("/metrics", key, now.Add(2*time.Minute), "nonce-0000000005")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Malformed credentials and scopes the key does not grant
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "HMAC-SHA256 node1:abc")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do("/tokens", key, now, "nonce-0000000006")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
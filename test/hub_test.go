@@ -0,0 +1,370 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/config"
+	"github.com/nodebytehosting/syscapture/internal/handler"
+	"github.com/nodebytehosting/syscapture/internal/hub"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nodeMetrics returns metrics with the given CPU, memory and disk usage
+func nodeMetrics(cpu float64, memory float64, disks map[string]float64) metric.AllMetrics {
+	m := metric.AllMetrics{
+		CPU:    metric.CPUData{UsagePercent: cpu},
+		Memory: metric.MemoryData{TotalBytes: 1000, UsedBytes: uint64(memory * 1000), UsagePercent: &memory},
+		Host:   metric.HostData{Os: "linux"},
+	}
+	for device, usage := range disks {
+		total, free := uint64(100), uint64((1-usage)*100)
+		m.Disk = append(m.Disk, &metric.DiskData{Device: device, TotalBytes: &total, FreeBytes: &free, UsagePercent: &usage})
+	}
+	return m
+}
+
+// TestHubPoll tests that agents are polled with the token and marked unreachable when failing
+func TestHubPoll(t *testing.T) {
+	var authorization string
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		assert.Equal(t, hub.MetricsPath, r.URL.Path)
+		w.WriteHeader(http.StatusMultiStatus)
+		json.NewEncoder(w).Encode(metric.APIResponse{
+			Data:   nodeMetrics(0.25, 0.5, map[string]float64{"/dev/sda1": 0.7}),
+			Errors: []metric.CustomErr{{Metric: []string{"cpu.temperature"}, Error: "unavailable"}},
+		})
+	}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusUnauthorized)
+	}))
+	defer failing.Close()
+
+	h := hub.New(hub.Options{
+		Agents: map[string]string{"web-1": healthy.URL + "/", "web-2": failing.URL, "web-3": "http://127.0.0.1:1"},
+		Token:  "agent-secret",
+	})
+	h.PollAll(context.Background())
+	assert.Equal(t, "Bearer agent-secret", authorization)
+
+	nodes := h.Nodes()
+	require.Len(t, nodes, 3)
+	assert.Equal(t, "web-1", nodes[0].Name)
+	assert.True(t, nodes[0].Reachable)
+	assert.False(t, nodes[0].Stale)
+	assert.NotNil(t, nodes[0].LastSeen)
+	assert.Nil(t, nodes[0].Metrics, "node lists leave the metrics out")
+	assert.False(t, nodes[1].Reachable)
+	assert.True(t, nodes[1].Stale)
+	assert.Contains(t, nodes[1].LastError, "401")
+	assert.False(t, nodes[2].Reachable)
+	assert.NotEmpty(t, nodes[2].LastError)
+
+	node, ok := h.Node("web-1")
+	require.True(t, ok)
+	require.NotNil(t, node.Metrics)
+	assert.Equal(t, 0.25, node.Metrics.CPU.UsagePercent)
+	require.Len(t, node.Metrics.Disk, 1)
+	assert.Equal(t, "/dev/sda1", node.Metrics.Disk[0].(*metric.DiskData).Device)
+	assert.Len(t, node.Errors, 1)
+
+	_, ok = h.Node("web-4")
+	assert.False(t, ok)
+}
+
+// TestHubStale tests that nodes that stop reporting are marked stale and left out of the aggregates
+func TestHubStale(t *testing.T) {
+	h := hub.New(hub.Options{Interval: 10 * time.Millisecond, StaleAfter: 50 * time.Millisecond})
+	require.NoError(t, h.Push("db-1", nodeMetrics(0.9, 0.1, nil), nil))
+	require.NoError(t, h.Push("db-2", nodeMetrics(0.1, 0.1, nil), nil))
+
+	node, _ := h.Node("db-1")
+	assert.True(t, node.Reachable)
+	assert.False(t, node.Stale)
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, h.Push("db-2", nodeMetrics(0.1, 0.1, nil), nil))
+
+	node, _ = h.Node("db-1")
+	assert.False(t, node.Reachable)
+	assert.True(t, node.Stale)
+	assert.NotNil(t, node.Metrics, "the last metrics are kept")
+
+	top, err := h.Top(hub.ByCPU, 10)
+	require.NoError(t, err)
+	require.Len(t, top, 1)
+	assert.Equal(t, "db-2", top[0].Name)
+
+	summary := h.Summary()
+	assert.Equal(t, 2, summary.Nodes)
+	assert.Equal(t, 1, summary.Reachable)
+	assert.Equal(t, 1, summary.Stale)
+
+	assert.ErrorIs(t, h.Push("db 3", nodeMetrics(0, 0, nil), nil), hub.ErrInvalidName)
+	polled := hub.New(hub.Options{Agents: map[string]string{"web-1": "http://127.0.0.1:1"}})
+	assert.ErrorIs(t, polled.Push("web-1", nodeMetrics(0, 0, nil), nil), hub.ErrPolled)
+}
+
+// TestHubAggregates tests the top rankings and the fleet summary
+func TestHubAggregates(t *testing.T) {
+	h := hub.New(hub.Options{})
+	require.NoError(t, h.Push("a", nodeMetrics(0.2, 0.8, map[string]float64{"/dev/sda1": 0.3, "/dev/sdb1": 0.95}), nil))
+	require.NoError(t, h.Push("b", nodeMetrics(0.6, 0.4, map[string]float64{"/dev/sda1": 0.5}), nil))
+	require.NoError(t, h.Push("c", nodeMetrics(0.4, 0.6, nil), nil))
+
+	top, err := h.Top(hub.ByCPU, 2)
+	require.NoError(t, err)
+	assert.Equal(t, metric.NodeRanking{{Name: "b", Value: 0.6}, {Name: "c", Value: 0.4}}, top)
+
+	top, err = h.Top(hub.ByMemory, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c", "b"}, []string{top[0].Name, top[1].Name, top[2].Name})
+
+	top, err = h.Top(hub.ByDisk, 10)
+	require.NoError(t, err)
+	assert.Equal(t, metric.NodeRanking{{Name: "a", Value: 0.95, Device: "/dev/sdb1"}, {Name: "b", Value: 0.5, Device: "/dev/sda1"}}, top)
+
+	_, err = h.Top("network", 10)
+	assert.ErrorIs(t, err, hub.ErrUnknownRanking)
+
+	summary := h.Summary()
+	assert.Equal(t, 3, summary.Nodes)
+	assert.Equal(t, 3, summary.Reachable)
+	assert.Zero(t, summary.Stale)
+	require.NotNil(t, summary.CPUUsagePercent)
+	assert.InDelta(t, 0.4, *summary.CPUUsagePercent, 1e-9)
+	assert.Equal(t, uint64(3000), summary.MemoryTotalBytes)
+	assert.Equal(t, uint64(1800), summary.MemoryUsedBytes)
+	assert.Equal(t, uint64(300), summary.DiskTotalBytes)
+	assert.Equal(t, uint64(125), summary.DiskFreeBytes)
+}
+
+// TestHubHandlers tests the node and fleet routes
+func TestHubHandlers(t *testing.T) {
+	h := hub.New(hub.Options{Agents: map[string]string{"web-1": "http://127.0.0.1:1"}})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/nodes", func(c *gin.Context) { handler.ListNodes(c, h) })
+	r.GET("/nodes/:name", func(c *gin.Context) { handler.GetNode(c, h) })
+	r.GET("/nodes/:name/metrics", func(c *gin.Context) { handler.NodeMetrics(c, h) })
+	r.POST("/nodes/:name/metrics", func(c *gin.Context) { handler.PushNodeMetrics(c, h) })
+	r.GET("/fleet/top", func(c *gin.Context) { handler.FleetTop(c, h) })
+	r.GET("/fleet/summary", func(c *gin.Context) { handler.FleetSummary(c, h) })
+
+	request := func(method string, path string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewReader(body)))
+		return w
+	}
+
+	push, err := json.Marshal(metric.APIResponse{Data: nodeMetrics(0.5, 0.5, map[string]float64{"/dev/sda1": 0.5})})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/nodes/db-1/metrics", push).Code)
+	assert.Equal(t, http.StatusConflict, request(http.MethodPost, "/nodes/web-1/metrics", push).Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/nodes/db-2/metrics", []byte("{")).Code)
+
+	w := request(http.MethodGet, "/nodes", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var nodes struct {
+		Data []metric.Node `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &nodes))
+	require.Len(t, nodes.Data, 2)
+	assert.Equal(t, "db-1", nodes.Data[0].Name)
+	assert.True(t, nodes.Data[0].Reachable)
+	assert.True(t, nodes.Data[1].Stale)
+
+	w = request(http.MethodGet, "/nodes/db-1/metrics", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var metrics struct {
+		Data metric.AllMetrics `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	assert.Equal(t, 0.5, metrics.Data.CPU.UsagePercent)
+
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/nodes/db-1", nil).Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/nodes/db-9", nil).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, request(http.MethodGet, "/nodes/web-1/metrics", nil).Code)
+
+	w = request(http.MethodGet, "/fleet/top?by=disk", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"device":"/dev/sda1"`)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "/fleet/top?by=network", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "/fleet/top?limit=0", nil).Code)
+
+	w = request(http.MethodGet, "/fleet/summary", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"nodes":2`)
+}
+
+// TestConfigHub tests the hub settings
+func TestConfigHub(t *testing.T) {
+	env := map[string]string{"API_SECRET": "secret"}
+	cfg, err := config.Load("", envOf(env))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Hub.Agents)
+	assert.Equal(t, hub.DefaultInterval, cfg.Hub.PollInterval)
+	assert.Equal(t, 3*hub.DefaultInterval, cfg.Hub.StaleAfter)
+
+	path := writeConfig(t, "syscapture.yaml", `hub:
+  agents:
+    web-1: https://web-1.example.com:42000
+    web-2: http://10.0.0.2:42000
+  agent_token: agent-secret
+  poll_interval: 15s
+`)
+	cfg, err = config.Load(path, envOf(env))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"web-1": "https://web-1.example.com:42000", "web-2": "http://10.0.0.2:42000"}, cfg.Hub.Agents)
+	assert.Equal(t, "agent-secret", cfg.Hub.AgentToken)
+	assert.Equal(t, 15*time.Second, cfg.Hub.Timeout)
+	assert.Equal(t, 45*time.Second, cfg.Hub.StaleAfter)
+
+	_, err = config.Load("", envOf(map[string]string{
+		"API_SECRET":        "secret",
+		"HUB_AGENTS":        "web 1=http://10.0.0.1:42000;web-2=10.0.0.2",
+		"HUB_POLL_INTERVAL": "1m",
+		"HUB_STALE_AFTER":   "30s",
+	}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"web 1"`)
+	assert.Contains(t, err.Error(), "HUB_AGENTS web-2")
+	assert.Contains(t, err.Error(), "HUB_STALE_AFTER")
+}
+
+// TestHubRegistration tests that agents register, send heartbeats and register again after a hub restart
+func TestHubRegistration(t *testing.T) {
+	current := hub.New(hub.Options{})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/v1/nodes/:name/register", func(c *gin.Context) { handler.RegisterNode(c, current) })
+	r.POST("/api/v1/nodes/:name/heartbeat", func(c *gin.Context) { handler.NodeHeartbeat(c, current) })
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	cpu := 0.3
+	agent := hub.NewAgent(hub.AgentOptions{
+		HubURL:   server.URL + "/",
+		Name:     "web-1",
+		Interval: time.Minute,
+		Registration: func() hub.Registration {
+			return hub.Registration{
+				Version:      "1.2.3",
+				Host:         metric.HostData{Os: "linux", Platform: "debian"},
+				Address:      "https://web-1:42000",
+				Listen:       []string{":42000"},
+				Capabilities: []string{"metrics", "history"},
+			}
+		},
+		Summary: func() (metric.NodeSummary, bool) {
+			return metric.Summarize(nodeMetrics(cpu, 0.5, map[string]float64{"/dev/sda1": 0.8}), nil), true
+		},
+	})
+	require.NoError(t, agent.Report(context.Background()))
+
+	node, ok := current.Node("web-1")
+	require.True(t, ok)
+	assert.Equal(t, metric.NodeOnline, node.Status)
+	assert.Equal(t, "1.2.3", node.Version)
+	assert.Equal(t, "debian", node.Host.Platform)
+	assert.Equal(t, "https://web-1:42000", node.Address)
+	assert.Equal(t, []string{"metrics", "history"}, node.Capabilities)
+	assert.NotNil(t, node.RegisteredAt)
+	require.NotNil(t, node.Summary)
+	assert.Equal(t, 0.3, node.Summary.CPUUsagePercent)
+	assert.Equal(t, "/dev/sda1", node.Summary.DiskDevice)
+	assert.Nil(t, node.Metrics)
+
+	// Heartbeat-only nodes are ranked like polled ones
+	top, err := current.Top(hub.ByDisk, 10)
+	require.NoError(t, err)
+	assert.Equal(t, metric.NodeRanking{{Name: "web-1", Value: 0.8, Device: "/dev/sda1"}}, top)
+
+	// A restarted hub forgets the node, which registers again
+	current = hub.New(hub.Options{})
+	cpu = 0.6
+	require.NoError(t, agent.Report(context.Background()))
+	node, ok = current.Node("web-1")
+	require.True(t, ok)
+	assert.Equal(t, "1.2.3", node.Version)
+	assert.Equal(t, 0.6, node.Summary.CPUUsagePercent)
+
+	// Registration failures are reported and retried
+	server.Close()
+	assert.Error(t, hub.NewAgent(hub.AgentOptions{
+		HubURL:       server.URL,
+		Name:         "web-2",
+		Registration: func() hub.Registration { return hub.Registration{} },
+		Summary:      func() (metric.NodeSummary, bool) { return metric.NodeSummary{}, false },
+	}).Report(context.Background()))
+}
+
+// TestHubInventory tests the offline status of registered nodes
+func TestHubInventory(t *testing.T) {
+	h := hub.New(hub.Options{StaleAfter: 50 * time.Millisecond, Agents: map[string]string{"web-1": "http://127.0.0.1:1"}})
+
+	assert.ErrorIs(t, h.Heartbeat("db-1", hub.Heartbeat{}), hub.ErrNotRegistered)
+	_, err := h.Register("web-1", hub.Registration{})
+	assert.ErrorIs(t, err, hub.ErrPolled)
+	_, err = h.Register("db/1", hub.Registration{})
+	assert.ErrorIs(t, err, hub.ErrInvalidName)
+
+	node, err := h.Register("db-1", hub.Registration{Version: "1.2.3"})
+	require.NoError(t, err)
+	assert.Equal(t, metric.NodeOnline, node.Status)
+	assert.NotNil(t, node.LastSeen)
+	require.NoError(t, h.Heartbeat("db-1", hub.Heartbeat{Summary: metric.NodeSummary{CPUUsagePercent: 0.5}}))
+
+	time.Sleep(100 * time.Millisecond)
+	node, _ = h.Node("db-1")
+	assert.Equal(t, metric.NodeOffline, node.Status)
+	assert.True(t, node.Stale)
+	assert.Equal(t, "1.2.3", node.Version, "offline nodes stay in the inventory")
+
+	// Nodes announcing their heartbeat interval go offline after three missed heartbeats
+	_, err = h.Register("db-2", hub.Registration{HeartbeatSeconds: 60})
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	node, _ = h.Node("db-2")
+	assert.Equal(t, metric.NodeOnline, node.Status)
+
+	node, _ = h.Node("web-1")
+	assert.Equal(t, metric.NodeOffline, node.Status)
+}
+
+// TestConfigHubRegistration tests the settings of the registration with a hub
+func TestConfigHubRegistration(t *testing.T) {
+	cfg, err := config.Load("", envOf(map[string]string{"API_SECRET": "secret"}))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Hub.URL)
+	assert.Equal(t, hub.DefaultHeartbeatInterval, cfg.Hub.HeartbeatInterval)
+
+	cfg, err = config.Load(writeConfig(t, "syscapture.toml", `[hub]
+url = "https://hub.example.com:42000"
+token = "push-secret"
+node_name = "web-1"
+heartbeat_interval = "10s"
+`), envOf(map[string]string{"API_SECRET": "secret", "HUB_ADVERTISE_URL": "https://web-1.example.com:42000"}))
+	require.NoError(t, err)
+	assert.Equal(t, "https://hub.example.com:42000", cfg.Hub.URL)
+	assert.Equal(t, "push-secret", cfg.Hub.Token)
+	assert.Equal(t, "web-1", cfg.Hub.NodeName)
+	assert.Equal(t, "https://web-1.example.com:42000", cfg.Hub.AdvertiseURL)
+	assert.Equal(t, 10*time.Second, cfg.Hub.HeartbeatInterval)
+
+	_, err = config.Load("", envOf(map[string]string{"API_SECRET": "secret", "HUB_URL": "hub:42000", "HUB_NODE_NAME": "web 1"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HUB_URL")
+	assert.Contains(t, err.Error(), "HUB_NODE_NAME")
+}
@@ -0,0 +1,172 @@
+package test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/handler"
+	"github.com/nodebytehosting/syscapture/internal/influx"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestInfluxLines tests the line protocol rendering of a snapshot
+func TestInfluxLines(t *testing.T) {
+	free, usage := uint64(1024), 50.5
+	m := metric.AllMetrics{
+		CPU:    metric.CPUData{LogicalCore: 4, UsagePercent: 12.5, Temperature: []float32{40}},
+		Memory: metric.MemoryData{TotalBytes: 2048, UsagePercent: &usage},
+		Disk:   metric.MetricsSlice{&metric.DiskData{Device: "/dev/disk 1", FreeBytes: &free}},
+		Host:   metric.HostData{Platform: "ubuntu"},
+	}
+	ts := time.Unix(1700000000, 5)
+
+	lines := strings.Split(strings.TrimSpace(string(influx.AppendLines(nil, collector.Snapshot{Timestamp: ts, Metrics: m}, map[string]string{"region": "eu,west"}))), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, `cpu,platform=ubuntu,region=eu\,west physical_core=0,logical_core=4,frequency=0,current_frequency=0,free_percent=0,usage_percent=12.5 1700000000000000005`, lines[0])
+	assert.Equal(t, `cpu,platform=ubuntu,region=eu\,west,sensor=0 temperature=40 1700000000000000005`, lines[1])
+	assert.Equal(t, `memory,platform=ubuntu,region=eu\,west total_bytes=2048,available_bytes=0,used_bytes=0,usage_percent=50.5 1700000000000000005`, lines[2])
+	assert.Equal(t, `disk,device=/dev/disk\ 1,platform=ubuntu,region=eu\,west free_bytes=1024 1700000000000000005`, lines[3])
+}
+
+// TestInfluxFormat tests that unknown formats of the metrics endpoint are rejected
+func TestInfluxFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/metrics", func(c *gin.Context) {
+		handler.Metrics(c, nil, metric.Filter{})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics?format=xml", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// influxReceiver is a stand-in InfluxDB v2 write API answering with the given statuses in turn
+type influxReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	batches  []string
+}
+
+func (ir *influxReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/v2/write" || r.URL.Query().Get("org") != "ops" || r.URL.Query().Get("bucket") != "nodes" ||
+		r.Header.Get("Authorization") != "Token secret" || r.Header.Get("Content-Encoding") != "gzip" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	if len(ir.statuses) > 0 {
+		status := ir.statuses[0]
+		ir.statuses = ir.statuses[1:]
+		if status != http.StatusNoContent {
+			w.WriteHeader(status)
+			return
+		}
+	}
+
+	zr, err := gzip.NewReader(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	body, _ := io.ReadAll(zr)
+	ir.batches = append(ir.batches, string(body))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestInfluxPusher tests batching, gzip and retries of the InfluxDB pusher
+func TestInfluxPusher(t *testing.T) {
+	receiver := &influxReceiver{statuses: []int{http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	var errs []error
+	pusher := influx.NewPusher(influx.PushOptions{
+		URL:       srv.URL + "/",
+		Org:       "ops",
+		Bucket:    "nodes",
+		Token:     "secret",
+		Tags:      map[string]string{"host": "node-1"},
+		BatchSize: 3,
+	}, func(err error) { errs = append(errs, err) })
+
+	now := time.Now()
+	pusher.Consume(snapshotAt(now, 1))
+	pusher.Consume(snapshotAt(now.Add(time.Second), 2))
+	assert.Equal(t, 6, pusher.Buffered())
+
+	// Failed writes keep the lines for the next flush
+	require.Error(t, pusher.Flush(context.Background()))
+	assert.Equal(t, 6, pusher.Buffered())
+
+	require.NoError(t, pusher.Flush(context.Background()))
+	assert.Zero(t, pusher.Buffered())
+	require.Len(t, receiver.batches, 2)
+	assert.Equal(t, 3, strings.Count(receiver.batches[0], "\n"))
+	assert.Equal(t, 3, strings.Count(receiver.batches[1], "\n"))
+	assert.Contains(t, receiver.batches[0], "cpu,host=node-1 ")
+	assert.Contains(t, receiver.batches[0], "disk,device=/dev/sda1,host=node-1 free_bytes=1024")
+
+	// Batches rejected as invalid are dropped
+	receiver.statuses = []int{http.StatusBadRequest}
+	pusher.Consume(snapshotAt(now.Add(2*time.Second), 3))
+	require.NoError(t, pusher.Flush(context.Background()))
+	assert.Zero(t, pusher.Buffered())
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], influx.ErrRejected)
+}
+
+// TestInfluxDropDuringWrite tests that lines buffered while a full buffer is being
+// written are not removed in place of the dropped ones that were sent
+func TestInfluxDropDuringWrite(t *testing.T) {
+	receiver := &influxReceiver{}
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			close(started)
+			<-release
+		})
+		receiver.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	pusher := influx.NewPusher(influx.PushOptions{
+		URL:         srv.URL,
+		Org:         "ops",
+		Bucket:      "nodes",
+		Token:       "secret",
+		BatchSize:   3,
+		MaxBuffered: 3,
+	}, func(error) {})
+
+	now := time.Now()
+	pusher.Consume(snapshotAt(now, 1))
+	done := make(chan error)
+	go func() {
+		done <- pusher.Flush(context.Background())
+	}()
+
+	<-started
+	pusher.Consume(snapshotAt(now.Add(time.Second), 2))
+	close(release)
+	require.NoError(t, <-done)
+
+	assert.Zero(t, pusher.Buffered())
+	require.Len(t, receiver.batches, 2)
+	assert.Contains(t, receiver.batches[0], "usage_percent=1 ")
+	assert.Contains(t, receiver.batches[1], "usage_percent=2 ")
+}
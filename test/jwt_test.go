@@ -0,0 +1,226 @@
+package test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/jwt"
+	"github.com/nodebytehosting/syscapture/internal/middleware"
+	"github.com/nodebytehosting/syscapture/internal/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var b64 = base64.RawURLEncoding
+
+// signJWT returns a compact JWT of claims signed with key
+func signJWT(t *testing.T, alg string, kid string, key crypto.Signer, claims map[string]any) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	input := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, signErr := ecdsa.Sign(rand.Reader, k, digest[:])
+		err = signErr
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(input))
+	}
+	require.NoError(t, err)
+
+	return input + "." + b64.EncodeToString(signature)
+}
+
+// writeJWKS writes a JWKS file holding the public keys
+func writeJWKS(t *testing.T, path string, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey, edKey ed25519.PrivateKey) {
+	var keys []map[string]string
+	if rsaKey != nil {
+		keys = append(keys, map[string]string{
+			"kty": "RSA", "kid": "rsa", "alg": "RS256", "use": "sig",
+			"n": b64.EncodeToString(rsaKey.N.Bytes()),
+			"e": b64.EncodeToString([]byte{1, 0, 1}),
+		})
+	}
+	if ecKey != nil {
+		keys = append(keys, map[string]string{
+			"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": b64.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+			"y": b64.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+		})
+	}
+	if edKey != nil {
+		keys = append(keys, map[string]string{
+			"kty": "OKP", "kid": "ed", "crv": "Ed25519",
+			"x": b64.EncodeToString(edKey.Public().(ed25519.PublicKey)),
+		})
+	}
+
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+// TestJWTVerifier tests signature algorithms, registered claims and scope mapping
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, rsaKey, ecKey, edKey)
+
+	verifier, err := jwt.NewVerifier(jwt.Options{
+		JWKSFile: path,
+		Issuer:   "https://panel.example.com",
+		Audience: "syscapture",
+		Leeway:   30 * time.Second,
+		ScopeMap: map[string][]string{
+			"node:read":  {token.ScopeMetricsRead},
+			"node:admin": {token.ScopeAdmin},
+		},
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"iss":   "https://panel.example.com",
+			"aud":   []string{"syscapture", "other"},
+			"sub":   "user-42",
+			"exp":   now.Add(time.Minute).Unix(),
+			"scope": "node:read unknown",
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	for alg, signer := range map[string]struct {
+		kid string
+		key crypto.Signer
+	}{
+		jwt.RS256: {"rsa", rsaKey},
+		jwt.ES256: {"ec", ecKey},
+		jwt.EdDSA: {"ed", edKey},
+	} {
+		got, err := verifier.Verify(signJWT(t, alg, signer.kid, signer.key, claims(nil)), now)
+		require.NoError(t, err, alg)
+		assert.Equal(t, "user-42", got.Subject)
+		assert.Equal(t, []string{token.ScopeMetricsRead}, got.Scopes)
+	}
+
+	cases := map[string]struct {
+		raw string
+		err error
+	}{
+		"expired":        {signJWT(t, jwt.ES256, "ec", ecKey, claims(map[string]any{"exp": now.Add(-time.Minute).Unix()})), jwt.ErrExpired},
+		"no expiry":      {signJWT(t, jwt.ES256, "ec", ecKey, claims(map[string]any{"exp": nil})), jwt.ErrExpired},
+		"not yet valid":  {signJWT(t, jwt.ES256, "ec", ecKey, claims(map[string]any{"nbf": now.Add(time.Minute).Unix()})), jwt.ErrNotYetValid},
+		"wrong issuer":   {signJWT(t, jwt.ES256, "ec", ecKey, claims(map[string]any{"iss": "https://evil.example.com"})), jwt.ErrIssuer},
+		"wrong audience": {signJWT(t, jwt.ES256, "ec", ecKey, claims(map[string]any{"aud": "other"})), jwt.ErrAudience},
+		"key mismatch":   {signJWT(t, jwt.RS256, "ec", rsaKey, claims(nil)), jwt.ErrUnknownKey},
+		"malformed":      {"a.b", jwt.ErrMalformed},
+		"none algorithm": {b64.EncodeToString([]byte(`{"alg":"none"}`)) + "." + b64.EncodeToString([]byte(`{}`)) + ".", jwt.ErrAlgorithm},
+	}
+	for name, tc := range cases {
+		_, err := verifier.Verify(tc.raw, now)
+		assert.ErrorIs(t, err, tc.err, name)
+	}
+
+	// Expiry within the leeway is accepted
+	_, err = verifier.Verify(signJWT(t, jwt.ES256, "ec", ecKey, claims(map[string]any{"exp": now.Add(-10 * time.Second).Unix()})), now)
+	require.NoError(t, err)
+
+	// A token signed by a different key with a known kid fails the signature check
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = verifier.Verify(signJWT(t, jwt.ES256, "ec", otherKey, claims(nil)), now)
+	require.ErrorIs(t, err, jwt.ErrSignature)
+
+	// Rotated keys are picked up after a reload
+	writeJWKS(t, path, nil, otherKey, nil)
+	require.NoError(t, verifier.Reload())
+	_, err = verifier.Verify(signJWT(t, jwt.ES256, "ec", otherKey, claims(nil)), now)
+	require.NoError(t, err)
+	_, err = verifier.Verify(signJWT(t, jwt.ES256, "ec", ecKey, claims(nil)), now)
+	require.ErrorIs(t, err, jwt.ErrSignature)
+}
+
+// TestJWTAuthRequired tests that AuthRequired accepts JWTs next to opaque tokens
+func TestJWTAuthRequired(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, nil, nil, edKey)
+
+	verifier, err := jwt.NewVerifier(jwt.Options{JWKSFile: path, Issuer: "panel", Audience: "node"})
+	require.NoError(t, err)
+
+	store, err := token.Open("")
+	require.NoError(t, err)
+	store.AddStatic("default", "secret", []string{token.ScopeAdmin})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.AuthRequired(store, middleware.NewJWTAuthenticator(verifier)))
+	r.GET("/metrics", middleware.RequireScope(token.ScopeMetricsRead), func(c *gin.Context) {
+		c.String(http.StatusOK, middleware.CurrentToken(c).Name)
+	})
+	r.GET("/tokens", middleware.RequireScope(token.ScopeAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(path string, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	valid := signJWT(t, jwt.EdDSA, "ed", edKey, map[string]any{
+		"iss": "panel", "aud": "node", "sub": "alice",
+		"exp": time.Now().Add(time.Minute).Unix(), "scope": []string{"metrics:read"},
+	})
+	w := do("/metrics", valid)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "jwt:alice", w.Body.String())
+	assert.Equal(t, http.StatusForbidden, do("/tokens", valid).Code)
+
+	expired := signJWT(t, jwt.EdDSA, "ed", edKey, map[string]any{
+		"iss": "panel", "aud": "node", "sub": "alice",
+		"exp": time.Now().Add(-time.Hour).Unix(), "scope": "metrics:read",
+	})
+	w = do("/metrics", expired)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Token has expired")
+
+	// Opaque tokens still go through the token store
+	assert.Equal(t, http.StatusOK, do("/tokens", "secret").Code)
+}
@@ -0,0 +1,73 @@
+package test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nodebytehosting/syscapture/internal/listener"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestListenerValidate tests the accepted address formats
+func TestListenerValidate(t *testing.T) {
+	for _, address := range []string{":42000", "127.0.0.1:42000", "[::1]:42000", "unix:/run/syscapture.sock"} {
+		assert.NoError(t, listener.Validate(address), address)
+	}
+	for _, address := range []string{"42000", "127.0.0.1", "127.0.0.1:0", "[::1]:70000", "unix:"} {
+		assert.Error(t, listener.Validate(address), address)
+	}
+}
+
+// TestListenerUnix tests socket permissions, stale sockets and local client addresses
+func TestListenerUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syscapture.sock")
+
+	l, err := listener.Listen(listener.UnixPrefix+path, listener.Options{SocketMode: 0o600})
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// A socket that is in use is not replaced
+	_, err = listener.Listen(listener.UnixPrefix+path, listener.Options{})
+	assert.ErrorContains(t, err, "in use")
+
+	var remoteAddr string
+	srv := &http.Server{Handler: listener.LocalClients(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddr = r.RemoteAddr
+	}))}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://syscapture/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "127.0.0.1:0", remoteAddr)
+
+	// A socket left behind by a crash is replaced
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path + ".stale", Net: "unix"})
+	require.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err = listener.Listen(listener.UnixPrefix+path+".stale", listener.Options{})
+	require.NoError(t, err)
+	l.Close()
+
+	// Other files are never removed
+	regular := filepath.Join(t.TempDir(), "regular")
+	require.NoError(t, os.WriteFile(regular, nil, 0o600))
+	_, err = listener.Listen(listener.UnixPrefix+regular, listener.Options{})
+	assert.ErrorContains(t, err, "not a socket")
+}
@@ -0,0 +1,195 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/nodebytehosting/syscapture/internal/handler"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/middleware"
+	"github.com/nodebytehosting/syscapture/internal/negotiate"
+	"github.com/nodebytehosting/syscapture/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNegotiate tests the media type chosen for Accept headers
+func TestNegotiate(t *testing.T) {
+	offers := []string{negotiate.JSON, negotiate.CBOR, negotiate.MessagePack}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", negotiate.JSON},
+		{"*/*", negotiate.JSON},
+		{"application/cbor", negotiate.CBOR},
+		{"application/x-msgpack", negotiate.MessagePack},
+		{"application/json;q=0.5, application/msgpack", negotiate.MessagePack},
+		{"application/cbor;q=0.2, */*;q=0.1", negotiate.CBOR},
+		{"application/*", negotiate.JSON},
+		{"text/html", negotiate.JSON},
+		{"application/cbor;q=0", negotiate.JSON},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, negotiate.Negotiate(tt.accept, offers...), tt.accept)
+	}
+
+	encodings := map[string]string{
+		"":                        "",
+		"gzip":                    middleware.EncodingGzip,
+		"gzip, deflate, br, zstd": middleware.EncodingZstd,
+		"zstd;q=0.5, gzip":        middleware.EncodingGzip,
+		"*":                       middleware.EncodingZstd,
+		"identity, gzip;q=0":      "",
+		"br, *;q=0.1, zstd;q=0":   middleware.EncodingGzip,
+		"GZIP;Q=0.8, deflate;q=1": middleware.EncodingGzip,
+	}
+	for header, want := range encodings {
+		assert.Equal(t, want, middleware.PreferredEncoding(header), header)
+	}
+}
+
+// TestNegotiateHistory tests the history in every supported encoding
+func TestNegotiateHistory(t *testing.T) {
+	store, err := storage.Open(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.Append(start.Add(time.Duration(i)*time.Minute), []metric.Sample{
+			{Name: "disk.free_bytes", Labels: map[string]string{"device": "/dev/sda1"}, Value: float64(i)},
+		}))
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/metrics/history", func(c *gin.Context) {
+		handler.MetricsHistory(c, store)
+	})
+
+	request := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics/history?metric=disk.free_bytes&from=-2h", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, accept)
+		return w
+	}
+
+	for _, mediaType := range []string{negotiate.JSON, negotiate.CBOR, negotiate.MessagePack} {
+		w := request(mediaType)
+		assert.Equal(t, mediaType, strings.Split(w.Header().Get("Content-Type"), ";")[0])
+
+		var response struct {
+			Data []metric.Series `json:"data"`
+		}
+		require.NoError(t, negotiate.Decode(w.Body, mediaType, &response), mediaType)
+		require.Len(t, response.Data, 1, mediaType)
+		assert.Equal(t, "/dev/sda1", response.Data[0].Labels["device"], mediaType)
+		require.Len(t, response.Data[0].Points, 3, mediaType)
+		assert.Equal(t, 2.0, response.Data[0].Points[2].Value, mediaType)
+		assert.True(t, start.Equal(response.Data[0].Points[0].Timestamp), mediaType)
+	}
+
+	w := request(negotiate.NDJSON)
+	assert.Equal(t, negotiate.NDJSON, w.Header().Get("Content-Type"))
+	assert.Equal(t, []string{"Accept"}, w.Header().Values("Vary"))
+	scanner := bufio.NewScanner(w.Body)
+	var values []float64
+	for scanner.Scan() {
+		var line struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+			Value  float64           `json:"value"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		assert.Equal(t, "disk.free_bytes", line.Name)
+		assert.Equal(t, "/dev/sda1", line.Labels["device"])
+		values = append(values, line.Value)
+	}
+	assert.Equal(t, []float64{0, 1, 2}, values)
+
+	// Behind Compress responses vary on both headers, compressed or not
+	compressed := gin.New()
+	compressed.Use(middleware.Compress())
+	compressed.GET("/metrics/history", func(c *gin.Context) {
+		handler.MetricsHistory(c, store)
+	})
+	for _, mediaType := range []string{negotiate.CBOR, negotiate.NDJSON} {
+		for _, encoding := range []string{"gzip", ""} {
+			req := httptest.NewRequest(http.MethodGet, "/metrics/history?metric=disk.free_bytes&from=-2h", nil)
+			req.Header.Set("Accept", mediaType)
+			req.Header.Set("Accept-Encoding", encoding)
+			w := httptest.NewRecorder()
+			compressed.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code, mediaType)
+			assert.Equal(t, encoding, w.Header().Get("Content-Encoding"), mediaType)
+			assert.ElementsMatch(t, []string{"Accept-Encoding", "Accept"}, w.Header().Values("Vary"), mediaType)
+		}
+	}
+}
+
+// TestCompress tests that responses are compressed with the preferred coding
+func TestCompress(t *testing.T) {
+	body := strings.Repeat(`{"cpu":{"usage_percent":0.125}}`, 100)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Compress())
+	r.GET("/data", func(c *gin.Context) {
+		c.Data(http.StatusOK, negotiate.JSON, []byte(body))
+	})
+	r.GET("/empty", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	request := func(path string, encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/data", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, []string{"Accept-Encoding"}, w.Header().Values("Vary"))
+	assert.Less(t, w.Body.Len(), len(body))
+	reader, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+
+	w = request("/data", "gzip;q=0.5, zstd")
+	assert.Equal(t, "zstd", w.Header().Get("Content-Encoding"))
+	decoder, err := zstd.NewReader(bytes.NewReader(w.Body.Bytes()))
+	require.NoError(t, err)
+	defer decoder.Close()
+	decoded, err = io.ReadAll(decoder)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+
+	// Uncompressed responses vary on Accept-Encoding as well
+	w = request("/data", "")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, []string{"Accept-Encoding"}, w.Header().Values("Vary"))
+	assert.Equal(t, body, w.Body.String())
+
+	w = request("/empty", "gzip")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Zero(t, w.Body.Len())
+}
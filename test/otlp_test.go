@@ -0,0 +1,210 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/otlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// otlpReceiver is a stand-in OTLP/HTTP receiver decoding protobuf and JSON exports
+type otlpReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []otlp.ExportRequest
+	types    []string
+}
+
+func (or *otlpReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != otlp.MetricsPath || r.Header.Get("Authorization") != "Api-Key secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	or.mu.Lock()
+	defer or.mu.Unlock()
+	if or.status != 0 {
+		w.WriteHeader(or.status)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	var request otlp.ExportRequest
+	var err error
+	switch r.Header.Get("Content-Type") {
+	case "application/x-protobuf":
+		request, err = otlp.UnmarshalProto(body)
+	case "application/json":
+		err = json.Unmarshal(body, &request)
+	default:
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	or.requests = append(or.requests, request)
+	or.types = append(or.types, r.Header.Get("Content-Type"))
+	w.WriteHeader(http.StatusOK)
+}
+
+// findMetric returns the metric with the given name of an export request
+func findMetric(t *testing.T, r otlp.ExportRequest, name string) otlp.Metric {
+	t.Helper()
+	require.Len(t, r.ResourceMetrics, 1)
+	require.Len(t, r.ResourceMetrics[0].ScopeMetrics, 1)
+	for _, m := range r.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if m.Name == name {
+			return m
+		}
+	}
+	t.Fatalf("metric %s not exported", name)
+	return otlp.Metric{}
+}
+
+// attribute returns the value of the attribute key
+func attribute(attrs []otlp.KeyValue, key string) string {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value.StringValue
+		}
+	}
+	return ""
+}
+
+// TestOTLPProtoRoundTrip tests that the protobuf encoding decodes to the same request
+func TestOTLPProtoRoundTrip(t *testing.T) {
+	free := uint64(1024)
+	m := metric.AllMetrics{
+		CPU:  metric.CPUData{UsagePercent: 12.5, Temperature: []float32{40}},
+		Disk: metric.MetricsSlice{&metric.DiskData{Device: "/dev/sda1", FreeBytes: &free}},
+		Host: metric.HostData{Os: "linux", Platform: "ubuntu", KernelVersion: "6.8.0"},
+	}
+	start := time.Unix(1700000000, 0)
+	resource := otlp.ResourceAttributes(m.Host, "1.0.0", map[string]string{"deployment.environment": "prod"})
+	request := otlp.BuildRequest(m.Samples(), start.Add(time.Minute), otlp.Counters{Start: start, Collections: 6, Errors: 1}, resource, "1.0.0")
+
+	decoded, err := otlp.UnmarshalProto(request.MarshalProto())
+	require.NoError(t, err)
+	assert.Equal(t, request, decoded)
+
+	attrs := decoded.ResourceMetrics[0].Resource.Attributes
+	assert.Equal(t, "syscapture", attribute(attrs, "service.name"))
+	assert.Equal(t, "1.0.0", attribute(attrs, "service.version"))
+	assert.Equal(t, "linux", attribute(attrs, "os.type"))
+	assert.Equal(t, "ubuntu", attribute(attrs, "os.name"))
+	assert.Equal(t, "6.8.0", attribute(attrs, "os.version"))
+	assert.Equal(t, "prod", attribute(attrs, "deployment.environment"))
+	assert.NotEmpty(t, attribute(attrs, "host.arch"))
+
+	usage := findMetric(t, decoded, "cpu.usage_percent")
+	require.NotNil(t, usage.Gauge)
+	assert.Equal(t, "%", usage.Unit)
+	assert.Equal(t, 12.5, usage.Gauge.DataPoints[0].AsDouble)
+
+	disk := findMetric(t, decoded, "disk.free_bytes")
+	assert.Equal(t, "By", disk.Unit)
+	assert.Equal(t, "/dev/sda1", attribute(disk.Gauge.DataPoints[0].Attributes, "device"))
+
+	collections := findMetric(t, decoded, "syscapture.collections")
+	require.NotNil(t, collections.Sum)
+	assert.Equal(t, otlp.TemporalityCumulative, collections.Sum.AggregationTemporality)
+	assert.True(t, collections.Sum.IsMonotonic)
+	assert.Equal(t, uint64(start.UnixNano()), collections.Sum.DataPoints[0].StartTimeUnixNano)
+	assert.Equal(t, float64(6), collections.Sum.DataPoints[0].AsDouble)
+}
+
+// TestOTLPExporter tests exports to a stand-in receiver over protobuf and JSON
+func TestOTLPExporter(t *testing.T) {
+	for _, protocol := range []string{otlp.ProtocolProtobuf, otlp.ProtocolJSON} {
+		t.Run(protocol, func(t *testing.T) {
+			receiver := &otlpReceiver{}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			exporter := otlp.New(otlp.Options{
+				Endpoint: server.URL,
+				Protocol: protocol,
+				Headers:  map[string]string{"Authorization": "Api-Key secret"},
+				Version:  "1.0.0",
+			}, func(error) {})
+			ctx := context.Background()
+
+			// Nothing collected yet
+			require.NoError(t, exporter.Export(ctx))
+			assert.Empty(t, receiver.requests)
+
+			now := time.Now()
+			first := snapshotAt(now, 10)
+			first.ID = 1
+			first.Errors = []metric.CustomErr{{Metric: []string{"cpu.temperature"}, Error: "no sensors"}}
+			exporter.Consume(first)
+			require.NoError(t, exporter.Export(ctx))
+
+			// An export failing with a retryable status keeps the counts for the next one
+			receiver.status = http.StatusServiceUnavailable
+			second := snapshotAt(now.Add(10*time.Second), 20)
+			second.ID = 2
+			exporter.Consume(second)
+			assert.Error(t, exporter.Export(ctx))
+			receiver.status = 0
+			require.NoError(t, exporter.Export(ctx))
+
+			// The latest snapshot is exported only once
+			require.NoError(t, exporter.Export(ctx))
+			require.Len(t, receiver.requests, 2)
+
+			for _, contentType := range receiver.types {
+				if protocol == otlp.ProtocolJSON {
+					assert.Equal(t, "application/json", contentType)
+				} else {
+					assert.Equal(t, "application/x-protobuf", contentType)
+				}
+			}
+
+			last := receiver.requests[1]
+			usage := findMetric(t, last, "cpu.usage_percent")
+			assert.Equal(t, float64(20), usage.Gauge.DataPoints[0].AsDouble)
+			assert.Equal(t, uint64(second.Timestamp.UnixNano()), usage.Gauge.DataPoints[0].TimeUnixNano)
+
+			collections := findMetric(t, last, "syscapture.collections")
+			assert.Equal(t, float64(2), collections.Sum.DataPoints[0].AsDouble)
+			assert.Equal(t, otlp.TemporalityCumulative, collections.Sum.AggregationTemporality)
+			assert.Equal(t, float64(1), findMetric(t, last, "syscapture.collection_errors").Sum.DataPoints[0].AsDouble)
+
+			// The start time of the cumulative sums stays the same across exports
+			assert.Equal(t,
+				findMetric(t, receiver.requests[0], "syscapture.collections").Sum.DataPoints[0].StartTimeUnixNano,
+				collections.Sum.DataPoints[0].StartTimeUnixNano)
+		})
+	}
+}
+
+// TestOTLPExporterRejected tests that a rejected export is not retried
+func TestOTLPExporterRejected(t *testing.T) {
+	receiver := &otlpReceiver{status: http.StatusBadRequest}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	exporter := otlp.New(otlp.Options{
+		Endpoint: server.URL,
+		Headers:  map[string]string{"Authorization": "Api-Key secret"},
+	}, func(error) {})
+	exporter.Consume(collector.Snapshot{ID: 1, Timestamp: time.Now()})
+
+	assert.ErrorIs(t, exporter.Export(context.Background()), otlp.ErrRejected)
+	receiver.status = 0
+	require.NoError(t, exporter.Export(context.Background()))
+	assert.Empty(t, receiver.requests)
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/promtext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPromText tests the text exposition of the requested samples and host fields
+func TestPromText(t *testing.T) {
+	total, free, usage := uint64(270553174016), uint64(1024), 0.5
+	m := metric.AllMetrics{
+		CPU:    metric.CPUData{UsagePercent: 0.125},
+		Memory: metric.MemoryData{TotalBytes: 2048, UsagePercent: &usage},
+		Disk: metric.MetricsSlice{
+			&metric.DiskData{Device: "/dev/sda1", TotalBytes: &total, FreeBytes: &free},
+			&metric.DiskData{Device: `/dev/"b"`, TotalBytes: &total},
+		},
+		Host: metric.HostData{Os: "linux", Platform: "debian"},
+	}
+
+	filter, err := metric.ParseFilter("", []string{"cpu.usage_percent,disk.total_bytes,host.platform,host.kernel_version"}, nil)
+	require.NoError(t, err)
+	b := promtext.AppendSamples(nil, filter.Samples(m))
+	b = promtext.AppendInfo(b, "host.info", filter.HostInfo(m.Host))
+	assert.Equal(t, `# TYPE syscapture_cpu_usage_percent gauge
+syscapture_cpu_usage_percent 0.125
+# TYPE syscapture_disk_total_bytes gauge
+syscapture_disk_total_bytes{device="/dev/sda1"} 270553174016
+syscapture_disk_total_bytes{device="/dev/\"b\""} 270553174016
+# TYPE syscapture_host_info gauge
+syscapture_host_info{platform="debian"} 1
+`, string(b))
+
+	filter, err = metric.ParseFilter("", []string{"memory"}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, filter.HostInfo(m.Host))
+	assert.Empty(t, promtext.AppendInfo(nil, "host.info", filter.HostInfo(m.Host)))
+
+	filter, err = metric.ParseFilter("", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"os": "linux", "platform": "debian"}, filter.HostInfo(m.Host))
+}
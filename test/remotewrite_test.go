@@ -0,0 +1,238 @@
+package test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/remotewrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// remoteWriteReceiver is a stand-in remote_write endpoint answering with the
+// given statuses in turn, and 204 once they are used up
+type remoteWriteReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	series   []remotewrite.TimeSeries
+	requests atomic.Int32
+}
+
+func (rw *remoteWriteReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw.requests.Add(1)
+	if user, password, _ := r.BasicAuth(); user != "node" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if len(rw.statuses) > 0 {
+		status := rw.statuses[0]
+		rw.statuses = rw.statuses[1:]
+		if status != http.StatusNoContent {
+			w.WriteHeader(status)
+			return
+		}
+	}
+
+	compressed, _ := io.ReadAll(r.Body)
+	body, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	series, err := remotewrite.DecodeWriteRequest(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rw.series = append(rw.series, series...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// received returns the pushed series
+func (rw *remoteWriteReceiver) received() []remotewrite.TimeSeries {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	return append([]remotewrite.TimeSeries(nil), rw.series...)
+}
+
+// snapshotAt returns a snapshot with a CPU and a disk sample
+func snapshotAt(ts time.Time, usage float64) collector.Snapshot {
+	free := uint64(1024)
+	return collector.Snapshot{
+		Timestamp: ts,
+		Metrics: metric.AllMetrics{
+			CPU:  metric.CPUData{UsagePercent: usage},
+			Disk: metric.MetricsSlice{&metric.DiskData{Device: "/dev/sda1", FreeBytes: &free}},
+		},
+	}
+}
+
+// findSeries returns the series with the given name and label
+func findSeries(series []remotewrite.TimeSeries, name string, label string, value string) []remotewrite.TimeSeries {
+	var found []remotewrite.TimeSeries
+	for _, ts := range series {
+		var hasName, hasLabel bool
+		for _, l := range ts.Labels {
+			hasName = hasName || (l.Name == "__name__" && l.Value == name)
+			hasLabel = hasLabel || (l.Name == label && l.Value == value)
+		}
+		if hasName && hasLabel {
+			found = append(found, ts)
+		}
+	}
+	return found
+}
+
+// TestRemoteWrite tests pushing with basic auth and external labels
+func TestRemoteWrite(t *testing.T) {
+	receiver := &remoteWriteReceiver{}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	writer, err := remotewrite.New(remotewrite.Options{
+		URL:            srv.URL,
+		Username:       "node",
+		Password:       "secret",
+		ExternalLabels: map[string]string{"instance": "node-1", "device": "ignored"},
+	}, func(err error) { t.Error(err) })
+	require.NoError(t, err)
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+	writer.Consume(snapshotAt(now, 12.5))
+	writer.Consume(snapshotAt(now.Add(10*time.Second), 15))
+	writer.Flush(context.Background())
+	assert.Zero(t, writer.Queued())
+	assert.Equal(t, int32(1), receiver.requests.Load())
+
+	cpu := findSeries(receiver.received(), "syscapture_cpu_usage_percent", "instance", "node-1")
+	require.Len(t, cpu, 2)
+	assert.Equal(t, 12.5, cpu[0].Samples[0].Value)
+	assert.Equal(t, now.UnixMilli(), cpu[0].Samples[0].Timestamp)
+	assert.Equal(t, 15.0, cpu[1].Samples[0].Value)
+
+	// Labels of the samples win over external labels
+	disk := findSeries(receiver.received(), "syscapture_disk_free_bytes", "device", "/dev/sda1")
+	require.Len(t, disk, 2)
+	assert.Equal(t, 1024.0, disk[0].Samples[0].Value)
+}
+
+// TestRemoteWriteRetry tests that outages are retried with backoff from the on-disk queue
+func TestRemoteWriteRetry(t *testing.T) {
+	receiver := &remoteWriteReceiver{statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	opts := remotewrite.Options{
+		URL:        srv.URL,
+		Username:   "node",
+		Password:   "secret",
+		QueueDir:   t.TempDir(),
+		QueueSize:  2,
+		MinBackoff: 10 * time.Millisecond,
+	}
+	var errs atomic.Int32
+	writer, err := remotewrite.New(opts, func(error) { errs.Add(1) })
+	require.NoError(t, err)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		writer.Consume(snapshotAt(now.Add(time.Duration(i)*time.Second), float64(i)))
+	}
+	// The oldest collection was dropped from the full queue
+	assert.Equal(t, 2, writer.Queued())
+	assert.Equal(t, int32(1), errs.Load())
+
+	// The queue survives a restart
+	writer, err = remotewrite.New(opts, func(error) { errs.Add(1) })
+	require.NoError(t, err)
+	assert.Equal(t, 2, writer.Queued())
+
+	writer.Flush(context.Background())
+	assert.Zero(t, writer.Queued())
+	assert.Equal(t, int32(3), receiver.requests.Load())
+	assert.Equal(t, int32(3), errs.Load())
+
+	cpu := findSeries(receiver.received(), "syscapture_cpu_usage_percent", "__name__", "syscapture_cpu_usage_percent")
+	require.Len(t, cpu, 2)
+	assert.Equal(t, 1.0, cpu[0].Samples[0].Value)
+	assert.Equal(t, 2.0, cpu[1].Samples[0].Value)
+}
+
+// TestRemoteWriteRejected tests that requests rejected as invalid are dropped
+func TestRemoteWriteRejected(t *testing.T) {
+	receiver := &remoteWriteReceiver{}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	var errs []error
+	writer, err := remotewrite.New(remotewrite.Options{URL: srv.URL, BearerToken: "wrong"}, func(err error) {
+		errs = append(errs, err)
+	})
+	require.NoError(t, err)
+
+	writer.Consume(snapshotAt(time.Now(), 1))
+	writer.Flush(context.Background())
+	assert.Zero(t, writer.Queued())
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], remotewrite.ErrRejected)
+}
+
+// TestRemoteWriteDropDuringPush tests that a collection queued while a full queue is
+// being pushed is not removed in place of the dropped one that was sent
+func TestRemoteWriteDropDuringPush(t *testing.T) {
+	receiver := &remoteWriteReceiver{}
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			close(started)
+			<-release
+		})
+		receiver.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	writer, err := remotewrite.New(remotewrite.Options{
+		URL:       srv.URL,
+		Username:  "node",
+		Password:  "secret",
+		QueueSize: 1,
+	}, func(error) {})
+	require.NoError(t, err)
+
+	now := time.Now()
+	writer.Consume(snapshotAt(now, 1))
+	done := make(chan struct{})
+	go func() {
+		writer.Flush(context.Background())
+		close(done)
+	}()
+
+	<-started
+	writer.Consume(snapshotAt(now.Add(time.Second), 2))
+	close(release)
+	<-done
+
+	assert.Zero(t, writer.Queued())
+	cpu := findSeries(receiver.received(), "syscapture_cpu_usage_percent", "__name__", "syscapture_cpu_usage_percent")
+	require.Len(t, cpu, 2)
+	assert.Equal(t, 1.0, cpu[0].Samples[0].Value)
+	assert.Equal(t, 2.0, cpu[1].Samples[0].Value)
+}
@@ -0,0 +1,245 @@
+package test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"net/http"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/audit"
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/jwt"
+	"github.com/nodebytehosting/syscapture/internal/middleware"
+	"github.com/nodebytehosting/syscapture/internal/rpc"
+	pb "github.com/nodebytehosting/syscapture/internal/rpc/syscapturev1"
+	"github.com/nodebytehosting/syscapture/internal/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newRPCClient starts the gRPC service over an in-memory connection and returns a client of it
+func newRPCClient(t *testing.T, store *token.Store) pb.SysCaptureClient {
+	return newGuardedRPCClient(t, rpc.Options{Tokens: store})
+}
+
+// newGuardedRPCClient is newRPCClient with the access lists, bans, rate limits and audit log of opts
+func newGuardedRPCClient(t *testing.T, opts rpc.Options) pb.SysCaptureClient {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	opts.Collector = collector.New(time.Second, collector.DefaultBufferSize)
+	go opts.Collector.Run(ctx)
+
+	listener := bufconn.Listen(1 << 20)
+	server := rpc.NewServer(opts)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewSysCaptureClient(conn)
+}
+
+// withToken returns a context sending secret as Bearer token
+func withToken(secret string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+secret)
+}
+
+// TestRPCAuth tests that calls are authenticated like on the HTTP API
+func TestRPCAuth(t *testing.T) {
+	store, err := token.Open("")
+	require.NoError(t, err)
+	_, reader, err := store.Create("reader", []string{token.ScopeMetricsRead}, nil)
+	require.NoError(t, err)
+	client := newRPCClient(t, store)
+
+	// The messages are those of the HTTP API, see middleware.AuthFailure
+	_, err = client.GetCollector(context.Background(), &pb.GetCollectorRequest{Collector: "host"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, "Authorization token required", status.Convert(err).Message())
+
+	_, err = client.GetCollector(withToken("wrong"), &pb.GetCollectorRequest{Collector: "host"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, "Invalid token provided", status.Convert(err).Message())
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic "+reader)
+	_, err = client.GetCollector(ctx, &pb.GetCollectorRequest{Collector: "host"})
+	assert.Equal(t, "Unable to parse 'Authorization' header", status.Convert(err).Message())
+
+	// The process list needs its own scope
+	_, err = client.ListProcesses(withToken(reader), &pb.ListProcessesRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stream, err := client.WatchMetrics(context.Background(), &pb.WatchMetricsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+// TestRPCMetrics tests the unary and streaming metric calls
+func TestRPCMetrics(t *testing.T) {
+	store, err := token.Open("")
+	require.NoError(t, err)
+	_, reader, err := store.Create("reader", []string{token.ScopeMetricsRead, token.ScopeProcessesRead}, nil)
+	require.NoError(t, err)
+	client := newRPCClient(t, store)
+	ctx := withToken(reader)
+
+	response, err := client.GetCollector(ctx, &pb.GetCollectorRequest{Collector: "memory"})
+	require.NoError(t, err)
+	assert.NotZero(t, response.GetMetrics().GetMemory().GetTotalBytes())
+	assert.Nil(t, response.GetMetrics().GetCpu())
+	assert.Empty(t, response.GetMetrics().GetDisk())
+
+	_, err = client.GetCollector(ctx, &pb.GetCollectorRequest{Collector: "network"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.GetMetrics(ctx, &pb.GetMetricsRequest{Devices: []string{"/dev/does-not-exist"}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	watchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	stream, err := client.WatchMetrics(watchCtx, &pb.WatchMetricsRequest{IntervalSeconds: 1000})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	stream, err = client.WatchMetrics(watchCtx, &pb.WatchMetricsRequest{})
+	require.NoError(t, err)
+	first, err := stream.Recv()
+	require.NoError(t, err)
+	assert.NotZero(t, first.GetId())
+	assert.NotNil(t, first.GetMetrics().GetCpu())
+	assert.NotNil(t, first.GetMetrics().GetHost())
+	second, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Greater(t, second.GetId(), first.GetId())
+
+	processes, err := client.ListProcesses(ctx, &pb.ListProcessesRequest{OrderBy: pb.ListProcessesRequest_ORDER_MEMORY, Limit: 3})
+	require.NoError(t, err)
+	require.NotEmpty(t, processes.GetProcesses())
+	assert.LessOrEqual(t, len(processes.GetProcesses()), 3)
+	for i := 1; i < len(processes.GetProcesses()); i++ {
+		assert.GreaterOrEqual(t, processes.GetProcesses()[i-1].GetMemoryBytes(), processes.GetProcesses()[i].GetMemoryBytes())
+	}
+}
+
+// TestRPCAccess tests that calls pass the access list, bans, rate limits and
+// audit log of the HTTP API
+func TestRPCAccess(t *testing.T) {
+	store, err := token.Open("")
+	require.NoError(t, err)
+	_, reader, err := store.Create("reader", []string{token.ScopeMetricsRead}, nil)
+	require.NoError(t, err)
+	log, err := audit.Open(audit.Options{Destination: filepath.Join(t.TempDir(), "audit.log")})
+	require.NoError(t, err)
+	defer log.Close()
+
+	// In-memory connections are reported as 127.0.0.1, like unix domain sockets
+	access := middleware.NewAccessList(nil, []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")})
+	bans := middleware.NewAuthBan(2, time.Minute, time.Minute)
+	client := newGuardedRPCClient(t, rpc.Options{Tokens: store, Access: access, Bans: bans, Audit: log})
+	request := &pb.GetCollectorRequest{Collector: "host"}
+
+	_, err = client.GetCollector(withToken(reader), request)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, "Access denied for this address", status.Convert(err).Message())
+
+	// Two failed authentications ban the client, even with a valid token
+	access.Set(nil, nil)
+	_, err = client.GetCollector(withToken(reader), request)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = client.GetCollector(withToken("wrong"), request)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+	_, err = client.GetCollector(withToken(reader), request)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, "Too many failed authentication attempts", status.Convert(err).Message())
+
+	entries, err := log.Query(audit.Query{})
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+	assert.Equal(t, "GRPC", entries[0].Method)
+	assert.Equal(t, pb.SysCapture_GetCollector_FullMethodName, entries[0].Route)
+	assert.Equal(t, "127.0.0.1", entries[0].ClientIP)
+	assert.Equal(t, http.StatusForbidden, entries[0].Status)
+	assert.Equal(t, "Access denied for this address", entries[0].Error)
+	assert.Equal(t, http.StatusOK, entries[1].Status)
+	assert.Equal(t, "reader", entries[1].Token)
+	assert.Equal(t, http.StatusUnauthorized, entries[2].Status)
+	assert.Equal(t, "Invalid token provided", entries[2].Error)
+	assert.Equal(t, "Too many failed authentication attempts", entries[4].Error)
+}
+
+// TestRPCRateLimit tests the per-IP and per-token rate limits of calls
+func TestRPCRateLimit(t *testing.T) {
+	store, err := token.Open("")
+	require.NoError(t, err)
+	_, reader, err := store.Create("reader", []string{token.ScopeMetricsRead}, nil)
+	require.NoError(t, err)
+	ipLimiter, tokenLimiter := middleware.NewRateLimiter(0, 1), middleware.NewRateLimiter(0.001, 1)
+	client := newGuardedRPCClient(t, rpc.Options{Tokens: store, IPLimiter: ipLimiter, TokenLimiter: tokenLimiter})
+	request := &pb.GetCollectorRequest{Collector: "host"}
+
+	_, err = client.GetCollector(withToken(reader), request)
+	require.NoError(t, err)
+	_, err = client.GetCollector(withToken(reader), request)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	tokenLimiter.SetRate(0, 1)
+	ipLimiter.SetRate(0.001, 1)
+	_, err = client.GetCollector(withToken(reader), request)
+	require.NoError(t, err)
+	_, err = client.GetCollector(withToken("wrong"), request)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+// TestRPCJWT tests that calls accept JWTs next to opaque tokens
+func TestRPCJWT(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, nil, nil, edKey)
+	verifier, err := jwt.NewVerifier(jwt.Options{JWKSFile: path, Issuer: "panel", Audience: "node"})
+	require.NoError(t, err)
+
+	store, err := token.Open("")
+	require.NoError(t, err)
+	client := newGuardedRPCClient(t, rpc.Options{Tokens: store, JWT: middleware.NewJWTAuthenticator(verifier)})
+	request := &pb.GetCollectorRequest{Collector: "host"}
+
+	valid := signJWT(t, jwt.EdDSA, "ed", edKey, map[string]any{
+		"iss": "panel", "aud": "node", "sub": "alice",
+		"exp": time.Now().Add(time.Minute).Unix(), "scope": []string{"metrics:read"},
+	})
+	_, err = client.GetCollector(withToken(valid), request)
+	require.NoError(t, err)
+
+	_, err = client.ListProcesses(withToken(valid), &pb.ListProcessesRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	expired := signJWT(t, jwt.EdDSA, "ed", edKey, map[string]any{
+		"iss": "panel", "aud": "node", "sub": "alice",
+		"exp": time.Now().Add(-time.Hour).Unix(), "scope": "metrics:read",
+	})
+	_, err = client.GetCollector(withToken(expired), request)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, "Token has expired", status.Convert(err).Message())
+}
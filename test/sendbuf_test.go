@@ -0,0 +1,33 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/nodebytehosting/syscapture/internal/sendbuf"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSendBuffer tests that sent items are removed by position, so items pushed
+// and dropped while sending are accounted for
+func TestSendBuffer(t *testing.T) {
+	var discarded []int
+	buf := sendbuf.New(3, func(i int) { discarded = append(discarded, i) })
+
+	assert.Zero(t, buf.Push(1, 2))
+	items, end := buf.Peek(10)
+	assert.Equal(t, []int{1, 2}, items)
+
+	// While 1 and 2 are sent, 3 to 5 arrive and 1 and 2 are dropped
+	assert.Equal(t, 2, buf.Push(3, 4, 5))
+	buf.Remove(end)
+	assert.Equal(t, 3, buf.Len())
+
+	items, end = buf.Peek(2)
+	assert.Equal(t, []int{3, 4}, items)
+	assert.Equal(t, 1, buf.Push(6))
+	buf.Remove(end)
+
+	items, _ = buf.Peek(10)
+	assert.Equal(t, []int{5, 6}, items)
+	assert.Equal(t, []int{1, 2, 3, 4}, discarded)
+}
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/nodebytehosting/syscapture/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStorageRollup tests that raw samples are rolled up into the minute and hour tiers
+// and that in-progress buckets survive reopening the store
+func TestStorageRollup(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-3 * time.Hour).Truncate(time.Hour)
+
+	store, err := storage.Open(dir)
+	require.NoError(t, err)
+
+	// Two samples per minute for 90 minutes, values 0..179
+	for i := 0; i < 180; i++ {
+		ts := start.Add(time.Duration(i) * 30 * time.Second)
+		require.NoError(t, store.Append(ts, []metric.Sample{
+			{Name: "cpu.usage_percent", Value: float64(i)},
+			{Name: "disk.free_bytes", Labels: map[string]string{"device": "/dev/sda1"}, Value: 100},
+		}))
+	}
+	require.NoError(t, store.Close())
+
+	// Reopening replays the raw tier into the lost minute bucket
+	store, err = storage.Open(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.Append(start.Add(2*time.Hour), []metric.Sample{{Name: "cpu.usage_percent", Value: 0}}))
+
+	minutes, err := store.Query(storage.Query{
+		Name:       "cpu.usage_percent",
+		From:       start,
+		To:         start.Add(2 * time.Hour),
+		Resolution: "1m",
+	})
+	require.NoError(t, err)
+	require.Len(t, minutes, 1)
+	require.Len(t, minutes[0].Points, 90)
+	assert.Equal(t, 0.5, minutes[0].Points[0].Value)
+	assert.Equal(t, uint64(2), minutes[0].Points[0].Count)
+	assert.Equal(t, 178.0, minutes[0].Points[89].Min)
+	assert.Equal(t, 179.0, minutes[0].Points[89].Max)
+
+	hours, err := store.Query(storage.Query{
+		Name:       "cpu.usage_percent",
+		From:       start,
+		To:         start.Add(2 * time.Hour),
+		Resolution: "1h",
+	})
+	require.NoError(t, err)
+	require.Len(t, hours, 1)
+	require.Len(t, hours[0].Points, 1) // The second hour is still in progress
+	assert.Equal(t, uint64(120), hours[0].Points[0].Count)
+	assert.Equal(t, 59.5, hours[0].Points[0].Value)
+
+	disks, err := store.Query(storage.Query{
+		Name:   "disk.free_bytes",
+		Labels: map[string]string{"device": "/dev/sda1"},
+		From:   start,
+		To:     start.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Len(t, disks, 1)
+	assert.Equal(t, "raw", disks[0].Resolution)
+	assert.Equal(t, "/dev/sda1", disks[0].Labels["device"])
+}
+
+// TestStorageCrashRecovery tests that a partially written record is truncated on open
+func TestStorageCrashRecovery(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Now().Add(-time.Minute)
+
+	store, err := storage.Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Append(ts, []metric.Sample{{Name: "memory.used_bytes", Value: 42}}))
+	require.NoError(t, store.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "raw", "*.seg"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	info, err := os.Stat(segments[0])
+	require.NoError(t, err)
+	validSize := info.Size()
+
+	// Simulate a crash in the middle of a write
+	f, err := os.OpenFile(segments[0], os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0x20, 0x00, 0x00, 0x00, 0xde, 0xad})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	store, err = storage.Open(dir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	info, err = os.Stat(segments[0])
+	require.NoError(t, err)
+	assert.Equal(t, validSize, info.Size())
+
+	require.NoError(t, store.Append(ts.Add(time.Second), []metric.Sample{{Name: "memory.used_bytes", Value: 43}}))
+
+	series, err := store.Query(storage.Query{Name: "memory.used_bytes", From: ts.Add(-time.Second)})
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	require.Len(t, series[0].Points, 2)
+	assert.Equal(t, 42.0, series[0].Points[0].Value)
+	assert.Equal(t, 43.0, series[0].Points[1].Value)
+}
+
+// TestStorageConcurrentQuery tests that queries running while samples are appended see
+// every complete record, without blocking or failing on the one being written
+func TestStorageConcurrentQuery(t *testing.T) {
+	store, err := storage.Open(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 500; i++ {
+			if err := store.Append(start.Add(time.Duration(i)*time.Second), []metric.Sample{{Name: "cpu.usage_percent", Value: float64(i)}}); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	seen := 0
+	for appending := true; appending; {
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+			appending = false
+		default:
+		}
+
+		series, err := store.Query(storage.Query{Name: "cpu.usage_percent", From: start, Resolution: "raw"})
+		require.NoError(t, err)
+		if len(series) == 0 {
+			continue
+		}
+		points := series[0].Points
+		require.GreaterOrEqual(t, len(points), seen)
+		for i, p := range points {
+			require.Equal(t, float64(i), p.Value)
+		}
+		seen = len(points)
+	}
+	assert.Equal(t, 500, seen)
+}
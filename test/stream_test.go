@@ -0,0 +1,162 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/handler"
+	"github.com/nodebytehosting/syscapture/internal/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamCollector is a collector making one collection each time collect is called
+type streamCollector struct {
+	*collector.Collector
+	step chan struct{}
+}
+
+// newStreamServer starts a test server exposing the metrics stream of a collector
+// keeping bufferSize snapshots
+func newStreamServer(t *testing.T, bufferSize int) (*streamCollector, string) {
+	gin.SetMode(gin.TestMode)
+
+	step := make(chan struct{})
+	usage := 0.0
+	col := &streamCollector{
+		Collector: collector.NewWithFunc(time.Millisecond, bufferSize, func() (metric.AllMetrics, []metric.CustomErr) {
+			<-step
+			usage += 0.01
+			return metric.AllMetrics{CPU: metric.CPUData{UsagePercent: usage}}, nil
+		}),
+		step: step,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go col.Run(ctx)
+
+	r := gin.New()
+	r.GET("/stream", func(c *gin.Context) {
+		handler.MetricsStream(c, col.Collector)
+	})
+	srv := httptest.NewServer(r)
+	t.Cleanup(func() {
+		srv.Close()
+		cancel()
+		close(step)
+	})
+	return col, srv.URL + "/stream"
+}
+
+// collect makes n collections and returns the IDs of every buffered snapshot
+func (c *streamCollector) collect(t *testing.T, n int) []uint64 {
+	for i := 0; i < n; i++ {
+		var before uint64
+		if s, ok := c.Latest(); ok {
+			before = s.ID
+		}
+		c.step <- struct{}{}
+		require.Eventually(t, func() bool {
+			s, ok := c.Latest()
+			return ok && s.ID > before
+		}, time.Second, time.Millisecond)
+		time.Sleep(2 * time.Millisecond) // Keep the events apart from the stream interval
+	}
+
+	var ids []uint64
+	for _, s := range c.Since(0) {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// openStream requests the stream resuming after lastID and returns a function
+// reading the IDs of the next events
+func openStream(t *testing.T, url string, lastID uint64) func() uint64 {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", strconv.FormatUint(lastID, 10))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan uint64)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+				value, _ := strconv.ParseUint(id, 10, 64)
+				select {
+				case events <- value:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return func() uint64 {
+		select {
+		case id := <-events:
+			return id
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+			return 0
+		}
+	}
+}
+
+// TestStreamResume tests that a client sending Last-Event-ID first receives the
+// buffered events it missed, then the new ones
+func TestStreamResume(t *testing.T) {
+	col, url := newStreamServer(t, collector.DefaultBufferSize)
+	ids := col.collect(t, 3)
+	require.Len(t, ids, 3)
+
+	next := openStream(t, url, ids[0])
+	assert.Equal(t, ids[1], next())
+	assert.Equal(t, ids[2], next())
+
+	ids = col.collect(t, 1)
+	assert.Equal(t, ids[3], next())
+}
+
+// TestStreamGap tests that a client whose last event is no longer buffered resumes
+// from the oldest buffered event
+func TestStreamGap(t *testing.T) {
+	col, url := newStreamServer(t, 2)
+	first := col.collect(t, 1)
+	ids := col.collect(t, 3)
+	require.Len(t, ids, 2, "only the last two snapshots are buffered")
+
+	next := openStream(t, url, first[0])
+	assert.Equal(t, ids[0], next())
+	assert.Equal(t, ids[1], next())
+}
+
+// TestStreamInvalidLastEventID tests that a malformed Last-Event-ID is rejected
+func TestStreamInvalidLastEventID(t *testing.T) {
+	_, url := newStreamServer(t, 1)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "yesterday")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nodebytehosting/syscapture/internal/systemd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNotifySocket listens like systemd on a datagram socket and returns the received messages
+func fakeNotifySocket(t *testing.T) (string, <-chan string) {
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	messages := make(chan string, 16)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				close(messages)
+				return
+			}
+			messages <- string(buf[:n])
+		}
+	}()
+	return path, messages
+}
+
+// receive waits for the next notification
+func receive(t *testing.T, messages <-chan string) string {
+	select {
+	case m := <-messages:
+		return m
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification received")
+		return ""
+	}
+}
+
+// TestNotifier tests the messages sent to the notify socket
+func TestNotifier(t *testing.T) {
+	path, messages := fakeNotifySocket(t)
+	notifier := systemd.NewNotifier(path)
+	require.True(t, notifier.Enabled())
+
+	require.NoError(t, notifier.Notify(systemd.Ready, systemd.Status("Collecting\nmetrics")))
+	assert.Equal(t, "READY=1\nSTATUS=Collecting metrics", receive(t, messages))
+
+	// Without $NOTIFY_SOCKET nothing is sent
+	disabled := systemd.NewNotifier("")
+	assert.False(t, disabled.Enabled())
+	assert.NoError(t, disabled.Notify(systemd.Ready))
+}
+
+// TestWatchdog tests that keep-alives are only sent while the check reports progress
+func TestWatchdog(t *testing.T) {
+	env := map[string]string{"WATCHDOG_USEC": "30000000", "WATCHDOG_PID": "42"}
+	assert.Equal(t, 30*time.Second, systemd.WatchdogInterval(envOf(env), 42))
+	assert.Zero(t, systemd.WatchdogInterval(envOf(env), 43))
+	assert.Zero(t, systemd.WatchdogInterval(envOf(nil), 42))
+
+	path, messages := fakeNotifySocket(t)
+	notifier := systemd.NewNotifier(path)
+
+	var progressing atomic.Bool
+	progressing.Store(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.RunWatchdog(ctx, 10*time.Millisecond, func() (string, bool) {
+		if progressing.Load() {
+			return "ok", true
+		}
+		return "stalled", false
+	}, func(err error) { t.Error(err) })
+
+	assert.Contains(t, receive(t, messages), systemd.Watchdog)
+
+	progressing.Store(false)
+	// Skip a keep-alive that may have been sent before the change
+	for {
+		m := receive(t, messages)
+		if m == "STATUS=stalled" {
+			break
+		}
+	}
+	assert.Equal(t, "STATUS=stalled", receive(t, messages))
+
+	progressing.Store(true)
+	for {
+		if m := receive(t, messages); m != "STATUS=stalled" {
+			assert.Contains(t, m, systemd.Watchdog)
+			break
+		}
+	}
+}
+
+// TestListenersNotActivated tests that nothing is returned without socket activation
+func TestListenersNotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+
+	listeners, err := systemd.Listeners()
+	require.NoError(t, err)
+	assert.Empty(t, listeners)
+}
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nodebytehosting/syscapture/internal/middleware"
+	"github.com/nodebytehosting/syscapture/internal/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTokenStore tests creating, persisting, expiring and revoking tokens
+func TestTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+
+	store, err := token.Open(path)
+	require.NoError(t, err)
+	store.AddStatic("default", "legacy-secret", []string{token.ScopeAdmin})
+
+	reader, secret, err := store.Create("panel", []string{token.ScopeMetricsRead}, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, reader.Hash)
+
+	_, _, err = store.Create("panel", []string{token.ScopeMetricsRead}, nil)
+	require.ErrorIs(t, err, token.ErrExists)
+	_, _, err = store.Create("bad", []string{"metrics:write"}, nil)
+	require.Error(t, err)
+
+	expired := time.Now().Add(-time.Minute)
+	_, expiredSecret, err := store.Create("old", []string{token.ScopeMetricsRead}, &expired)
+	require.NoError(t, err)
+
+	// Tokens survive reopening the store, static tokens are not persisted
+	store, err = token.Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, store.Len())
+
+	found, err := store.Authenticate(secret)
+	require.NoError(t, err)
+	assert.Equal(t, "panel", found.Name)
+	assert.True(t, found.HasScope(token.ScopeMetricsRead))
+	assert.False(t, found.HasScope(token.ScopeAdmin))
+
+	_, err = store.Authenticate(expiredSecret)
+	require.ErrorIs(t, err, token.ErrExpired)
+	_, err = store.Authenticate("legacy-secret")
+	require.ErrorIs(t, err, token.ErrInvalidToken)
+
+	require.NoError(t, store.Delete("panel"))
+	_, err = store.Authenticate(secret)
+	require.ErrorIs(t, err, token.ErrInvalidToken)
+	require.ErrorIs(t, store.Delete("panel"), token.ErrNotFound)
+}
+
+// TestRequireScope tests the route-level scope checks
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store, err := token.Open("")
+	require.NoError(t, err)
+	store.AddStatic("admin", "admin-secret", []string{token.ScopeAdmin})
+	store.AddStatic("reader", "reader-secret", []string{token.ScopeMetricsRead})
+
+	r := gin.New()
+	api := r.Group("/", middleware.AuthRequired(store))
+	api.GET("/metrics", middleware.RequireScope(token.ScopeMetricsRead), func(c *gin.Context) {
+		c.String(http.StatusOK, middleware.CurrentToken(c).Name)
+	})
+	api.GET("/tokens", middleware.RequireScope(token.ScopeAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	cases := []struct {
+		path   string
+		secret string
+		status int
+	}{
+		{"/metrics", "reader-secret", http.StatusOK},
+		{"/metrics", "admin-secret", http.StatusOK},
+		{"/tokens", "reader-secret", http.StatusForbidden},
+		{"/tokens", "admin-secret", http.StatusOK},
+		{"/metrics", "wrong", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.secret)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.status, w.Code, "%s with %s", tc.path, tc.secret)
+	}
+}
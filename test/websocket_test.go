@@ -0,0 +1,174 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/nodebytehosting/syscapture/internal/audit"
+	"github.com/nodebytehosting/syscapture/internal/collector"
+	"github.com/nodebytehosting/syscapture/internal/handler"
+	"github.com/nodebytehosting/syscapture/internal/middleware"
+	"github.com/nodebytehosting/syscapture/internal/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWebSocketServer starts a test server exposing the WebSocket endpoint with the secret "secret",
+// treating connections as already authenticated when authenticated is set. The endpoint
+// runs behind the middleware of use and records its outcome like the server does.
+func newWebSocketServer(t *testing.T, authenticated bool, use ...gin.HandlerFunc) string {
+	gin.SetMode(gin.TestMode)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	col := collector.New(time.Second, collector.DefaultBufferSize)
+	go col.Run(ctx)
+
+	tokens, err := token.Open("")
+	require.NoError(t, err)
+	tokens.AddStatic("default", "secret", []string{token.ScopeMetricsRead})
+
+	r := gin.New()
+	r.Use(use...)
+	r.GET("/ws", func(c *gin.Context) {
+		handler.MetricsWebSocket(c, col, nil, authenticated, func(secret string) error {
+			t, err := middleware.ValidateToken(tokens, secret, token.ScopeMetricsRead)
+			if err != nil {
+				c.Set(middleware.AuthErrorKey, "Invalid token provided")
+				c.Set(middleware.StatusKey, http.StatusForbidden)
+				return err
+			}
+			c.Set(middleware.TokenKey, t)
+			return nil
+		})
+	})
+	srv := httptest.NewServer(r)
+	t.Cleanup(func() {
+		srv.Close()
+		cancel()
+	})
+
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+}
+
+// TestWebSocketSubscribe tests subprotocol authentication and channel filtering of updates
+func TestWebSocketSubscribe(t *testing.T) {
+	url := newWebSocketServer(t, false)
+
+	dialer := websocket.Dialer{
+		Subprotocols: []string{"syscapture.v1", "bearer." + base64.RawURLEncoding.EncodeToString([]byte("secret"))},
+	}
+	conn, _, err := dialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "syscapture.v1", conn.Subprotocol())
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Second)))
+
+	var msg map[string]any
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "authenticated", msg["type"])
+
+	require.NoError(t, conn.WriteJSON(map[string]any{"type": "subscribe", "channel": "memory", "interval": 1}))
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "subscribed", msg["type"])
+
+	require.NoError(t, conn.ReadJSON(&msg))
+	require.Equal(t, "update", msg["type"])
+	data, ok := msg["data"].(map[string]any)
+	require.True(t, ok)
+	assert.Contains(t, data, "memory.total_bytes")
+	for key := range data {
+		assert.True(t, strings.HasPrefix(key, "memory."), key)
+	}
+
+	// Intervals range from the collection interval to 5 minutes
+	require.NoError(t, conn.WriteJSON(map[string]any{"type": "subscribe", "channel": "cpu", "interval": 301}))
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "error", msg["type"])
+	assert.Contains(t, msg["error"], "between 1 and 300 seconds")
+}
+
+// TestWebSocketAuthMessage tests authentication through the first message
+func TestWebSocketAuthMessage(t *testing.T) {
+	url := newWebSocketServer(t, false)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	require.NoError(t, conn.WriteJSON(map[string]any{"type": "auth", "token": "wrong"}))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.ClosePolicyViolation, closeErr.Code)
+
+	conn, _, err = websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	require.NoError(t, conn.WriteJSON(map[string]any{"type": "auth", "token": "secret"}))
+	var msg map[string]any
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "authenticated", msg["type"])
+}
+
+// TestWebSocketPreAuthenticated tests that connections authenticated before the upgrade,
+// e.g. by a client certificate, need no token
+func TestWebSocketPreAuthenticated(t *testing.T) {
+	url := newWebSocketServer(t, true)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	var msg map[string]any
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "authenticated", msg["type"])
+}
+
+// TestWebSocketBan tests that invalid tokens sent after the upgrade are audited as
+// rejections and count towards a ban
+func TestWebSocketBan(t *testing.T) {
+	log, err := audit.Open(audit.Options{Destination: filepath.Join(t.TempDir(), "audit.log")})
+	require.NoError(t, err)
+	defer log.Close()
+	bans := middleware.NewAuthBan(2, time.Minute, time.Minute)
+	url := newWebSocketServer(t, false, middleware.Audit(log, func(error) {}), bans.Middleware())
+
+	for i := 0; i < 2; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteJSON(map[string]any{"type": "auth", "token": "wrong"}))
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, _, err = conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), err)
+		conn.Close()
+	}
+
+	// The failures are recorded once the connections are closed
+	require.Eventually(t, func() bool {
+		conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		if err == nil {
+			conn.Close()
+			return false
+		}
+		return resp != nil && resp.StatusCode == http.StatusForbidden
+	}, 5*time.Second, 10*time.Millisecond)
+
+	entries, err := log.Query(audit.Query{Failed: true})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(entries), 3)
+	assert.Equal(t, http.StatusForbidden, entries[0].Status)
+	assert.Equal(t, "Invalid token provided", entries[0].Error)
+	assert.Equal(t, "Too many failed authentication attempts", entries[len(entries)-1].Error)
+}